@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+)
+
+// breakersSubcommands lists the `breakers` actions in usage order
+var breakersSubcommands = []command{
+	{name: "list", summary: "Show the state, counts and override of every circuit breaker", run: runBreakersList},
+	{name: "reset", summary: "Close one breaker (or all without argument) immediately", run: runBreakersReset},
+	{name: "open", summary: "Force a server open: stop querying it for -for, with -reason", run: runBreakersOpen},
+	{name: "pin", summary: "Pin a server closed: query it whatever its failures", run: runBreakersPin},
+	{name: "clear", summary: "Remove a server's override", run: runBreakersClear},
+	{name: "overrides", summary: "List active overrides", run: runBreakersOverrides},
+}
+
+// runBreakers implements `ntp-exporter breakers <command>`, a client of a running
+// exporter's /api/v1/breakers and admin endpoints
+func runBreakers(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		for _, sub := range breakersSubcommands {
+			if args[0] == sub.name {
+				return sub.run(args[1:], stdout, stderr)
+			}
+		}
+	}
+
+	fmt.Fprintln(stderr, "Usage: ntp-exporter breakers <command> [flags] [server]")
+	fmt.Fprintln(stderr, "")
+	fmt.Fprintln(stderr, "Commands:")
+	for _, sub := range breakersSubcommands {
+		fmt.Fprintf(stderr, "  %-9s %s\n", sub.name, sub.summary)
+	}
+	return 2
+}
+
+// adminClient calls the exporter HTTP API
+type adminClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// adminTokenFromEnv reads ADMIN_TOKEN or ADMIN_TOKEN_FILE, as the exporter does
+func adminTokenFromEnv() (string, error) {
+	if path := os.Getenv("ADMIN_TOKEN_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("ADMIN_TOKEN_FILE: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return os.Getenv("ADMIN_TOKEN"), nil
+}
+
+// do sends a request and decodes the JSON response into out (when non-nil).
+// Non-2xx responses are returned as errors carrying the API error message.
+func (c *adminClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/api/v1/admin/") {
+			return errors.New(resp.Status + ": admin API not enabled (server.admin.enabled)")
+		}
+		return errors.New(resp.Status)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// parseBreakersFlags adds the shared flags to fs, parses args and returns the
+// client and between minArgs and maxArgs positional arguments described by usage
+func parseBreakersFlags(fs *flag.FlagSet, args []string, stderr io.Writer, usage string, minArgs, maxArgs int) (*adminClient, []string, bool) {
+	baseURL := fs.String("url", "http://localhost:9559", "Base URL of the running exporter")
+	token := fs.String("token", "", "Admin bearer token (default $ADMIN_TOKEN or the content of $ADMIN_TOKEN_FILE)")
+	timeout := fs.Duration("timeout", 10*time.Second, "HTTP request timeout")
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ntp-exporter %s [flags] %s\n", fs.Name(), usage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, false
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, nil, false
+	}
+
+	if *token == "" {
+		var err error
+		if *token, err = adminTokenFromEnv(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return nil, nil, false
+		}
+	}
+
+	client := &adminClient{
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		token:   *token,
+		http:    &http.Client{Timeout: *timeout},
+	}
+	return client, fs.Args(), true
+}
+
+// runBreakersList implements `ntp-exporter breakers list`
+func runBreakersList(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("breakers list", flag.ContinueOnError)
+	client, _, ok := parseBreakersFlags(fs, args, stderr, "", 0, 0)
+	if !ok {
+		return 2
+	}
+
+	var snapshots []ntp.BreakerSnapshot
+	if err := client.do(http.MethodGet, "/api/v1/breakers", nil, &snapshots); err != nil {
+		fmt.Fprintln(stderr, "Failed to list breakers:", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tSTATE\tREQUESTS\tFAILURES\tCONSECUTIVE\tRETRY AT\tOVERRIDE")
+	for _, s := range snapshots {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n",
+			s.Server, s.State, s.Requests, s.TotalFailures, s.ConsecutiveFailures,
+			formatTime(s.RetryAt), formatOverride(s.Override))
+	}
+	tw.Flush()
+	return 0
+}
+
+// runBreakersReset implements `ntp-exporter breakers reset [server]`
+func runBreakersReset(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("breakers reset", flag.ContinueOnError)
+	client, rest, ok := parseBreakersFlags(fs, args, stderr, "[server]", 0, 1)
+	if !ok {
+		return 2
+	}
+
+	req := map[string]string{}
+	if len(rest) == 1 {
+		req["server"] = rest[0]
+	}
+
+	var resp struct {
+		Reset []string `json:"reset"`
+	}
+	if err := client.do(http.MethodPost, "/api/v1/admin/breakers/reset", req, &resp); err != nil {
+		fmt.Fprintln(stderr, "Failed to reset breakers:", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Reset %d breaker(s)", len(resp.Reset))
+	if len(resp.Reset) > 0 {
+		fmt.Fprintf(stdout, ": %s", strings.Join(resp.Reset, ", "))
+	}
+	fmt.Fprintln(stdout)
+	return 0
+}
+
+// runBreakersOpen implements `ntp-exporter breakers open -for 2h -reason "..." server`
+func runBreakersOpen(args []string, stdout, stderr io.Writer) int {
+	return runBreakersOverride("open", ntp.OverrideOpen, args, stdout, stderr)
+}
+
+// runBreakersPin implements `ntp-exporter breakers pin server`
+func runBreakersPin(args []string, stdout, stderr io.Writer) int {
+	return runBreakersOverride("pin", ntp.OverrideClosed, args, stdout, stderr)
+}
+
+// runBreakersOverride installs an override of the given mode
+func runBreakersOverride(name string, mode ntp.OverrideMode, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("breakers "+name, flag.ContinueOnError)
+	duration := fs.Duration("for", 0, "How long the override lasts (0 until cleared)")
+	reason := fs.String("reason", "", "Why the override was set, shown in the API and logs")
+	client, rest, ok := parseBreakersFlags(fs, args, stderr, "<server>", 1, 1)
+	if !ok {
+		return 2
+	}
+
+	req := map[string]string{"server": rest[0], "mode": string(mode), "reason": *reason}
+	if *duration > 0 {
+		req["duration"] = duration.String()
+	}
+
+	var o ntp.Override
+	if err := client.do(http.MethodPost, "/api/v1/admin/breakers/overrides", req, &o); err != nil {
+		fmt.Fprintln(stderr, "Failed to set override:", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%s: %s\n", o.Server, formatOverride(&o))
+	return 0
+}
+
+// runBreakersClear implements `ntp-exporter breakers clear server`
+func runBreakersClear(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("breakers clear", flag.ContinueOnError)
+	client, rest, ok := parseBreakersFlags(fs, args, stderr, "<server>", 1, 1)
+	if !ok {
+		return 2
+	}
+
+	if err := client.do(http.MethodDelete, "/api/v1/admin/breakers/overrides/"+url.PathEscape(rest[0]), nil, nil); err != nil {
+		fmt.Fprintln(stderr, "Failed to clear override:", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Override cleared for %s\n", rest[0])
+	return 0
+}
+
+// runBreakersOverrides implements `ntp-exporter breakers overrides`
+func runBreakersOverrides(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("breakers overrides", flag.ContinueOnError)
+	client, _, ok := parseBreakersFlags(fs, args, stderr, "", 0, 0)
+	if !ok {
+		return 2
+	}
+
+	var overrides []ntp.Override
+	if err := client.do(http.MethodGet, "/api/v1/admin/breakers/overrides", nil, &overrides); err != nil {
+		fmt.Fprintln(stderr, "Failed to list overrides:", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tMODE\tSINCE\tUNTIL\tREASON")
+	for _, o := range overrides {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", o.Server, o.Mode, formatTime(o.CreatedAt), formatTime(o.Until), o.Reason)
+	}
+	tw.Flush()
+	return 0
+}
+
+// formatOverride summarizes an override in one cell
+func formatOverride(o *ntp.Override) string {
+	if o == nil {
+		return "-"
+	}
+
+	s := "forced " + string(o.Mode)
+	if !o.Until.IsZero() {
+		s += " until " + formatTime(o.Until)
+	}
+	if o.Reason != "" {
+		s += " (" + o.Reason + ")"
+	}
+	return s
+}
+
+// formatTime prints t in RFC 3339, or "-" when unset
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
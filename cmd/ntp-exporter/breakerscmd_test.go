@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAdminAPI records the last request and replies with a canned response
+type fakeAdminAPI struct {
+	method, path, auth string
+	body               map[string]string
+	status             int
+	response           string
+}
+
+func (f *fakeAdminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.method, f.path, f.auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+	f.body = nil
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		_ = json.Unmarshal(data, &f.body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.status)
+	_, _ = w.Write([]byte(f.response))
+}
+
+func TestRunBreakers_Open(t *testing.T) {
+	api := &fakeAdminAPI{status: http.StatusCreated, response: `{"server":"stratum1.internal","mode":"open","reason":"maintenance","created_at":"2025-01-01T12:00:00Z"}`}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runBreakers([]string{"open", "-url", ts.URL, "-token", "t0k3n", "-for", "2h", "-reason", "maintenance", "stratum1.internal"}, &stdout, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, http.MethodPost, api.method)
+	assert.Equal(t, "/api/v1/admin/breakers/overrides", api.path)
+	assert.Equal(t, "Bearer t0k3n", api.auth)
+	assert.Equal(t, map[string]string{"server": "stratum1.internal", "mode": "open", "duration": "2h0m0s", "reason": "maintenance"}, api.body)
+	assert.Contains(t, stdout.String(), "forced open (maintenance)")
+}
+
+func TestRunBreakers_ResetAndClear(t *testing.T) {
+	api := &fakeAdminAPI{status: http.StatusOK, response: `{"reset":["a.example","b.example"]}`}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	t.Setenv("ADMIN_TOKEN", "from-env")
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, runBreakers([]string{"reset", "-url", ts.URL}, &stdout, &stderr), stderr.String())
+	assert.Equal(t, "Bearer from-env", api.auth)
+	assert.Empty(t, api.body)
+	assert.Contains(t, stdout.String(), "Reset 2 breaker(s): a.example, b.example")
+
+	api.status, api.response = http.StatusNoContent, ""
+	stdout.Reset()
+	require.Equal(t, 0, runBreakers([]string{"clear", "-url", ts.URL, "stratum1.internal"}, &stdout, &stderr), stderr.String())
+	assert.Equal(t, http.MethodDelete, api.method)
+	assert.Equal(t, "/api/v1/admin/breakers/overrides/stratum1.internal", api.path)
+}
+
+func TestRunBreakers_List(t *testing.T) {
+	api := &fakeAdminAPI{status: http.StatusOK, response: `[{"server":"bad.example","state":"open","consecutive_failures":5}]`}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, runBreakers([]string{"list", "-url", ts.URL}, &stdout, &stderr), stderr.String())
+	assert.Equal(t, "/api/v1/breakers", api.path)
+	assert.Contains(t, stdout.String(), "bad.example")
+	assert.Contains(t, stdout.String(), "open")
+}
+
+func TestRunBreakers_Errors(t *testing.T) {
+	api := &fakeAdminAPI{status: http.StatusUnauthorized, response: `{"error":"unauthorized"}`}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 1, runBreakers([]string{"overrides", "-url", ts.URL}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unauthorized")
+
+	assert.Equal(t, 2, runBreakers([]string{"open", "-url", ts.URL}, &stdout, &stderr), "server is required")
+	assert.Equal(t, 2, runBreakers([]string{"bogus"}, &stdout, &stderr))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml"
+	"github.com/maximewewer/ntp-exporter/internal/config"
+)
+
+// configSubcommands lists the `config` actions in usage order
+var configSubcommands = []command{
+	{name: "validate", summary: "Report every validation error of the effective configuration", run: runConfigValidate},
+	{name: "dump", summary: "Print the effective configuration as YAML with secrets redacted", run: runConfigDump},
+	{name: "explain", summary: "Print each effective value with its source (default, file:line, env var)", run: runConfigExplain},
+}
+
+// runConfig implements `ntp-exporter config <validate|dump|explain>`
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		for _, sub := range configSubcommands {
+			if args[0] == sub.name {
+				return sub.run(args[1:], stdout, stderr)
+			}
+		}
+	}
+
+	fmt.Fprintln(stderr, "Usage: ntp-exporter config <command> [flags]")
+	fmt.Fprintln(stderr, "")
+	fmt.Fprintln(stderr, "Commands:")
+	for _, sub := range configSubcommands {
+		fmt.Fprintf(stderr, "  %-8s %s\n", sub.name, sub.summary)
+	}
+	return 2
+}
+
+// parseConfigFlags parses the flags shared by the config subcommands. The
+// configuration file may be given with -config or as the only argument.
+func parseConfigFlags(name string, args []string, stderr io.Writer) (string, bool) {
+	fs := flag.NewFlagSet("config "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "", "Path to configuration file (environment variables only when empty)")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ntp-exporter config %s [-config file | file]\n", name)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 1 && *configFile != "") {
+		fs.Usage()
+		return "", false
+	}
+	if fs.NArg() == 1 {
+		return fs.Arg(0), true
+	}
+	return *configFile, true
+}
+
+// runConfigValidate implements `ntp-exporter config validate`
+func runConfigValidate(args []string, stdout, stderr io.Writer) int {
+	path, ok := parseConfigFlags("validate", args, stderr)
+	if !ok {
+		return 2
+	}
+
+	initCLILogger()
+	cfg, _, err := config.LoadWithProvenance(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	errs := config.ValidateAll(cfg)
+	if len(errs) == 0 {
+		fmt.Fprintln(stdout, "Configuration is valid")
+		return 0
+	}
+
+	fmt.Fprintf(stdout, "Configuration is invalid (%d errors):\n", len(errs))
+	for _, err := range errs {
+		fmt.Fprintln(stdout, "  -", err)
+	}
+	return 1
+}
+
+// runConfigDump implements `ntp-exporter config dump`
+func runConfigDump(args []string, stdout, stderr io.Writer) int {
+	path, ok := parseConfigFlags("dump", args, stderr)
+	if !ok {
+		return 2
+	}
+
+	initCLILogger()
+	cfg, _, err := config.LoadWithProvenance(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	redacted, err := config.Redacted(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	data, err := yaml.Marshal(redacted)
+	if err != nil {
+		fmt.Fprintln(stderr, "Failed to encode configuration:", err)
+		return 1
+	}
+
+	_, _ = stdout.Write(data)
+	return 0
+}
+
+// runConfigExplain implements `ntp-exporter config explain`
+func runConfigExplain(args []string, stdout, stderr io.Writer) int {
+	path, ok := parseConfigFlags("explain", args, stderr)
+	if !ok {
+		return 2
+	}
+
+	initCLILogger()
+	cfg, prov, err := config.LoadWithProvenance(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	writeExplain(stdout, cfg, prov)
+	return 0
+}
+
+// writeExplain prints one aligned line per configuration leaf with its source
+func writeExplain(w io.Writer, cfg *config.Config, prov config.Provenance) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, field := range config.Fields(cfg) {
+		value := field.Value
+		if field.Secret && value != `""` {
+			value = `"<redacted>"`
+		}
+		fmt.Fprintf(tw, "%s\t= %s\t# %s\n", field.Path, value, prov.Lookup(field.Path))
+	}
+
+	tw.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	valid := writeConfigFile(t, "ntp:\n  servers:\n    - time.google.com\n")
+	invalid := writeConfigFile(t, "ntp:\n  servers:\n    - time.google.com\n  timeout: 90s\nlogging:\n  level: loud\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  []string
+	}{
+		{name: "valid", args: []string{valid}, wantCode: 0, wantOut: []string{"Configuration is valid"}},
+		{name: "valid_flag", args: []string{"-config", valid}, wantCode: 0, wantOut: []string{"Configuration is valid"}},
+		{
+			name:     "all_errors",
+			args:     []string{invalid},
+			wantCode: 1,
+			wantOut:  []string{"(2 errors)", "ntp: timeout must be between 1s and 60s", "logging: invalid log level"},
+		},
+		{name: "missing_file", args: []string{"/nonexistent/config.yaml"}, wantCode: 1},
+		{name: "too_many_args", args: []string{valid, invalid}, wantCode: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runConfig(append([]string{"validate"}, tt.args...), &stdout, &stderr)
+			assert.Equal(t, tt.wantCode, code)
+			for _, want := range tt.wantOut {
+				assert.Contains(t, stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestRunConfigDump(t *testing.T) {
+	path := writeConfigFile(t, "ntp:\n  servers:\n    - time.google.com\n")
+	t.Setenv("NTP_EXPORTER_PORT", "9999")
+
+	var stdout, stderr bytes.Buffer
+	code := runConfig([]string{"dump", path}, &stdout, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "port: 9999")
+	assert.Contains(t, stdout.String(), "- time.google.com")
+	assert.Contains(t, stdout.String(), "timeout: 5s")
+}
+
+func TestRunConfigExplain(t *testing.T) {
+	path := writeConfigFile(t, "ntp:\n  servers:\n    - time.google.com\n")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	var stdout, stderr bytes.Buffer
+	code := runConfig([]string{"explain", "-config", path}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		lines[strings.Fields(line)[0]] = line
+	}
+
+	assert.True(t, strings.HasSuffix(lines["ntp.servers"], "# "+path+":2:3"))
+	assert.True(t, strings.HasSuffix(lines["logging.level"], "# env LOG_LEVEL"))
+	assert.True(t, strings.HasSuffix(lines["server.port"], "# default"))
+}
+
+func TestRunConfig_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runConfig([]string{"bogus"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "validate")
+	assert.Contains(t, stderr.String(), "explain")
+}
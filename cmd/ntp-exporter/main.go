@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"runtime"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/maximewewer/ntp-exporter/internal/collector"
 	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/discovery"
 	"github.com/maximewewer/ntp-exporter/internal/server"
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
 	"github.com/maximewewer/ntp-exporter/pkg/metrics"
@@ -21,9 +25,35 @@ var (
 	version = "dev"
 )
 
+// command is a CLI subcommand sharing the exporter's code paths
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands lists the available subcommands in usage order
+var commands = []command{
+	{name: "query", summary: "Query NTP servers and print offset, RTT, stratum and trust details", run: runQuery},
+	{name: "check", summary: "Nagios/Icinga compatible offset check with --warn/--crit thresholds", run: runCheck},
+	{name: "config", summary: "Validate, dump or explain the effective configuration", run: runConfig},
+	{name: "breakers", summary: "List, reset or override circuit breakers of a running exporter", run: runBreakers},
+}
+
 func main() {
+	// Dispatch subcommands before parsing exporter flags
+	if len(os.Args) > 1 {
+		for _, cmd := range commands {
+			if os.Args[1] == cmd.name {
+				os.Exit(cmd.run(os.Args[2:], os.Stdout, os.Stderr))
+			}
+		}
+	}
+
 	// Parse command-line flags
+	flag.Usage = func() { printUsage(os.Stderr) }
 	configFile := flag.String("config", "", "Path to configuration file")
+	configRequired := flag.Bool("config-required", false, "Exit when the configuration file is missing or invalid instead of falling back to environment variables")
 	showVersion := flag.Bool("version", false, "Show version information")
 	flag.Parse()
 
@@ -35,7 +65,7 @@ func main() {
 	}
 
 	// Load configuration (before logger is initialized)
-	cfg, err := loadConfig(*configFile)
+	cfg, err := loadConfig(*configFile, *configRequired)
 	if err != nil {
 		// Cannot use logger yet, write to stderr
 		os.Stderr.WriteString("Failed to load configuration: " + err.Error() + "\n")
@@ -55,14 +85,24 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Log startup information
+	// Log startup information, without secrets such as the admin token
+	loggedCfg, err := config.Redacted(cfg)
+	if err != nil {
+		logger.Fatal("main", "Failed to redact configuration", err)
+	}
 	logger.Startup(version, "", map[string]interface{}{
 		"go_version": runtime.Version(),
-		"config":     cfg,
+		"config":     loggedCfg,
 	})
 
-	// Create metrics registry with custom namespace and subsystem from config
-	registry := metrics.NewRegistryWithConfig(cfg.Metrics.Namespace, cfg.Metrics.Subsystem)
+	// Resolve ${VAR} templates in the constant labels added to every series
+	constLabels, err := config.ExpandLabels(cfg.Metrics.Labels)
+	if err != nil {
+		logger.Fatal("main", "Failed to expand metrics labels", err)
+	}
+
+	// Create metrics registry with custom namespace, subsystem and labels from config
+	registry := metrics.NewRegistryWithLabels(cfg.Metrics.Namespace, cfg.Metrics.Subsystem, constLabels)
 	if err := registry.Register(); err != nil {
 		logger.Fatal("main", "Failed to register metrics", err)
 	}
@@ -74,8 +114,14 @@ func main() {
 	m.ExporterBuildInfo.WithLabelValues(version, "", runtime.Version()).Set(1)
 	m.ExporterServersConfigured.Set(float64(len(cfg.NTP.Servers) + len(cfg.NTP.Pools)))
 
-	// Create collector registry and register collectors
-	collectorRegistry := collector.NewRegistry()
+	// Create collector registry and register collectors; they share one NTP
+	// client so rate limits and circuit breakers apply across collectors
+	shared := collector.NewShared(cfg, m)
+	if manager := discovery.NewManagerFromConfig(cfg.NTP.Discovery); manager != nil {
+		manager.SetConstLabels(constLabels)
+		shared.SetDiscovery(manager)
+	}
+	collectorRegistry := collector.NewRegistryWithShared(shared)
 	collectorRegistry.Register(collector.NewBaseCollector(cfg, m))
 	collectorRegistry.Register(collector.NewQualityCollector(cfg, m))
 	collectorRegistry.Register(collector.NewSecurityCollector(cfg, m))
@@ -92,12 +138,41 @@ func main() {
 		"kernel_enabled": cfg.NTP.EnableKernel,
 	})
 
+	// Size and check the rate limits against the rate the collectors query at
+	shared.PlanRateLimit(collectorRegistry.EnabledNames())
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Drop expired DNS cache entries in the background
+	if cache := shared.DNSCache(); cache != nil && cfg.NTP.DNSCache.CleanupWorkers > 0 {
+		go cache.StartCleanupWorker(ctx, cfg.NTP.DNSCache.MinTTL)
+	}
+
+	// Run the collector lifecycle hooks, e.g. the hybrid collector watching
+	// for clock steps and suspends between collections
+	if err := collectorRegistry.Init(ctx); err != nil {
+		logger.Fatal("main", "Failed to initialize collectors", err)
+	}
+
+	// Discover targets before the first collection, then follow their changes
+	if manager := shared.Discovery(); manager != nil {
+		manager.Refresh(ctx)
+		logger.SafeInfo("main", "Target discovery enabled", map[string]interface{}{
+			"srv":     cfg.NTP.Discovery.SRV,
+			"files":   cfg.NTP.Discovery.Files,
+			"targets": len(manager.Targets()),
+		})
+		go manager.Run(ctx)
+	}
+
 	// Start HTTP server
 	srv := server.New(cfg, registry.GetRegistry(), m)
+	srv.SetBreakers(shared)
+	if cfg.NTP.CollectionMode == config.CollectionModeOnScrape {
+		srv.SetCollection(collector.NewScrapeCollection(collectorRegistry, cfg.NTP.CollectionMinInterval))
+	}
 	serverErrChan := make(chan error, 1)
 	go func() {
 		serverErrChan <- srv.Start(ctx)
@@ -136,17 +211,42 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("main", "Server shutdown error", err)
 	}
+	if err := collectorRegistry.Close(); err != nil {
+		logger.Error("main", "Collector shutdown error", err)
+	}
 
 	logger.Shutdown("graceful")
 }
 
-// loadConfig loads configuration based on whether a config file is specified
-func loadConfig(configFile string) (*config.Config, error) {
+// printUsage prints the exporter flags followed by the available subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ntp-exporter [flags]")
+	fmt.Fprintln(w, "       ntp-exporter <command> [flags] [args]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Flags:")
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// loadConfig loads configuration based on whether a config file is specified.
+// When required is set, a missing or invalid file is an error.
+func loadConfig(configFile string, required bool) (*config.Config, error) {
 	if configFile != "" {
+		if required {
+			return config.LoadFromRequiredYamlWithEnvOverrides(configFile)
+		}
 		// Load from YAML file with environment variable overrides
 		// Priority: Environment Variables > YAML File > Defaults
 		return config.LoadFromYamlWithEnvOverrides(configFile)
 	}
+	if required {
+		return nil, errors.New("-config-required needs -config")
+	}
 	// No config file specified, use environment variables only
 	// Priority: Environment Variables > Defaults
 	return config.LoadFromEnvVarsOnly()
@@ -158,30 +258,57 @@ func runCollectionLoop(
 	cfg *config.Config,
 	collectorRegistry *collector.Registry,
 ) error {
-	// Initial collection
-	if err := collectorRegistry.CollectAll(ctx); err != nil {
-		logger.Warn("main", "Initial collection failed")
+	// Scrapes of /metrics run the collectors instead
+	if cfg.NTP.CollectionMode == config.CollectionModeOnScrape {
+		logger.SafeInfo("main", "On-scrape collection enabled - collectors run when /metrics is scraped", map[string]interface{}{
+			"min_interval": cfg.NTP.CollectionMinInterval,
+		})
+		<-ctx.Done()
+		return nil
 	}
 
-	// Collection interval using configured scrape_interval
-	ticker := time.NewTicker(cfg.NTP.ScrapeInterval)
-	defer ticker.Stop()
+	// Per-server poll intervals replace the collector intervals, which
+	// validation rejects with poll scheduling
+	if cfg.NTP.PollScheduling.Enabled {
+		return runPollLoop(ctx, collectorRegistry)
+	}
 
 	logger.SafeInfo("main", "Collection loop started", map[string]interface{}{
 		"scrape_interval": cfg.NTP.ScrapeInterval,
+		"collectors":      collectorRegistry.EnabledCount(),
 	})
 
+	// Each collector runs in its own goroutine on its own interval
+	collectorRegistry.Run(ctx, &cfg.NTP)
+
+	logger.Info("main", "Collection loop stopped")
+	return nil
+}
+
+// minPollWait is the shortest wait between two scheduled collections
+const minPollWait = time.Second
+
+// runPollLoop collects each server and pool when its own poll interval has
+// elapsed, independently of scrape_interval
+func runPollLoop(ctx context.Context, collectorRegistry *collector.Registry) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	logger.Info("main", "Poll scheduling enabled - servers are collected at their own poll intervals")
+
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("main", "Collection loop stopped")
 			return nil
-		case <-ticker.C:
+		case <-timer.C:
 			start := time.Now()
-			if err := collectorRegistry.CollectAll(ctx); err != nil {
+			next, err := collectorRegistry.CollectDue(ctx, start)
+			if err != nil {
 				logger.Warn("main", "Collection failed")
 			}
-			logger.Metric("collection", "all", time.Since(start), true)
+			logger.Metric("collection", "due", time.Since(start), true)
+			timer.Reset(max(time.Until(next), minPollWait))
 		}
 	}
 }
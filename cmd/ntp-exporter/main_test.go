@@ -29,7 +29,7 @@ logging:
 	err := os.WriteFile(configFile, []byte(configContent), 0644)
 	assert.NoError(t, err)
 
-	cfg, err := loadConfig(configFile)
+	cfg, err := loadConfig(configFile, false)
 	assert.NoError(t, err)
 	assert.NotNil(t, cfg)
 	assert.Equal(t, 9559, cfg.Server.Port)
@@ -37,11 +37,23 @@ logging:
 
 func TestLoadConfig_FromEnv(t *testing.T) {
 	// Test with empty file (loads from env)
-	cfg, err := loadConfig("")
+	cfg, err := loadConfig("", false)
 	assert.NoError(t, err)
 	assert.NotNil(t, cfg)
 }
 
+func TestLoadConfig_RequiredWithoutFile(t *testing.T) {
+	cfg, err := loadConfig("", true)
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestLoadConfig_RequiredMissingFile(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir()+"/missing.yaml", true)
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
 func TestCollectMetrics(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping network test in short mode")
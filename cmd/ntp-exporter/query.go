@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+	"github.com/maximewewer/ntp-exporter/pkg/mathutil"
+)
+
+// Nagios/Icinga plugin exit codes
+const (
+	exitOK       = 0
+	exitWarning  = 1
+	exitCritical = 2
+	exitUnknown  = 3
+)
+
+// queryOptions holds the flags shared by the query and check subcommands
+type queryOptions struct {
+	timeout time.Duration
+	version int
+	samples int
+}
+
+// serverResult is the outcome of querying one server from the command line
+type serverResult struct {
+	Server           string   `json:"server"`
+	Reachable        bool     `json:"reachable"`
+	Error            string   `json:"error,omitempty"`
+	ErrorReason      string   `json:"error_reason,omitempty"`
+	OffsetSeconds    float64  `json:"offset_seconds"`
+	RTTSeconds       float64  `json:"rtt_seconds"`
+	JitterSeconds    float64  `json:"jitter_seconds"`
+	Stratum          uint8    `json:"stratum"`
+	ReferenceID      string   `json:"refid"`
+	LeapIndicator    uint8    `json:"leap"`
+	TrustScore       float64  `json:"trust_score"`
+	Samples          int      `json:"samples"`
+	PacketLossRatio  float64  `json:"packet_loss_ratio"`
+	Suspicious       bool     `json:"suspicious"`
+	SuspicionReasons []string `json:"suspicion_reasons,omitempty"`
+}
+
+// registerQueryFlags registers the flags shared by query and check on fs
+func registerQueryFlags(fs *flag.FlagSet, opts *queryOptions) {
+	fs.DurationVar(&opts.timeout, "timeout", ntp.DefaultTimeout, "Timeout for each NTP query")
+	fs.IntVar(&opts.version, "ntp-version", 4, "NTP protocol version (2, 3 or 4)")
+	fs.IntVar(&opts.samples, "samples", 3, "Number of samples per server")
+}
+
+// validate checks the shared query flags
+func (o *queryOptions) validate() error {
+	if err := ntp.ValidateTimeout(o.timeout); err != nil {
+		return err
+	}
+	if o.version < 2 || o.version > 4 {
+		return fmt.Errorf("ntp version must be 2, 3, or 4, got %d", o.version)
+	}
+	if o.samples < 1 || o.samples > ntp.MaxSamplesPerQuery {
+		return fmt.Errorf("samples must be between 1 and %d, got %d", ntp.MaxSamplesPerQuery, o.samples)
+	}
+	return nil
+}
+
+// initCLILogger keeps library logging on stderr so it never mixes with command output
+func initCLILogger() {
+	_ = logger.InitLogger(logger.Config{
+		Level:     "error",
+		Format:    "json",
+		Output:    "stderr",
+		Component: "ntp-exporter",
+	})
+}
+
+// runQuery implements `ntp-exporter query [flags] <server...>`
+func runQuery(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts queryOptions
+	registerQueryFlags(fs, &opts)
+	format := fs.String("format", "table", "Output format (table or json)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ntp-exporter query [flags] <server...>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(stderr, "Invalid arguments:", err)
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintln(stderr, "Invalid arguments: format must be table or json")
+		return 2
+	}
+
+	initCLILogger()
+	results := queryServers(context.Background(), fs.Args(), opts)
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(stderr, "Failed to encode results:", err)
+			return 1
+		}
+	} else {
+		writeResultTable(stdout, results)
+	}
+
+	for _, r := range results {
+		if !r.Reachable {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runCheck implements `ntp-exporter check [flags] <server...>` with Nagios plugin semantics
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts queryOptions
+	registerQueryFlags(fs, &opts)
+	warn := fs.Duration("warn", 100*time.Millisecond, "Absolute offset that triggers WARNING")
+	crit := fs.Duration("crit", 500*time.Millisecond, "Absolute offset that triggers CRITICAL")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ntp-exporter check [flags] <server...>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stdout, "NTP UNKNOWN - invalid arguments")
+		return exitUnknown
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stdout, "NTP UNKNOWN - no server specified")
+		return exitUnknown
+	}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(stdout, "NTP UNKNOWN - "+err.Error())
+		return exitUnknown
+	}
+	if *warn <= 0 || *crit <= 0 || *warn > *crit {
+		fmt.Fprintln(stdout, "NTP UNKNOWN - thresholds must be positive and warn <= crit")
+		return exitUnknown
+	}
+
+	initCLILogger()
+	results := queryServers(context.Background(), fs.Args(), opts)
+
+	line, code := evaluateCheck(results, *warn, *crit)
+	fmt.Fprintln(stdout, line)
+	return code
+}
+
+// queryServers queries each server in turn and builds its result
+func queryServers(ctx context.Context, servers []string, opts queryOptions) []serverResult {
+	client := ntp.NewClient(opts.timeout, opts.version)
+	validator := ntp.NewValidator()
+
+	results := make([]serverResult, 0, len(servers))
+	for _, server := range servers {
+		responses, err := client.QueryMultiple(ctx, server, opts.samples)
+		if err != nil {
+			results = append(results, serverResult{Server: server, Error: err.Error(), ErrorReason: ntp.ErrorReason(err)})
+			continue
+		}
+		results = append(results, buildResult(server, responses, opts.samples, validator))
+	}
+
+	return results
+}
+
+// buildResult summarizes the samples of one server. The median offset is
+// reported, while stratum, refid and leap come from the lowest-RTT sample.
+func buildResult(server string, responses []*ntp.Response, requested int, validator *ntp.Validator) serverResult {
+	stats := ntp.CalculateStatistics(responses, requested)
+
+	best := responses[0]
+	for _, resp := range responses[1:] {
+		if resp.RTT < best.RTT {
+			best = resp
+		}
+	}
+
+	validation := validator.Validate(best)
+	result := serverResult{
+		Server:          server,
+		Reachable:       true,
+		OffsetSeconds:   stats.MedianOffset.Seconds(),
+		RTTSeconds:      best.RTT.Seconds(),
+		JitterSeconds:   stats.Jitter.Seconds(),
+		Stratum:         best.Stratum,
+		ReferenceID:     best.ReferenceString(),
+		LeapIndicator:   best.LeapIndicator,
+		TrustScore:      validation.TrustScore,
+		Samples:         stats.SamplesCount,
+		PacketLossRatio: stats.PacketLossRatio,
+		Suspicious:      best.IsSuspicious(),
+	}
+
+	result.SuspicionReasons = append(result.SuspicionReasons, validation.Errors...)
+	result.SuspicionReasons = append(result.SuspicionReasons, validation.Warnings...)
+	if result.Suspicious && len(result.SuspicionReasons) == 0 {
+		result.SuspicionReasons = append(result.SuspicionReasons, validator.GetSuspicionReason(best))
+	}
+
+	return result
+}
+
+// writeResultTable prints results as an aligned table
+func writeResultTable(w io.Writer, results []serverResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tOFFSET\tRTT\tSTRATUM\tREFID\tLEAP\tTRUST\tREASONS")
+
+	for _, r := range results {
+		if !r.Reachable {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\t-\t%s\n", r.Server, r.Error)
+			continue
+		}
+
+		reasons := "-"
+		if len(r.SuspicionReasons) > 0 {
+			reasons = strings.Join(r.SuspicionReasons, "; ")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\t%.2f\t%s\n",
+			r.Server,
+			formatSeconds(r.OffsetSeconds),
+			formatSeconds(r.RTTSeconds),
+			r.Stratum,
+			r.ReferenceID,
+			r.LeapIndicator,
+			r.TrustScore,
+			reasons,
+		)
+	}
+
+	tw.Flush()
+}
+
+// evaluateCheck turns query results into a Nagios status line and exit code.
+// The worst server decides the state; unreachable or untrusted servers are
+// CRITICAL and WARNING respectively.
+func evaluateCheck(results []serverResult, warn, crit time.Duration) (string, int) {
+	code := exitOK
+	var details []string
+	var perfdata []string
+
+	raise := func(c int) {
+		if c > code {
+			code = c
+		}
+	}
+
+	for _, r := range results {
+		if !r.Reachable {
+			raise(exitCritical)
+			details = append(details, r.Server+" unreachable")
+			continue
+		}
+
+		offset := time.Duration(r.OffsetSeconds * float64(time.Second))
+		abs := mathutil.AbsDuration(offset)
+		switch {
+		case abs >= crit:
+			raise(exitCritical)
+		case abs >= warn:
+			raise(exitWarning)
+		}
+		if r.Suspicious {
+			raise(exitWarning)
+		}
+
+		detail := r.Server + " offset " + formatSeconds(r.OffsetSeconds) + " stratum " + strconv.Itoa(int(r.Stratum))
+		if r.Suspicious && len(r.SuspicionReasons) > 0 {
+			detail += " (" + r.SuspicionReasons[0] + ")"
+		}
+		details = append(details, detail)
+
+		perfdata = append(perfdata,
+			"'"+r.Server+"_offset'="+strconv.FormatFloat(r.OffsetSeconds, 'f', 6, 64)+"s;"+
+				strconv.FormatFloat(warn.Seconds(), 'f', 6, 64)+";"+
+				strconv.FormatFloat(crit.Seconds(), 'f', 6, 64),
+			"'"+r.Server+"_rtt'="+strconv.FormatFloat(r.RTTSeconds, 'f', 6, 64)+"s",
+			"'"+r.Server+"_stratum'="+strconv.Itoa(int(r.Stratum)),
+		)
+	}
+
+	line := "NTP " + checkStateName(code) + " - " + strings.Join(details, ", ")
+	if len(perfdata) > 0 {
+		line += " | " + strings.Join(perfdata, " ")
+	}
+	return line, code
+}
+
+// checkStateName returns the Nagios state label for an exit code
+func checkStateName(code int) string {
+	switch code {
+	case exitOK:
+		return "OK"
+	case exitWarning:
+		return "WARNING"
+	case exitCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// formatSeconds formats a value in seconds with microsecond precision
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 6, 64) + "s"
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildResult(t *testing.T) {
+	now := time.Now()
+	responses := []*ntp.Response{
+		{Server: "time.example.com", Offset: 3 * time.Millisecond, RTT: 30 * time.Millisecond, Stratum: 2, ReferenceID: 0xC0000201, ReferenceTime: now},
+		{Server: "time.example.com", Offset: 1 * time.Millisecond, RTT: 10 * time.Millisecond, Stratum: 1, ReferenceID: 0x47505300, ReferenceTime: now},
+		{Server: "time.example.com", Offset: 2 * time.Millisecond, RTT: 20 * time.Millisecond, Stratum: 2, ReferenceID: 0xC0000201, ReferenceTime: now},
+	}
+
+	result := buildResult("time.example.com", responses, 4, ntp.NewValidator())
+
+	assert.True(t, result.Reachable)
+	assert.InDelta(t, 0.002, result.OffsetSeconds, 1e-9, "median offset should be reported")
+	assert.InDelta(t, 0.010, result.RTTSeconds, 1e-9, "lowest RTT sample should be used")
+	assert.Equal(t, uint8(1), result.Stratum)
+	assert.Equal(t, ".GPS.", result.ReferenceID)
+	assert.Equal(t, 3, result.Samples)
+	assert.InDelta(t, 0.25, result.PacketLossRatio, 1e-9)
+	assert.False(t, result.Suspicious)
+	assert.Equal(t, 1.0, result.TrustScore)
+}
+
+func TestBuildResult_KissOfDeath(t *testing.T) {
+	responses := []*ntp.Response{
+		{Server: "kod.example.com", Stratum: 0, KissCode: "RATE", RTT: 10 * time.Millisecond},
+	}
+
+	result := buildResult("kod.example.com", responses, 1, ntp.NewValidator())
+
+	assert.True(t, result.Suspicious)
+	assert.Equal(t, "RATE", result.ReferenceID)
+	assert.NotEmpty(t, result.SuspicionReasons)
+	assert.Less(t, result.TrustScore, 1.0)
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	warn := 100 * time.Millisecond
+	crit := 500 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		results  []serverResult
+		wantCode int
+		wantText string
+	}{
+		{
+			name:     "ok",
+			results:  []serverResult{{Server: "a", Reachable: true, OffsetSeconds: 0.001, Stratum: 2}},
+			wantCode: exitOK,
+			wantText: "NTP OK - a offset 0.001000s stratum 2",
+		},
+		{
+			name:     "warning_on_negative_offset",
+			results:  []serverResult{{Server: "a", Reachable: true, OffsetSeconds: -0.2, Stratum: 2}},
+			wantCode: exitWarning,
+			wantText: "NTP WARNING",
+		},
+		{
+			name:     "critical_offset",
+			results:  []serverResult{{Server: "a", Reachable: true, OffsetSeconds: 0.6, Stratum: 2}},
+			wantCode: exitCritical,
+			wantText: "NTP CRITICAL",
+		},
+		{
+			name:     "suspicious_is_warning",
+			results:  []serverResult{{Server: "a", Reachable: true, Suspicious: true, SuspicionReasons: []string{"kod_received"}}},
+			wantCode: exitWarning,
+			wantText: "(kod_received)",
+		},
+		{
+			name: "worst_server_wins",
+			results: []serverResult{
+				{Server: "a", Reachable: true, OffsetSeconds: 0.001, Stratum: 2},
+				{Server: "b", Error: "timeout"},
+			},
+			wantCode: exitCritical,
+			wantText: "b unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, code := evaluateCheck(tt.results, warn, crit)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Contains(t, line, tt.wantText)
+		})
+	}
+}
+
+func TestEvaluateCheck_Perfdata(t *testing.T) {
+	line, _ := evaluateCheck([]serverResult{
+		{Server: "a", Reachable: true, OffsetSeconds: 0.001, RTTSeconds: 0.02, Stratum: 2},
+	}, 100*time.Millisecond, 500*time.Millisecond)
+
+	parts := strings.SplitN(line, " | ", 2)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "'a_offset'=0.001000s;0.100000;0.500000 'a_rtt'=0.020000s 'a_stratum'=2", parts[1])
+}
+
+func TestWriteResultTable(t *testing.T) {
+	var buf bytes.Buffer
+	writeResultTable(&buf, []serverResult{
+		{Server: "a", Reachable: true, OffsetSeconds: 0.001, RTTSeconds: 0.02, Stratum: 2, ReferenceID: "1.2.3.4", TrustScore: 1},
+		{Server: "b", Error: "i/o timeout"},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "SERVER"))
+	assert.Contains(t, lines[1], "1.2.3.4")
+	assert.Contains(t, lines[2], "i/o timeout")
+}
+
+func TestServerResult_JSON(t *testing.T) {
+	data, err := json.Marshal(serverResult{Server: "a", Reachable: true, ReferenceID: ".GPS."})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"refid":".GPS."`)
+	assert.NotContains(t, string(data), "suspicion_reasons")
+	assert.NotContains(t, string(data), "error_reason")
+
+	data, err = json.Marshal(serverResult{Server: "b", Error: "i/o timeout", ErrorReason: "timeout"})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"error_reason":"timeout"`)
+}
+
+func TestRunCheck_InvalidArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no_server", args: []string{}},
+		{name: "warn_above_crit", args: []string{"-warn", "1s", "-crit", "100ms", "time.example.com"}},
+		{name: "bad_samples", args: []string{"-samples", "0", "time.example.com"}},
+		{name: "unknown_flag", args: []string{"-nope", "time.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCheck(tt.args, &stdout, &stderr)
+			assert.Equal(t, exitUnknown, code)
+			assert.True(t, strings.HasPrefix(stdout.String(), "NTP UNKNOWN"))
+		})
+	}
+}
+
+func TestRunQuery_InvalidFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runQuery([]string{"-format", "xml", "time.example.com"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "format must be table or json")
+}
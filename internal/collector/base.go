@@ -21,6 +21,8 @@ package collector
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
 	"time"
 
@@ -33,12 +35,37 @@ import (
 // BaseCollector collects standard NTP metrics
 type BaseCollector struct {
 	*CommonCollector
+
+	// Addresses probed per server in the last cycle, to drop the series of
+	// addresses no longer resolved
+	addresses map[string][]ntp.ServerAddress
+
+	// Holdover tracking: offsets answered in the current cycle, and the
+	// kernel state when kernel monitoring is enabled
+	holdover         *ntp.HoldoverTracker
+	kernelReader     *ntp.KernelReader
+	cycleOffsets     []float64
+	holdoverExceeded bool
+
+	// Offset history per server, to estimate the local clock frequency
+	frequency *ntp.FrequencyEstimator
+
+	// Per-server poll intervals, nil without poll_scheduling
+	scheduler *ntp.PollScheduler
+
+	// Failed queries per server since it last answered
+	failures map[string]int
 }
 
 // NewBaseCollector creates a new base NTP collector
 func NewBaseCollector(cfg *config.Config, m *metrics.NTPMetrics) *BaseCollector {
 	return &BaseCollector{
 		CommonCollector: NewCommonCollector(cfg, m, "base"),
+		addresses:       make(map[string][]ntp.ServerAddress),
+		holdover:        ntp.NewHoldoverTracker(time.Now()),
+		kernelReader:    ntp.NewKernelReader(cfg.NTP.EnableKernel),
+		frequency:       ntp.NewFrequencyEstimator(),
+		failures:        make(map[string]int),
 	}
 }
 
@@ -53,14 +80,19 @@ func (c *BaseCollector) Collect(ctx context.Context) error {
 	cfg := c.GetConfig()
 	m := c.GetMetrics()
 
-	logger.Infof("collector", "Starting NTP collection with %d servers", len(cfg.NTP.Servers))
+	servers := c.Servers()
+	logger.Infof("collector", "Starting NTP collection with %d servers", len(servers))
 
 	successCount := 0
 	failCount := 0
+	c.cycleOffsets = c.cycleOffsets[:0]
 
-	// Collect from individual servers
-	for _, server := range cfg.NTP.Servers {
-		if err := c.collectFromServer(ctx, server); err != nil {
+	// Collect from individual servers, configured and discovered
+	for _, server := range servers {
+		resp, err := c.collectFromServer(ctx, server)
+		c.recordPoll(server, resp)
+		c.recordQuery(server, err)
+		if err != nil {
 			logger.SafeWarn("collector", "Failed to collect from server", map[string]interface{}{
 				"server": server,
 				"error":  err.Error(),
@@ -75,7 +107,17 @@ func (c *BaseCollector) Collect(ctx context.Context) error {
 
 	// Collect from pools
 	for _, pool := range cfg.NTP.Pools {
-		if err := c.collectFromPool(ctx, pool); err != nil {
+		if !c.isDue(pool.Name) {
+			continue
+		}
+
+		resp, err := c.collectFromPool(ctx, pool)
+		var best *ntp.Response
+		if resp != nil {
+			best = &ntp.Response{Offset: resp.BestOffset}
+		}
+		c.recordPoll(pool.Name, best)
+		if err != nil {
 			logger.SafeWarn("collector", "Failed to collect from pool", map[string]interface{}{
 				"pool":  pool.Name,
 				"error": err.Error(),
@@ -86,6 +128,12 @@ func (c *BaseCollector) Collect(ctx context.Context) error {
 		}
 	}
 
+	// Holdover only applies once there is something to synchronize to
+	if successCount+failCount > 0 {
+		c.updateHoldover(time.Now())
+	}
+	c.updateFrequency(time.Now())
+
 	duration := time.Since(start)
 	m.ExporterScrapeDuration.Observe(duration.Seconds())
 
@@ -104,23 +152,149 @@ func (c *BaseCollector) Collect(ctx context.Context) error {
 	return nil
 }
 
-// collectFromServer collects metrics from a single NTP server
-func (c *BaseCollector) collectFromServer(ctx context.Context, server string) error {
+// collectFromServer collects metrics from a single NTP server and returns
+// the response they come from
+func (c *BaseCollector) collectFromServer(ctx context.Context, server string) (*ntp.Response, error) {
+	if family := c.GetConfig().NTP.AddressFamilyFor(server); family != ntp.AddressFamilyDefault {
+		return c.collectFromAddresses(ctx, server, family)
+	}
+
 	// Query NTP server
 	resp, err := c.GetClient().Query(ctx, server)
 	if err != nil {
 		logger.Error("collector", "Query failed", err)
-		return fmt.Errorf("failed to query NTP server %s: %w", server, err)
+		return nil, fmt.Errorf("failed to query NTP server %s: %w", server, err)
 	}
 
 	// Update metrics
 	c.updateMetrics(resp)
 
-	return nil
+	return resp, nil
+}
+
+// collectFromAddresses resolves a server and queries the addresses selected by
+// its address family separately. Server metrics come from the first address
+// that answers.
+func (c *BaseCollector) collectFromAddresses(ctx context.Context, server, family string) (*ntp.Response, error) {
+	m := c.GetMetrics()
+
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, ""
+	}
+
+	ips, err := c.lookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve NTP server %s: %w", server, err)
+	}
+
+	addresses := ntp.SelectAddresses(ips, family)
+	c.forgetAddresses(server, addresses)
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no %s address for NTP server %s", family, server)
+	}
+
+	var first *ntp.Response
+	var lastErr error
+	for _, addr := range addresses {
+		target := addr.IP
+		if port != "" {
+			target = net.JoinHostPort(addr.IP, port)
+		}
+
+		resp, err := c.GetClient().Query(ctx, target)
+		if err != nil {
+			lastErr = err
+			logger.SafeWarn("collector", "Address query failed", map[string]interface{}{
+				"server":  server,
+				"address": addr.IP,
+				"family":  addr.Family,
+				"error":   err.Error(),
+			})
+			m.AddressUp.WithLabelValues(server, addr.IP, addr.Family).Set(0)
+			continue
+		}
+
+		m.AddressUp.WithLabelValues(server, addr.IP, addr.Family).Set(1)
+		m.AddressOffsetSeconds.WithLabelValues(server, addr.IP, addr.Family).Set(resp.Offset.Seconds())
+		m.AddressRTTSeconds.WithLabelValues(server, addr.IP, addr.Family).Set(resp.RTT.Seconds())
+		m.AddressStratum.WithLabelValues(server, addr.IP, addr.Family).Set(float64(resp.Stratum))
+
+		if first == nil {
+			first = resp
+		}
+	}
+
+	if first == nil {
+		return nil, fmt.Errorf("no address of NTP server %s answered: %w", server, lastErr)
+	}
+
+	// Report under the configured name, not the address that answered
+	serverResp := *first
+	serverResp.Server = server
+	c.updateMetrics(&serverResp)
+	return &serverResp, nil
 }
 
-// collectFromPool collects metrics from an NTP pool
-func (c *BaseCollector) collectFromPool(ctx context.Context, poolCfg config.PoolConfig) error {
+// forgetAddresses deletes the series of addresses a server no longer resolves to
+func (c *BaseCollector) forgetAddresses(server string, current []ntp.ServerAddress) {
+	m := c.GetMetrics()
+
+	keep := make(map[ntp.ServerAddress]bool, len(current))
+	for _, addr := range current {
+		keep[addr] = true
+	}
+
+	for _, addr := range c.addresses[server] {
+		if keep[addr] {
+			continue
+		}
+		m.AddressUp.DeleteLabelValues(server, addr.IP, addr.Family)
+		m.AddressOffsetSeconds.DeleteLabelValues(server, addr.IP, addr.Family)
+		m.AddressRTTSeconds.DeleteLabelValues(server, addr.IP, addr.Family)
+		m.AddressStratum.DeleteLabelValues(server, addr.IP, addr.Family)
+	}
+	c.addresses[server] = current
+}
+
+// SetPollScheduler makes the collector record its polls in a scheduler shared by a registry
+func (c *BaseCollector) SetPollScheduler(scheduler *ntp.PollScheduler) {
+	c.scheduler = scheduler
+}
+
+// recordPoll updates the schedule of a server or pool with the response it
+// answered with, nil when it did not, and exports its poll interval
+func (c *BaseCollector) recordPoll(name string, resp *ntp.Response) {
+	if c.scheduler == nil {
+		return
+	}
+	c.scheduler.Record(name, time.Now(), resp)
+	c.GetMetrics().ScheduledPollSeconds.WithLabelValues(name).Set(c.scheduler.Interval(name).Seconds())
+}
+
+// recordQuery exports the outcome of the query to a server: the reason it
+// failed, or when it last answered
+func (c *BaseCollector) recordQuery(server string, err error) {
+	m := c.GetMetrics()
+	if err != nil {
+		c.failures[server]++
+		m.QueryErrorsTotal.WithLabelValues(server, ntp.ErrorReason(err)).Inc()
+	} else {
+		c.failures[server] = 0
+		m.LastSuccessTimestamp.WithLabelValues(server).Set(float64(time.Now().Unix()))
+	}
+	m.ConsecutiveFailures.WithLabelValues(server).Set(float64(c.failures[server]))
+}
+
+// ForgetServer drops the state kept for a server no longer collected
+func (c *BaseCollector) ForgetServer(server string) {
+	delete(c.addresses, server)
+	delete(c.failures, server)
+	c.frequency.Forget(server)
+}
+
+// collectFromPool collects metrics from an NTP pool and returns its response
+func (c *BaseCollector) collectFromPool(ctx context.Context, poolCfg config.PoolConfig) (*ntp.PoolResponse, error) {
 	cfg := c.GetConfig()
 	m := c.GetMetrics()
 
@@ -132,6 +306,11 @@ func (c *BaseCollector) collectFromPool(ctx context.Context, poolCfg config.Pool
 		c.GetClient(),
 	)
 
+	// Share resolutions and TTLs across pools and collection cycles
+	if cache := c.GetDNSCache(); cache != nil {
+		pool.SetDNSCache(cache)
+	}
+
 	// Enable worker pool if configured and strategy is 'all'
 	if cfg.NTP.WorkerPool.Enabled && poolCfg.Strategy == "all" {
 		pool.EnableWorkerPool(cfg.NTP.WorkerPool.Size)
@@ -140,7 +319,7 @@ func (c *BaseCollector) collectFromPool(ctx context.Context, poolCfg config.Pool
 	resp, err := pool.Query(ctx, cfg.NTP.SamplesPerServer)
 	if err != nil {
 		logger.Error("collector", "Pool query failed", err)
-		return fmt.Errorf("failed to query NTP pool %s: %w", poolCfg.Name, err)
+		return nil, fmt.Errorf("failed to query NTP pool %s: %w", poolCfg.Name, err)
 	}
 
 	// Update pool metrics
@@ -154,7 +333,7 @@ func (c *BaseCollector) collectFromPool(ctx context.Context, poolCfg config.Pool
 		c.updateMetrics(serverResp)
 	}
 
-	return nil
+	return resp, nil
 }
 
 // updateMetrics updates Prometheus metrics from an NTP response
@@ -174,10 +353,23 @@ func (c *BaseCollector) updateMetrics(resp *ntp.Response) {
 		labels["stratum"],
 		labels["version"],
 	).Set(resp.Offset.Seconds())
+	c.cycleOffsets = append(c.cycleOffsets, resp.Offset.Seconds())
+	c.frequency.Record(resp.Server, time.Now(), resp.Offset)
+	c.filterSamples(resp.Server, resp)
+
+	// Offset uncertainty interval
+	lower, upper := resp.OffsetBounds()
+	m.OffsetLowerBound.WithLabelValues(resp.Server).Set(lower.Seconds())
+	m.OffsetUpperBound.WithLabelValues(resp.Server).Set(upper.Seconds())
 
-	// Check if offset exceeds configured threshold
+	// Check if offset exceeds configured threshold, or the whole interval
+	// when offset_bounds_check is set
+	exceeded := resp.Offset.Abs() > cfg.NTP.MaxClockOffset
+	if cfg.NTP.OffsetBoundsCheck {
+		exceeded = lower > cfg.NTP.MaxClockOffset || upper < -cfg.NTP.MaxClockOffset
+	}
 	offsetExceeded := 0.0
-	if resp.Offset.Abs() > cfg.NTP.MaxClockOffset {
+	if exceeded {
 		offsetExceeded = 1.0
 	}
 	m.ClockOffsetExceeded.WithLabelValues(resp.Server).Set(offsetExceeded)
@@ -190,6 +382,8 @@ func (c *BaseCollector) updateMetrics(resp *ntp.Response) {
 	m.RootDistance.WithLabelValues(resp.Server).Set(resp.RootDistance.Seconds())
 	m.Precision.WithLabelValues(resp.Server).Set(resp.Precision.Seconds())
 	m.LeapIndicator.WithLabelValues(resp.Server).Set(float64(resp.LeapIndicator))
+	m.MinErrorSeconds.WithLabelValues(resp.Server).Set(resp.MinError.Seconds())
+	m.PollInterval.WithLabelValues(resp.Server).Set(resp.Poll.Seconds())
 
 	logger.SafeDebug("collector", "Metrics updated", map[string]interface{}{
 		"server":  resp.Server,
@@ -198,3 +392,76 @@ func (c *BaseCollector) updateMetrics(resp *ntp.Response) {
 		"stratum": resp.Stratum,
 	})
 }
+
+// updateHoldover records whether a time source answered in this cycle and
+// exports the holdover estimate, warning when it crosses max_clock_offset
+func (c *BaseCollector) updateHoldover(now time.Time) {
+	cfg := c.GetConfig()
+	m := c.GetMetrics()
+
+	// Without kernel monitoring, the estimate follows the NTP offsets
+	var kernel *ntp.KernelTimex
+	if cfg.NTP.EnableKernel {
+		if state, err := c.kernelReader.Read(); err == nil {
+			kernel = state
+		}
+	}
+
+	switch {
+	case len(c.cycleOffsets) > 0:
+		sort.Float64s(c.cycleOffsets)
+		median := c.cycleOffsets[len(c.cycleOffsets)/2]
+		if len(c.cycleOffsets)%2 == 0 {
+			median = (c.cycleOffsets[len(c.cycleOffsets)/2-1] + median) / 2
+		}
+		c.holdover.RecordSync(now, time.Duration(median*float64(time.Second)), kernel)
+	case c.scheduler != nil && c.scheduler.Answering():
+		// Only some servers were due, and others answered their last poll
+	case c.holdover.RecordLoss():
+		logger.SafeWarn("collector", "No time source answered, entering holdover", nil)
+	}
+
+	est := c.holdover.Estimate(now, kernel)
+	m.HoldoverActive.Set(0)
+	if est.Active {
+		m.HoldoverActive.Set(1)
+	}
+	m.HoldoverSeconds.Set(est.Duration.Seconds())
+	if est.Synced {
+		m.LastSyncTimestamp.Set(float64(est.LastSync.Unix()))
+		m.HoldoverEstimatedErrorSeconds.Set(est.ErrorBound.Seconds())
+		m.HoldoverDriftPPM.Set(est.DriftPPM)
+	}
+
+	exceeded := est.Active && est.Synced && est.ErrorBound > cfg.NTP.MaxClockOffset
+	m.HoldoverErrorExceeded.Set(0)
+	if exceeded {
+		m.HoldoverErrorExceeded.Set(1)
+	}
+	if exceeded && !c.holdoverExceeded {
+		logger.SafeWarn("collector", "Estimated holdover error exceeds max_clock_offset", map[string]interface{}{
+			"holdover_seconds": est.Duration.Seconds(),
+			"estimated_error":  est.ErrorBound.Seconds(),
+			"drift_ppm":        est.DriftPPM,
+			"method":           est.Method,
+			"max_clock_offset": cfg.NTP.MaxClockOffset.Seconds(),
+		})
+	}
+	c.holdoverExceeded = exceeded
+}
+
+// updateFrequency exports the frequency error of the local clock estimated
+// from the offset history; the servers gauge is 0 until there is an estimate
+func (c *BaseCollector) updateFrequency(now time.Time) {
+	m := c.GetMetrics()
+
+	est := c.frequency.Estimate(now)
+	if !est.Valid {
+		m.LocalFrequencyServers.Set(0)
+		return
+	}
+	m.LocalFrequencyPPM.Set(est.PPM)
+	m.LocalFrequencyLowerPPM.Set(est.LowerPPM)
+	m.LocalFrequencyUpperPPM.Set(est.UpperPPM)
+	m.LocalFrequencyServers.Set(float64(est.Servers))
+}
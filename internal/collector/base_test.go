@@ -6,8 +6,11 @@ import (
 	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewBaseCollector(t *testing.T) {
@@ -184,3 +187,305 @@ func BenchmarkBaseCollector_Collect(b *testing.B) {
 		collector.Collect(ctx)
 	}
 }
+
+// multiResolver answers every lookup with a dual-stack set of addresses
+type multiResolver struct {
+	ips []string
+}
+
+func (r *multiResolver) Lookup(ctx context.Context, hostname string) (ntp.DNSAnswer, error) {
+	return ntp.DNSAnswer{IPs: r.ips, TTL: time.Hour}, nil
+}
+
+func TestBaseCollector_AddressFamilies(t *testing.T) {
+	resolver := &multiResolver{ips: []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}}
+
+	newCollector := func(family string) (*BaseCollector, *ntp.MockNTPClient, *metrics.NTPMetrics) {
+		cfg := &config.Config{
+			NTP: config.NTPConfig{
+				Servers:         []string{"time.example"},
+				Timeout:         time.Second,
+				Version:         4,
+				MaxClockOffset:  time.Second,
+				AddressFamilies: map[string]string{"time.example": family},
+			},
+		}
+		m := metrics.NewNTPMetrics()
+		client := ntp.NewMockNTPClient()
+		client.SetupSuccessfulServer("192.0.2.1", 2*time.Millisecond, 2)
+		client.SetupUnreachableServer("192.0.2.2")
+		client.SetupSuccessfulServer("2001:db8::1", 3*time.Millisecond, 1)
+
+		c := NewBaseCollector(cfg, m)
+		c.SetClient(client)
+		c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: resolver}))
+		return c, client, m
+	}
+
+	t.Run("all_addresses", func(t *testing.T) {
+		c, client, m := newCollector(ntp.AddressFamilyAll)
+		require.NoError(t, c.Collect(context.Background()))
+
+		assert.Equal(t, 3, testutil.CollectAndCount(m.AddressUp))
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.AddressUp.WithLabelValues("time.example", "192.0.2.1", "ipv4")))
+		assert.Equal(t, 0.0, testutil.ToFloat64(m.AddressUp.WithLabelValues("time.example", "192.0.2.2", "ipv4")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.AddressUp.WithLabelValues("time.example", "2001:db8::1", "ipv6")))
+		assert.InDelta(t, 0.003, testutil.ToFloat64(m.AddressOffsetSeconds.WithLabelValues("time.example", "2001:db8::1", "ipv6")), 1e-9)
+		assert.Equal(t, 1, client.GetCallCount("192.0.2.2"))
+
+		// Server series come from the first address that answered
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.ServerReachable.WithLabelValues("time.example")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(m.Stratum.WithLabelValues("time.example")))
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		c, client, m := newCollector(ntp.AddressFamilyIPv6)
+		require.NoError(t, c.Collect(context.Background()))
+
+		assert.Equal(t, 1, testutil.CollectAndCount(m.AddressUp))
+		assert.Zero(t, client.GetCallCount("192.0.2.1"))
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.Stratum.WithLabelValues("time.example")))
+	})
+
+	t.Run("stale addresses are dropped", func(t *testing.T) {
+		c, _, m := newCollector(ntp.AddressFamilyAll)
+		require.NoError(t, c.Collect(context.Background()))
+		require.Equal(t, 3, testutil.CollectAndCount(m.AddressUp))
+
+		c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: &multiResolver{ips: []string{"192.0.2.1"}}}))
+		require.NoError(t, c.Collect(context.Background()))
+		assert.Equal(t, 1, testutil.CollectAndCount(m.AddressUp))
+		assert.Equal(t, 1, testutil.CollectAndCount(m.AddressRTTSeconds))
+	})
+
+	t.Run("no address of the family", func(t *testing.T) {
+		c, _, m := newCollector(ntp.AddressFamilyIPv6)
+		c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: &multiResolver{ips: []string{"192.0.2.1"}}}))
+		require.NoError(t, c.Collect(context.Background()))
+		assert.Equal(t, 0.0, testutil.ToFloat64(m.ServerReachable.WithLabelValues("time.example")))
+	})
+}
+
+func TestBaseCollector_UpdateHoldover(t *testing.T) {
+	cfg := &config.Config{NTP: config.NTPConfig{MaxClockOffset: 50 * time.Millisecond}}
+	m := metrics.NewNTPMetrics()
+	c := NewBaseCollector(cfg, m)
+
+	// The offsets decrease by 3ms a minute: the clock gains 50 ppm
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		c.cycleOffsets = []float64{0.010 - 0.003*float64(i), 0.020, -0.050}
+		c.updateHoldover(start.Add(time.Duration(i) * time.Minute))
+	}
+	lastSync := start.Add(4 * time.Minute)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverActive))
+	assert.Equal(t, float64(lastSync.Unix()), testutil.ToFloat64(m.LastSyncTimestamp))
+	assert.InDelta(t, 50, testutil.ToFloat64(m.HoldoverDriftPPM), 1e-3)
+
+	// Every source is lost for 15 minutes: 2ms + 50 ppm over 900s
+	c.cycleOffsets = nil
+	c.updateHoldover(lastSync.Add(15 * time.Minute))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.HoldoverActive))
+	assert.Equal(t, 900.0, testutil.ToFloat64(m.HoldoverSeconds))
+	assert.InDelta(t, 0.047, testutil.ToFloat64(m.HoldoverEstimatedErrorSeconds), 1e-6)
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverErrorExceeded))
+
+	// Past max_clock_offset after 20 minutes
+	c.updateHoldover(lastSync.Add(20 * time.Minute))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.HoldoverErrorExceeded))
+
+	// A source answers again
+	c.cycleOffsets = []float64{0.001}
+	c.updateHoldover(lastSync.Add(21 * time.Minute))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverActive))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverSeconds))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverErrorExceeded))
+}
+
+func TestBaseCollector_UpdateFrequency(t *testing.T) {
+	m := metrics.NewNTPMetrics()
+	c := NewBaseCollector(&config.Config{}, m)
+
+	start := time.Unix(1700000000, 0)
+	c.updateFrequency(start)
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.LocalFrequencyServers))
+
+	// Offsets to both servers grow by 600µs a minute: the clock runs 10 ppm slow
+	for i := 0; i < 4; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		offset := time.Duration(i) * 600 * time.Microsecond
+		c.frequency.Record("a.example", at, offset)
+		c.frequency.Record("b.example", at, offset+5*time.Millisecond)
+	}
+	c.updateFrequency(start.Add(3 * time.Minute))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.LocalFrequencyServers))
+	assert.InDelta(t, -10, testutil.ToFloat64(m.LocalFrequencyPPM), 1e-6)
+	assert.InDelta(t, -10, testutil.ToFloat64(m.LocalFrequencyLowerPPM), 1e-6)
+	assert.InDelta(t, -10, testutil.ToFloat64(m.LocalFrequencyUpperPPM), 1e-6)
+
+	c.ForgetServer("a.example")
+	c.ForgetServer("b.example")
+	c.updateFrequency(start.Add(3 * time.Minute))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.LocalFrequencyServers))
+}
+
+func TestBaseCollector_OffsetBounds(t *testing.T) {
+	resp := &ntp.Response{
+		Server:         "time.example",
+		Offset:         120 * time.Millisecond,
+		RTT:            40 * time.Millisecond,
+		RootDispersion: 5 * time.Millisecond,
+		Poll:           64 * time.Second,
+		MinError:       2 * time.Millisecond,
+	}
+
+	for _, tt := range []struct {
+		name        string
+		boundsCheck bool
+		offset      time.Duration
+		expected    float64
+	}{
+		{"offset_outside", false, 120 * time.Millisecond, 1},
+		{"interval_across_threshold", true, 120 * time.Millisecond, 0},
+		{"interval_outside", true, -130 * time.Millisecond, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{NTP: config.NTPConfig{
+				Version:           4,
+				MaxClockOffset:    100 * time.Millisecond,
+				OffsetBoundsCheck: tt.boundsCheck,
+			}}
+			m := metrics.NewNTPMetrics()
+			c := NewBaseCollector(cfg, m)
+
+			r := *resp
+			r.Offset = tt.offset
+			c.updateMetrics(&r)
+
+			assert.Equal(t, tt.expected, testutil.ToFloat64(m.ClockOffsetExceeded.WithLabelValues("time.example")))
+			assert.InDelta(t, (tt.offset - 25*time.Millisecond).Seconds(), testutil.ToFloat64(m.OffsetLowerBound.WithLabelValues("time.example")), 1e-9)
+			assert.InDelta(t, (tt.offset + 25*time.Millisecond).Seconds(), testutil.ToFloat64(m.OffsetUpperBound.WithLabelValues("time.example")), 1e-9)
+			assert.Equal(t, 64.0, testutil.ToFloat64(m.PollInterval.WithLabelValues("time.example")))
+			assert.Equal(t, 0.002, testutil.ToFloat64(m.MinErrorSeconds.WithLabelValues("time.example")))
+		})
+	}
+}
+
+func TestBaseCollector_ClockFilter(t *testing.T) {
+	samples := []*ntp.Response{
+		{Server: "time.example", Offset: 2 * time.Millisecond, RTT: 20 * time.Millisecond},
+		{Server: "time.example", Offset: time.Millisecond, RTT: 10 * time.Millisecond},
+		{Server: "time.example", Offset: 9 * time.Millisecond, RTT: 80 * time.Millisecond},
+	}
+
+	t.Run("median", func(t *testing.T) {
+		m := metrics.NewNTPMetrics()
+		c := NewBaseCollector(&config.Config{NTP: config.NTPConfig{Version: 4}}, m)
+		for _, resp := range samples {
+			c.updateMetrics(resp)
+		}
+		assert.Equal(t, 0, testutil.CollectAndCount(m.FilteredOffsetSeconds))
+	})
+
+	t.Run("clock_filter", func(t *testing.T) {
+		cfg := &config.Config{NTP: config.NTPConfig{
+			Version:       4,
+			SampleFilters: map[string]string{"base": config.SampleFilterClockFilter},
+		}}
+		m := metrics.NewNTPMetrics()
+		c := NewBaseCollector(cfg, m)
+		for _, resp := range samples {
+			c.updateMetrics(resp)
+		}
+
+		// The last offset is exported as is, the filter keeps the minimum delay
+		assert.Equal(t, 0.009, testutil.ToFloat64(m.OffsetSeconds.WithLabelValues("time.example", "0", "4")))
+		assert.Equal(t, 0.001, testutil.ToFloat64(m.FilteredOffsetSeconds.WithLabelValues("time.example")))
+		assert.Greater(t, testutil.ToFloat64(m.PeerJitterSeconds.WithLabelValues("time.example")), 0.0)
+		assert.Greater(t, testutil.ToFloat64(m.PeerDispersionSeconds.WithLabelValues("time.example")), 0.0)
+	})
+}
+
+func TestBaseCollector_QueryErrors(t *testing.T) {
+	cfg := &config.Config{NTP: config.NTPConfig{
+		Servers:        []string{"good.example", "down.example", "unknown.example"},
+		Timeout:        time.Second,
+		Version:        4,
+		MaxClockOffset: time.Second,
+	}}
+	m := metrics.NewNTPMetrics()
+	client := ntp.NewMockNTPClient()
+	client.SetupSuccessfulServer("good.example", time.Millisecond, 2)
+	client.SetupUnreachableServer("down.example")
+
+	c := NewBaseCollector(cfg, m)
+	c.SetClient(client)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.Collect(context.Background()))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.QueryErrorsTotal.WithLabelValues("down.example", ntp.ReasonTimeout)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.QueryErrorsTotal.WithLabelValues("unknown.example", ntp.ReasonOther)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.ConsecutiveFailures.WithLabelValues("down.example")))
+	assert.Zero(t, testutil.ToFloat64(m.ConsecutiveFailures.WithLabelValues("good.example")))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(m.LastSuccessTimestamp.WithLabelValues("good.example")), 2)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.LastSuccessTimestamp), "only answering servers have a last success")
+
+	// An answer resets the failure streak
+	recovered := ntp.NewMockNTPClient()
+	recovered.SetupSuccessfulServer("down.example", time.Millisecond, 2)
+	c.SetClient(recovered)
+	require.NoError(t, c.Collect(context.Background()))
+	assert.Zero(t, testutil.ToFloat64(m.ConsecutiveFailures.WithLabelValues("down.example")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.QueryErrorsTotal.WithLabelValues("down.example", ntp.ReasonTimeout)))
+}
+
+func TestCommonCollector_QueryTarget(t *testing.T) {
+	cfg := &config.Config{NTP: config.NTPConfig{
+		AddressFamilies: map[string]string{"time.example": ntp.AddressFamilyIPv6, "time.example:10123": ntp.AddressFamilyBoth},
+	}}
+	c := NewCommonCollector(cfg, metrics.NewNTPMetrics(), "security")
+	c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: &multiResolver{ips: []string{"192.0.2.1", "2001:db8::1"}}}))
+
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"time.example", "2001:db8::1"},
+		{"time.example:10123", "192.0.2.1:10123"},
+		{"other.example", "other.example"}, // Resolved by the NTP client
+	}
+	for _, tt := range tests {
+		target, err := c.queryTarget(context.Background(), tt.server)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, target, tt.server)
+	}
+
+	cfg.NTP.AddressFamilies["v4only.example"] = ntp.AddressFamilyIPv4
+	c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: &multiResolver{ips: []string{"2001:db8::2"}}}))
+	_, err := c.queryTarget(context.Background(), "v4only.example")
+	assert.ErrorContains(t, err, "no ipv4 address")
+}
+
+func TestSecurityCollector_AddressFamily(t *testing.T) {
+	cfg := &config.Config{NTP: config.NTPConfig{
+		Servers:        []string{"time.example"},
+		Timeout:        time.Second,
+		Version:        4,
+		MaxClockOffset: time.Second,
+		AddressFamily:  ntp.AddressFamilyIPv6,
+	}}
+	client := ntp.NewMockNTPClient()
+	client.SetupSuccessfulServer("2001:db8::1", time.Millisecond, 2)
+
+	c := NewSecurityCollector(cfg, metrics.NewNTPMetrics())
+	c.SetClient(client)
+	c.SetDNSCache(ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: &multiResolver{ips: []string{"192.0.2.1", "2001:db8::1"}}}))
+
+	require.NoError(t, c.Collect(context.Background()))
+	assert.Equal(t, 1, client.GetCallCount("2001:db8::1"))
+	assert.Zero(t, client.GetCallCount("time.example"))
+}
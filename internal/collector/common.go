@@ -3,20 +3,27 @@ package collector
 import (
 	"context"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
 	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
 	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/sony/gobreaker"
 )
 
 // CommonCollector provides shared functionality for all collectors
 type CommonCollector struct {
-	config  *config.Config
-	client  ntp.NTPQuerier
-	metrics *metrics.NTPMetrics
-	enabled bool
-	name    string
+	config   *config.Config
+	client   ntp.NTPQuerier
+	dnsCache *ntp.DNSCache
+	filter   *ntp.ClockFilter
+	servers  ServerSource
+	due      map[string]bool // Servers and pools collected this cycle, nil for all
+	metrics  *metrics.NTPMetrics
+	enabled  bool
+	name     string
 }
 
 // NewCommonCollector creates a new common collector base
@@ -24,6 +31,7 @@ func NewCommonCollector(cfg *config.Config, m *metrics.NTPMetrics, name string)
 	return &CommonCollector{
 		config:  cfg,
 		client:  createNTPClient(cfg),
+		filter:  ntp.NewClockFilter(),
 		metrics: m,
 		enabled: true,
 		name:    name,
@@ -50,6 +58,153 @@ func (c *CommonCollector) GetClient() ntp.NTPQuerier {
 	return c.client
 }
 
+// SetClient replaces the NTP client, typically with the one shared by a registry
+func (c *CommonCollector) SetClient(client ntp.NTPQuerier) {
+	c.client = client
+}
+
+// GetDNSCache returns the shared DNS cache, nil when the collector has none
+func (c *CommonCollector) GetDNSCache() *ntp.DNSCache {
+	return c.dnsCache
+}
+
+// SetDNSCache sets the DNS cache shared by a registry
+func (c *CommonCollector) SetDNSCache(cache *ntp.DNSCache) {
+	c.dnsCache = cache
+}
+
+// SetClockFilter sets the clock filter registers shared by a registry
+func (c *CommonCollector) SetClockFilter(filter *ntp.ClockFilter) {
+	c.filter = filter
+}
+
+// filterSamples shifts the samples of a server into its clock filter register
+// and exports the filter output, when the collector's sample_filter is
+// clock_filter. It reports whether it did; Kiss-of-Death responses carry no
+// time and are left out.
+func (c *CommonCollector) filterSamples(server string, responses ...*ntp.Response) (ntp.FilterResult, bool) {
+	if c.config.NTP.SampleFilterFor(c.name) != config.SampleFilterClockFilter {
+		return ntp.FilterResult{}, false
+	}
+
+	var result ntp.FilterResult
+	filtered := false
+	for _, resp := range responses {
+		if resp.IsKissOfDeath() {
+			continue
+		}
+		result = c.filter.Add(server, time.Now(), resp)
+		filtered = true
+	}
+	if !filtered {
+		return ntp.FilterResult{}, false
+	}
+
+	c.metrics.FilteredOffsetSeconds.WithLabelValues(server).Set(result.Offset.Seconds())
+	c.metrics.PeerDispersionSeconds.WithLabelValues(server).Set(result.Dispersion.Seconds())
+	c.metrics.PeerJitterSeconds.WithLabelValues(server).Set(result.Jitter.Seconds())
+	return result, true
+}
+
+// SetServerSource sets the source of servers discovered at runtime
+func (c *CommonCollector) SetServerSource(source ServerSource) {
+	c.servers = source
+}
+
+// Servers returns the configured servers followed by the discovered ones
+// not already configured, restricted to the ones due when polls are scheduled
+func (c *CommonCollector) Servers() []string {
+	all := c.AllServers()
+	if c.due == nil {
+		return all
+	}
+
+	servers := make([]string, 0, len(c.due))
+	for _, server := range all {
+		if c.due[server] {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// AllServers returns the configured servers followed by the discovered ones
+// not already configured
+func (c *CommonCollector) AllServers() []string {
+	if c.servers == nil {
+		return c.config.NTP.Servers
+	}
+
+	discovered := c.servers.Servers()
+	servers := make([]string, 0, len(c.config.NTP.Servers)+len(discovered))
+	seen := make(map[string]bool, cap(servers))
+	for _, list := range [][]string{c.config.NTP.Servers, discovered} {
+		for _, server := range list {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
+}
+
+// SetDue restricts the next collections to the given servers and pools; nil
+// collects all of them again
+func (c *CommonCollector) SetDue(names []string) {
+	if names == nil {
+		c.due = nil
+		return
+	}
+	c.due = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.due[name] = true
+	}
+}
+
+// isDue reports whether a server or pool is collected this cycle
+func (c *CommonCollector) isDue(name string) bool {
+	return c.due == nil || c.due[name]
+}
+
+// lookupHost resolves a hostname through the shared DNS cache when available
+func (c *CommonCollector) lookupHost(ctx context.Context, host string) ([]string, error) {
+	if cache := c.GetDNSCache(); cache != nil {
+		return cache.Resolve(ctx, host)
+	}
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// queryTarget returns the address to query a server at. With an address
+// family, it is the first address the family selects, the one the base
+// collector takes the server metrics from when it answers; otherwise the
+// server itself, resolved by the NTP client.
+func (c *CommonCollector) queryTarget(ctx context.Context, server string) (string, error) {
+	family := c.GetConfig().NTP.AddressFamilyFor(server)
+	if family == ntp.AddressFamilyDefault {
+		return server, nil
+	}
+
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, ""
+	}
+
+	ips, err := c.lookupHost(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve NTP server %s: %w", server, err)
+	}
+	addresses := ntp.SelectAddresses(ips, family)
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("no %s address for NTP server %s", family, server)
+	}
+
+	if port != "" {
+		return net.JoinHostPort(addresses[0].IP, port), nil
+	}
+	return addresses[0].IP, nil
+}
+
 // GetMetrics returns the metrics registry
 func (c *CommonCollector) GetMetrics() *metrics.NTPMetrics {
 	return c.metrics
@@ -58,9 +213,10 @@ func (c *CommonCollector) GetMetrics() *metrics.NTPMetrics {
 // IterateServers iterates over all configured servers and collects metrics
 // The collectFunc is called for each server to perform the actual collection
 func (c *CommonCollector) IterateServers(ctx context.Context, collectFunc func(context.Context, string) error, metricType string) error {
-	logger.Infof("collector", "Starting %s metrics collection with %d servers", metricType, len(c.config.NTP.Servers))
+	servers := c.Servers()
+	logger.Infof("collector", "Starting %s metrics collection with %d servers", metricType, len(servers))
 
-	for _, server := range c.config.NTP.Servers {
+	for _, server := range servers {
 		if err := collectFunc(ctx, server); err != nil {
 			logger.SafeWarn("collector", fmt.Sprintf("Failed to collect %s metrics", metricType), map[string]interface{}{
 				"server": server,
@@ -76,7 +232,16 @@ func (c *CommonCollector) IterateServers(ctx context.Context, collectFunc func(c
 // createNTPClient creates an NTP client based on configuration
 // Wraps client with circuit breaker for fault tolerance
 func createNTPClient(cfg *config.Config) ntp.NTPQuerier {
-	var baseClient ntp.NTPQuerier
+	client, _, _ := newNTPClient(cfg, nil)
+	return client
+}
+
+// newNTPClient creates the rate limited client honoring Kiss-of-Death codes and,
+// when enabled, wraps it with circuit breakers reporting transitions to
+// onStateChange. The underlying client (for its rate limiter and KoD backoff)
+// and the breaker client (nil when disabled) are also returned for inspection.
+func newNTPClient(cfg *config.Config, onStateChange func(server string, from, to gobreaker.State)) (ntp.NTPQuerier, *ntp.Client, *ntp.CircuitBreakerClient) {
+	var baseClient *ntp.Client
 
 	if cfg.NTP.RateLimit.Enabled {
 		baseClient = ntp.NewClientWithRateLimit(
@@ -93,6 +258,14 @@ func createNTPClient(cfg *config.Config) ntp.NTPQuerier {
 		)
 	}
 
+	// Kiss-of-Death codes are always honored (RFC 5905), even without rate limiting
+	backoff := ntp.NewKoDBackoff(
+		cfg.NTP.RateLimit.BackoffDuration,
+		cfg.NTP.RateLimit.MaxBackoff,
+		cfg.NTP.RateLimit.KoDSuspend,
+	)
+	baseClient.SetKoDBackoff(backoff)
+
 	// Wrap with circuit breaker if enabled (enabled by default)
 	if cfg.NTP.CircuitBreaker.Enabled {
 		cbConfig := ntp.NewCircuitBreakerConfigWithThreshold(
@@ -101,8 +274,10 @@ func createNTPClient(cfg *config.Config) ntp.NTPQuerier {
 			cfg.NTP.CircuitBreaker.Timeout,
 			cfg.NTP.CircuitBreaker.FailureThreshold,
 		)
-		return ntp.NewCircuitBreakerClient(baseClient, cbConfig)
+		cbConfig.OnStateChange = onStateChange
+		breakers := ntp.NewCircuitBreakerClient(baseClient, cbConfig)
+		return breakers, baseClient, breakers
 	}
 
-	return baseClient
+	return baseClient, baseClient, nil
 }
@@ -4,22 +4,49 @@ import (
 	"context"
 	"math"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
 	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
 	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // HybridCollector collects both NTP and kernel metrics for correlation analysis
 // This collector is designed for Agent mode (DaemonSet) where kernel access is available
 type HybridCollector struct {
 	*CommonCollector
-	kernelReader *ntp.KernelReader
-	nodeName     string
+	kernelReader   *ntp.KernelReader
+	daemonDetector *ntp.DaemonDetector
+	clockWatcher   *ntp.ClockWatcher
+	nodeName       string
+	daemons        map[string]string // Daemon versions of the last time_daemon_info series
+	clockStats     ntp.ClockStats    // Clock discontinuities already added to the counters
+	clocksource    *ntp.Clocksource  // Clocksource of the last collection
+
+	stopWatch context.CancelFunc // Stops the clock watcher started by Init
+	watchDone chan struct{}      // Closed when the clock watcher has stopped
 }
 
+// timeNamespaceOffsetsPath holds the clock offsets of the exporter's own
+// time namespace (Linux 5.6+)
+const timeNamespaceOffsetsPath = "/proc/self/timens_offsets"
+
+// Clock states reported by time_daemon_clock_state
+const (
+	clockStateSynchronized   = "synchronized"   // One daemon adjusts the clock, STA_UNSYNC clear
+	clockStateUnsynchronized = "unsynchronized" // One daemon adjusts the clock, STA_UNSYNC set
+	clockStateConflict       = "conflict"       // Several daemons adjust the clock
+	clockStateFreeRunning    = "free_running"   // No daemon, STA_UNSYNC set
+	clockStateOrphaned       = "orphaned"       // No daemon, yet the kernel still reports a discipline
+)
+
+var clockStates = []string{clockStateSynchronized, clockStateUnsynchronized, clockStateConflict, clockStateFreeRunning, clockStateOrphaned}
+
+var disciplineModes = []string{ntp.DisciplineNone, ntp.DisciplinePLL, ntp.DisciplineFLL}
+
 // NewHybridCollector creates a new hybrid metrics collector
 func NewHybridCollector(cfg *config.Config, m *metrics.NTPMetrics) *HybridCollector {
 	// Get node name from environment (set by DaemonSet)
@@ -36,8 +63,32 @@ func NewHybridCollector(cfg *config.Config, m *metrics.NTPMetrics) *HybridCollec
 	return &HybridCollector{
 		CommonCollector: NewCommonCollector(cfg, m, "hybrid"),
 		kernelReader:    ntp.NewKernelReader(cfg.NTP.EnableKernel),
+		daemonDetector:  ntp.NewDaemonDetector(cfg.NTP.ProcRoot),
+		clockWatcher:    ntp.NewClockWatcher(),
 		nodeName:        nodeName,
+		daemons:         make(map[string]string),
+	}
+}
+
+// Init starts detecting clock steps and suspends between collections, until
+// ctx is done or the collector is closed
+func (c *HybridCollector) Init(ctx context.Context) error {
+	ctx, c.stopWatch = context.WithCancel(ctx)
+	c.watchDone = make(chan struct{})
+	go func() {
+		defer close(c.watchDone)
+		c.clockWatcher.Run(ctx)
+	}()
+	return nil
+}
+
+// Close stops the clock watcher
+func (c *HybridCollector) Close() error {
+	if c.stopWatch != nil {
+		c.stopWatch()
+		<-c.watchDone
 	}
+	return nil
 }
 
 // Collect collects both NTP and kernel metrics and correlates them
@@ -55,6 +106,8 @@ func (c *HybridCollector) Collect(ctx context.Context) error {
 		return nil
 	}
 
+	c.updateClockMetrics()
+
 	// Read kernel state
 	kernelState, err := c.kernelReader.Read()
 	if err != nil {
@@ -67,6 +120,8 @@ func (c *HybridCollector) Collect(ctx context.Context) error {
 
 	// Update kernel metrics
 	c.updateKernelMetrics(kernelState)
+	c.updateDaemonMetrics(kernelState)
+	c.updateClocksourceMetrics(kernelState)
 
 	// Collect NTP metrics and calculate divergence
 	return c.IterateServers(ctx, func(ctx context.Context, server string) error {
@@ -105,14 +160,170 @@ func (c *HybridCollector) updateKernelMetrics(kernelState *ntp.KernelTimex) {
 	})
 }
 
+// updateClockMetrics exports the clock discontinuities seen by the watcher
+// and the time namespaces
+func (c *HybridCollector) updateClockMetrics() {
+	m := c.GetMetrics()
+
+	stats := c.clockWatcher.Stats()
+	m.ClockStepsTotal.WithLabelValues(c.nodeName).Add(float64(stats.Steps - c.clockStats.Steps))
+	m.ClockSuspendsTotal.WithLabelValues(c.nodeName).Add(float64(stats.Suspends - c.clockStats.Suspends))
+	m.ClockSuspendSecondsTotal.WithLabelValues(c.nodeName).Add((stats.SuspendTotal - c.clockStats.SuspendTotal).Seconds())
+	if stats.Steps > 0 {
+		m.ClockLastStepSeconds.WithLabelValues(c.nodeName).Set(stats.LastStep.Seconds())
+	}
+	if stats.SlewValid {
+		m.ClockSlewPPM.WithLabelValues(c.nodeName).Set(stats.SlewPPM)
+	}
+	c.clockStats = stats
+
+	if offsets, err := ntp.ReadTimeNamespaceOffsets(timeNamespaceOffsetsPath); err == nil {
+		for clock, offset := range offsets {
+			m.ClockTimeNamespaceOffsetSeconds.WithLabelValues(c.nodeName, clock).Set(offset.Seconds())
+		}
+	}
+
+	namespaces, err := ntp.CountTimeNamespaces(c.GetConfig().NTP.ProcRoot)
+	if err != nil {
+		logger.SafeDebug("collector", "Failed to count time namespaces", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	m.ClockTimeNamespaces.WithLabelValues(c.nodeName).Set(float64(namespaces))
+}
+
+// updateClocksourceMetrics exports the kernel clocksource, counts its changes
+// and labels the kernel frequency and maximum error with it, so that drift
+// can be told apart per clocksource (tsc, kvm-clock, hpet...)
+func (c *HybridCollector) updateClocksourceMetrics(kernelState *ntp.KernelTimex) {
+	m := c.GetMetrics()
+
+	cs, err := ntp.ReadClocksource(c.GetConfig().NTP.SysRoot)
+	if err != nil {
+		logger.SafeDebug("collector", "Failed to read clocksource", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	m.ClocksourceChangesTotal.WithLabelValues(c.nodeName).Add(0)
+	if previous := c.clocksource; previous != nil {
+		if previous.Current != cs.Current {
+			m.ClocksourceChangesTotal.WithLabelValues(c.nodeName).Inc()
+			m.ClocksourceInfo.DeleteLabelValues(c.nodeName, previous.Current)
+			m.ClocksourceFrequencyPPM.DeleteLabelValues(c.nodeName, previous.Current)
+			m.ClocksourceMaxErrorSeconds.DeleteLabelValues(c.nodeName, previous.Current)
+			logger.SafeWarn("collector", "Kernel clocksource changed", map[string]interface{}{
+				"node":           c.nodeName,
+				"previous":       previous.Current,
+				"current":        cs.Current,
+				"available":      cs.Available,
+				"freq_ppm":       kernelState.GetFrequencyPPM(),
+				"max_error_secs": kernelState.GetMaxErrorSeconds(),
+			})
+		}
+		for _, name := range previous.Available {
+			if !slices.Contains(cs.Available, name) {
+				m.ClocksourceAvailable.DeleteLabelValues(c.nodeName, name)
+			}
+		}
+	}
+	c.clocksource = cs
+
+	m.ClocksourceInfo.WithLabelValues(c.nodeName, cs.Current).Set(1)
+	for _, name := range cs.Available {
+		m.ClocksourceAvailable.WithLabelValues(c.nodeName, name).Set(1)
+	}
+	m.ClocksourceFrequencyPPM.WithLabelValues(c.nodeName, cs.Current).Set(kernelState.GetFrequencyPPM())
+	m.ClocksourceMaxErrorSeconds.WithLabelValues(c.nodeName, cs.Current).Set(kernelState.GetMaxErrorSeconds())
+}
+
+// updateDaemonMetrics exports the time daemons running on the host and
+// correlates them with the kernel synchronization and discipline state
+func (c *HybridCollector) updateDaemonMetrics(kernelState *ntp.KernelTimex) {
+	m := c.GetMetrics()
+
+	daemons, err := c.daemonDetector.Detect()
+	if err != nil {
+		logger.SafeWarn("collector", "Failed to detect time daemons", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	current := make(map[string]string, len(daemons))
+	var adjusting []string
+	for _, daemon := range daemons {
+		current[daemon.Name] = daemon.Version
+		m.TimeDaemonInfo.WithLabelValues(c.nodeName, daemon.Name, daemon.Version).Set(1)
+		if daemon.AdjustsClock {
+			adjusting = append(adjusting, daemon.Name)
+		}
+	}
+	for name, version := range c.daemons {
+		if current[name] != version {
+			m.TimeDaemonInfo.DeleteLabelValues(c.nodeName, name, version)
+		}
+	}
+	c.daemons = current
+
+	conflict := 0.0
+	if len(adjusting) > 1 {
+		conflict = 1
+		logger.SafeWarn("collector", "Several time daemons adjust the system clock", map[string]interface{}{
+			"node":    c.nodeName,
+			"daemons": adjusting,
+		})
+	}
+	m.TimeDaemonConflict.WithLabelValues(c.nodeName).Set(conflict)
+
+	setCurrentLabel(m.KernelDisciplineMode, c.nodeName, kernelState.DisciplineMode(), disciplineModes)
+	setCurrentLabel(m.TimeDaemonClockState, c.nodeName, clockState(len(adjusting), kernelState), clockStates)
+}
+
+// clockState correlates the number of daemons adjusting the clock with the
+// kernel state. A clock no daemon adjusts but still marked synchronized, or
+// still under PLL/FLL discipline, was left behind by a daemon that stopped:
+// the kernel only sets STA_UNSYNC once its maximum error reaches 16s.
+func clockState(adjusting int, kernelState *ntp.KernelTimex) string {
+	switch {
+	case adjusting > 1:
+		return clockStateConflict
+	case adjusting == 1 && kernelState.IsSynchronized():
+		return clockStateSynchronized
+	case adjusting == 1:
+		return clockStateUnsynchronized
+	case kernelState.IsSynchronized() || kernelState.DisciplineMode() != ntp.DisciplineNone:
+		return clockStateOrphaned
+	default:
+		return clockStateFreeRunning
+	}
+}
+
+// setCurrentLabel sets the series of the current value of an enum-like gauge
+// to 1 and deletes the others, as for kernel_sync_status
+func setCurrentLabel(gauge *prometheus.GaugeVec, node, current string, values []string) {
+	for _, value := range values {
+		if value != current {
+			gauge.DeleteLabelValues(node, value)
+		}
+	}
+	gauge.WithLabelValues(node, current).Set(1)
+}
+
 // collectAndCorrelate collects NTP metrics and correlates with kernel state
 func (c *HybridCollector) collectAndCorrelate(ctx context.Context, server string, kernelState *ntp.KernelTimex) error {
 	client := c.GetClient()
 	cfg := c.GetConfig()
 	m := c.GetMetrics()
 
-	// Query NTP server for offset
-	resp, err := client.Query(ctx, server)
+	// Query NTP server for offset, at the address the base collector reports
+	target, err := c.queryTarget(ctx, server)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Query(ctx, target)
 	if err != nil {
 		logger.SafeDebug("ntp", "NTP query failed for correlation", map[string]interface{}{
 			"server": server,
@@ -121,7 +332,11 @@ func (c *HybridCollector) collectAndCorrelate(ctx context.Context, server string
 		return err
 	}
 
+	// Correlate with the clock filter output rather than a queued sample
 	ntpOffset := resp.Offset.Seconds()
+	if result, ok := c.filterSamples(server, resp); ok {
+		ntpOffset = result.Offset.Seconds()
+	}
 	kernelOffset := kernelState.GetOffsetSeconds()
 
 	// Calculate divergence (absolute difference)
@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProcess adds a process with its command line to a fake proc tree
+func writeProcess(t *testing.T, root, pid string, args ...string) {
+	t.Helper()
+	dir := filepath.Join(root, pid)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	cmdline := ""
+	for _, arg := range args {
+		cmdline += arg + "\x00"
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644))
+}
+
+func TestHybridCollector_UpdateDaemonMetrics(t *testing.T) {
+	proc := t.TempDir()
+	cfg := &config.Config{NTP: config.NTPConfig{EnableKernel: true, ProcRoot: proc}}
+	m := metrics.NewNTPMetrics()
+	c := NewHybridCollector(cfg, m)
+	c.nodeName = "node-1"
+
+	// chronyd alone, synchronized without kernel PLL
+	writeProcess(t, proc, "410", "/usr/sbin/chronyd", "-F", "1")
+	c.updateDaemonMetrics(&ntp.KernelTimex{Status: 0})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TimeDaemonInfo.WithLabelValues("node-1", ntp.DaemonChronyd, ntp.UnknownVersion)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.TimeDaemonConflict.WithLabelValues("node-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TimeDaemonClockState.WithLabelValues("node-1", clockStateSynchronized)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.KernelDisciplineMode.WithLabelValues("node-1", ntp.DisciplineNone)))
+
+	// systemd-timesyncd starts as well and enables the kernel PLL
+	writeProcess(t, proc, "520", "/lib/systemd/systemd-timesyncd")
+	c.updateDaemonMetrics(&ntp.KernelTimex{Status: ntp.STA_PLL})
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.TimeDaemonInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TimeDaemonConflict.WithLabelValues("node-1")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.TimeDaemonClockState))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TimeDaemonClockState.WithLabelValues("node-1", clockStateConflict)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.KernelDisciplineMode))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.KernelDisciplineMode.WithLabelValues("node-1", ntp.DisciplinePLL)))
+
+	// Both stop: the kernel is still marked synchronized
+	require.NoError(t, os.RemoveAll(filepath.Join(proc, "410")))
+	require.NoError(t, os.RemoveAll(filepath.Join(proc, "520")))
+	c.updateDaemonMetrics(&ntp.KernelTimex{Status: ntp.STA_PLL})
+
+	assert.Equal(t, 0, testutil.CollectAndCount(m.TimeDaemonInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TimeDaemonClockState.WithLabelValues("node-1", clockStateOrphaned)))
+}
+
+func TestClockState(t *testing.T) {
+	tests := []struct {
+		name      string
+		adjusting int
+		status    int32
+		expected  string
+	}{
+		{"one_synchronized", 1, ntp.STA_PLL, clockStateSynchronized},
+		{"one_unsynchronized", 1, ntp.STA_UNSYNC, clockStateUnsynchronized},
+		{"conflict", 2, 0, clockStateConflict},
+		{"free_running", 0, ntp.STA_UNSYNC, clockStateFreeRunning},
+		{"orphaned_synchronized", 0, 0, clockStateOrphaned},
+		{"orphaned_fll", 0, ntp.STA_UNSYNC | ntp.STA_PLL | ntp.STA_FLL, clockStateOrphaned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, clockState(tt.adjusting, &ntp.KernelTimex{Status: tt.status}))
+		})
+	}
+}
+
+func TestHybridCollector_UpdateClockMetrics(t *testing.T) {
+	proc := t.TempDir()
+	for pid, ns := range map[string]string{"1": "time:[1]", "300": "time:[2]"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(proc, pid, "ns"), 0o755))
+		require.NoError(t, os.Symlink(ns, filepath.Join(proc, pid, "ns", "time")))
+	}
+
+	cfg := &config.Config{NTP: config.NTPConfig{EnableKernel: true, ProcRoot: proc}}
+	m := metrics.NewNTPMetrics()
+	c := NewHybridCollector(cfg, m)
+	c.nodeName = "node-1"
+
+	c.updateClockMetrics()
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.ClockStepsTotal.WithLabelValues("node-1")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.ClockSuspendSecondsTotal.WithLabelValues("node-1")))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.ClockLastStepSeconds), "no step seen yet")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ClockTimeNamespaces.WithLabelValues("node-1")))
+}
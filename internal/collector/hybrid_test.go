@@ -2,12 +2,17 @@ package collector
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewHybridCollector(t *testing.T) {
@@ -147,3 +152,38 @@ func BenchmarkHybridCollector_CalculateCoherence(b *testing.B) {
 		_ = calculateCoherence(0.008, 0.002, cfg)
 	}
 }
+
+func TestHybridCollector_UpdateClocksourceMetrics(t *testing.T) {
+	sys := t.TempDir()
+	dir := filepath.Join(sys, "devices", "system", "clocksource", "clocksource0")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	setClocksource := func(current, available string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "current_clocksource"), []byte(current+"\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "available_clocksource"), []byte(available+"\n"), 0o644))
+	}
+
+	cfg := &config.Config{NTP: config.NTPConfig{EnableKernel: true, SysRoot: sys}}
+	m := metrics.NewNTPMetrics()
+	c := NewHybridCollector(cfg, m)
+	c.nodeName = "node-1"
+
+	setClocksource("tsc", "tsc hpet acpi_pm")
+	c.updateClocksourceMetrics(&ntp.KernelTimex{Frequency: 65536 * 12, MaxError: 2 * time.Millisecond})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ClocksourceInfo.WithLabelValues("node-1", "tsc")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.ClocksourceAvailable))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.ClocksourceChangesTotal.WithLabelValues("node-1")))
+	assert.Equal(t, 12.0, testutil.ToFloat64(m.ClocksourceFrequencyPPM.WithLabelValues("node-1", "tsc")))
+	assert.Equal(t, 0.002, testutil.ToFloat64(m.ClocksourceMaxErrorSeconds.WithLabelValues("node-1", "tsc")))
+
+	// The TSC is marked unstable: the kernel falls back to HPET
+	setClocksource("hpet", "hpet acpi_pm")
+	c.updateClocksourceMetrics(&ntp.KernelTimex{Frequency: -65536 * 40, MaxError: 50 * time.Millisecond})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.ClocksourceInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ClocksourceInfo.WithLabelValues("node-1", "hpet")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.ClocksourceAvailable))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ClocksourceChangesTotal.WithLabelValues("node-1")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.ClocksourceFrequencyPPM))
+	assert.Equal(t, -40.0, testutil.ToFloat64(m.ClocksourceFrequencyPPM.WithLabelValues("node-1", "hpet")))
+}
@@ -2,8 +2,13 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sync"
+	"time"
 
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
 )
 
@@ -19,9 +24,71 @@ type Collector interface {
 	Enabled() bool
 }
 
+// Initializer is implemented by collectors that need setup before their
+// first collection, such as starting a background watcher
+type Initializer interface {
+	// Init prepares the collector; ctx bounds its background work
+	Init(ctx context.Context) error
+}
+
+// Closer is implemented by collectors that release resources when the
+// exporter stops
+type Closer interface {
+	Close() error
+}
+
+// ServerSource provides servers discovered at runtime, collected in addition
+// to the configured ones
+type ServerSource interface {
+	Servers() []string
+}
+
+// clientSetter is implemented by collectors that accept a shared NTP client
+type clientSetter interface {
+	SetClient(client ntp.NTPQuerier)
+}
+
+// dnsCacheSetter is implemented by collectors that accept a shared DNS cache
+type dnsCacheSetter interface {
+	SetDNSCache(cache *ntp.DNSCache)
+}
+
+// clockFilterSetter is implemented by collectors that accept shared clock filter registers
+type clockFilterSetter interface {
+	SetClockFilter(filter *ntp.ClockFilter)
+}
+
+// pollSchedulerSetter is implemented by collectors that record their polls
+type pollSchedulerSetter interface {
+	SetPollScheduler(scheduler *ntp.PollScheduler)
+}
+
+// dueSetter is implemented by collectors that can collect a subset of the servers
+type dueSetter interface {
+	SetDue(names []string)
+}
+
+// serverSourceSetter is implemented by collectors that accept discovered servers
+type serverSourceSetter interface {
+	SetServerSource(source ServerSource)
+}
+
+// serverForgetter is implemented by collectors keeping per-server state
+type serverForgetter interface {
+	ForgetServer(server string)
+}
+
 // Registry manages multiple collectors
 type Registry struct {
 	collectors []Collector
+	shared     *Shared
+
+	// Collectors hold a read lock while they run and removed servers are
+	// forgotten under the write lock, while no collector is collecting
+	mu sync.RWMutex
+
+	// Serializes the refresh of the shared metrics after each run
+	updateMu sync.Mutex
 }
 
 // NewRegistry creates a new collector registry
@@ -31,37 +98,217 @@ func NewRegistry() *Registry {
 	}
 }
 
+// NewRegistryWithShared creates a collector registry whose collectors all use
+// the shared NTP client; breaker metrics are refreshed after each collection
+func NewRegistryWithShared(shared *Shared) *Registry {
+	r := NewRegistry()
+	r.shared = shared
+	return r
+}
+
 // Register registers a collector
 func (r *Registry) Register(c Collector) {
+	if r.shared != nil {
+		if setter, ok := c.(clientSetter); ok {
+			setter.SetClient(r.shared.Client())
+		}
+		if setter, ok := c.(dnsCacheSetter); ok && r.shared.DNSCache() != nil {
+			setter.SetDNSCache(r.shared.DNSCache())
+		}
+		if setter, ok := c.(clockFilterSetter); ok {
+			setter.SetClockFilter(r.shared.ClockFilter())
+		}
+		if setter, ok := c.(pollSchedulerSetter); ok && r.shared.PollScheduler() != nil {
+			setter.SetPollScheduler(r.shared.PollScheduler())
+		}
+		if setter, ok := c.(serverSourceSetter); ok && r.shared.Discovery() != nil {
+			setter.SetServerSource(r.shared.Discovery())
+		}
+	}
 	r.collectors = append(r.collectors, c)
 }
 
-// CollectAll collects metrics from all enabled collectors
+// CollectAll runs every enabled collector concurrently, each within its own
+// timeout (collector_timeouts), so that a slow collector does not delay the
+// others, and returns their errors joined
 func (r *Registry) CollectAll(ctx context.Context) error {
-	var errs []error
+	errs := make([]error, len(r.collectors))
 
-	for _, c := range r.collectors {
+	var wg sync.WaitGroup
+	for i, c := range r.collectors {
 		if !c.Enabled() {
 			continue
 		}
 
-		if err := c.Collect(ctx); err != nil {
-			logger.SafeWarn("collector", "Collection failed", map[string]interface{}{
-				"collector": c.Name(),
-				"error":     err.Error(),
-			})
-			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.collect(ctx, c, r.timeout(c))
+		}()
 	}
+	wg.Wait()
+	r.updateShared()
 
-	if len(errs) > 0 {
-		// Return first error for simplicity
-		return errs[0]
+	return errors.Join(errs...)
+}
+
+// timeout returns the time limit of one run of c, 0 for none
+func (r *Registry) timeout(c Collector) time.Duration {
+	if r.shared == nil {
+		return 0
+	}
+	return r.shared.cfg.NTP.CollectorTimeout(c.Name())
+}
+
+// collect runs one collector, within timeout when positive
+func (r *Registry) collect(ctx context.Context, c Collector, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
+	r.mu.RLock()
+	err := c.Collect(ctx)
+	r.mu.RUnlock()
+	r.recordResult(c, err)
+
+	if err != nil {
+		logger.SafeWarn("collector", "Collection failed", map[string]interface{}{
+			"collector": c.Name(),
+			"error":     err.Error(),
+		})
+		return fmt.Errorf("%s: %w", c.Name(), err)
+	}
 	return nil
 }
 
+// recordResult exports the outcome of a run of c as collector_up, and as
+// collector_errors_total or collector_last_success_timestamp_seconds
+func (r *Registry) recordResult(c Collector, err error) {
+	if r.shared == nil || r.shared.metrics == nil {
+		return
+	}
+
+	m := r.shared.metrics
+	if err != nil {
+		m.CollectorUp.WithLabelValues(c.Name()).Set(0)
+		m.CollectorErrorsTotal.WithLabelValues(c.Name(), errorReason(err)).Inc()
+		return
+	}
+	m.CollectorUp.WithLabelValues(c.Name()).Set(1)
+	m.CollectorLastSuccess.WithLabelValues(c.Name()).Set(float64(time.Now().Unix()))
+}
+
+// errorReason classifies a collection error for collector_errors_total
+func errorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// Init runs the Init hook of the enabled collectors implementing
+// Initializer and returns their errors joined
+func (r *Registry) Init(ctx context.Context) error {
+	var errs []error
+	for _, c := range r.collectors {
+		if initializer, ok := c.(Initializer); ok && c.Enabled() {
+			if err := initializer.Init(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close runs the Close hook of the collectors implementing Closer and
+// returns their errors joined
+func (r *Registry) Close() error {
+	var errs []error
+	for _, c := range r.collectors {
+		if closer, ok := c.(Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// updateShared forgets the servers no longer discovered and refreshes the
+// metrics of the shared state after a collection
+func (r *Registry) updateShared() {
+	if r.shared == nil {
+		return
+	}
+
+	if r.shared.hasRemovedServers() {
+		r.mu.Lock()
+		r.forgetRemovedServers()
+		r.mu.Unlock()
+	}
+
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	r.shared.UpdateTargetMetrics()
+	r.shared.UpdateBreakerMetrics()
+	r.shared.UpdateBackoffMetrics()
+	r.shared.replanRateLimit()
+	r.shared.UpdateRateLimitMetrics()
+	r.shared.UpdateDNSMetrics()
+}
+
+// CollectDue collects the servers and pools whose poll is due at now, per
+// the shared poll scheduler, and returns when the next poll is due. Without
+// a scheduler it collects everything and returns the zero time.
+func (r *Registry) CollectDue(ctx context.Context, now time.Time) (time.Time, error) {
+	if r.shared == nil || r.shared.PollScheduler() == nil {
+		return time.Time{}, r.CollectAll(ctx)
+	}
+
+	scheduler := r.shared.PollScheduler()
+	names := r.shared.PollTargets()
+	due := scheduler.Due(now, names)
+
+	var err error
+	if len(due) > 0 {
+		r.setDue(due)
+		err = r.CollectAll(ctx)
+		r.setDue(nil)
+	}
+	return scheduler.Next(time.Now(), r.shared.PollTargets()), err
+}
+
+// setDue restricts the collectors to the given servers and pools, nil for all
+func (r *Registry) setDue(names []string) {
+	for _, c := range r.collectors {
+		if setter, ok := c.(dueSetter); ok {
+			setter.SetDue(names)
+		}
+	}
+}
+
+// forgetRemovedServers drops the series and state of the servers no longer
+// discovered. It runs between collections so that no collector recreates
+// series for a server while they are deleted.
+func (r *Registry) forgetRemovedServers() {
+	for _, server := range r.shared.takeRemovedServers() {
+		for _, c := range r.collectors {
+			if forgetter, ok := c.(serverForgetter); ok {
+				forgetter.ForgetServer(server)
+			}
+		}
+		r.shared.forgetServer(server)
+	}
+}
+
 // List returns all registered collectors
 func (r *Registry) List() []Collector {
 	return r.collectors
@@ -72,6 +319,17 @@ func (r *Registry) Count() int {
 	return len(r.collectors)
 }
 
+// EnabledNames returns the names of the enabled collectors
+func (r *Registry) EnabledNames() []string {
+	names := make([]string, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		if c.Enabled() {
+			names = append(names, c.Name())
+		}
+	}
+	return names
+}
+
 // EnabledCount returns the number of enabled collectors
 func (r *Registry) EnabledCount() int {
 	count := 0
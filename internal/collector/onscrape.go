@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ScrapeCollection runs the collectors of a registry when metrics are
+// scraped (collection_mode: on_scrape). Concurrent scrapes share one run, and
+// the results of a run are reused by the scrapes within minInterval of it.
+type ScrapeCollection struct {
+	registry    *Registry
+	minInterval time.Duration
+	group       singleflight.Group
+
+	mu   sync.Mutex
+	last time.Time // When the last run finished
+}
+
+// NewScrapeCollection creates an on-scrape collection of the registry
+func NewScrapeCollection(registry *Registry, minInterval time.Duration) *ScrapeCollection {
+	return &ScrapeCollection{
+		registry:    registry,
+		minInterval: minInterval,
+	}
+}
+
+// Collect runs the collectors unless their last run finished less than
+// minInterval ago. It returns when the run ends or when ctx is done. The run
+// shares the deadline of the scrape that started it, so it is cut short with
+// that scrape; only when the scrape is cancelled earlier, e.g. by a client
+// disconnect, does it go on until the deadline for the next scrape.
+func (s *ScrapeCollection) Collect(ctx context.Context) error {
+	if s.fresh() {
+		return nil
+	}
+
+	ch := s.group.DoChan("collect", func() (interface{}, error) {
+		// A run may have finished between the check above and this one
+		if s.fresh() {
+			return nil, nil
+		}
+		return nil, s.run(ctx)
+	})
+
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects within the deadline of ctx, but not its cancellation
+func (s *ScrapeCollection) run(ctx context.Context) error {
+	runCtx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithDeadline(runCtx, deadline)
+		defer cancel()
+	}
+
+	err := s.registry.CollectAll(runCtx)
+
+	now := time.Now()
+	s.mu.Lock()
+	s.last = now
+	s.mu.Unlock()
+	if s.registry.shared != nil && s.registry.shared.metrics != nil {
+		s.registry.shared.metrics.CollectionTimestamp.Set(float64(now.UnixNano()) / 1e9)
+	}
+	return err
+}
+
+// fresh reports whether the last run finished within minInterval
+func (s *ScrapeCollection) fresh() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.last.IsZero() && time.Since(s.last) < s.minInterval
+}
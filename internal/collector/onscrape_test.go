@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeCollection_SingleFlight(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := metrics.NewNTPMetrics()
+
+	registry := NewRegistryWithShared(NewShared(cfg, m))
+	base := &countingCollector{name: "base", duration: 50 * time.Millisecond}
+	registry.Register(base)
+	collection := NewScrapeCollection(registry, time.Hour)
+
+	// Concurrent scrapes share one run
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, collection.Collect(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), base.runs.Load())
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(m.CollectionTimestamp), 2)
+
+	// Later scrapes reuse the results within the min interval
+	require.NoError(t, collection.Collect(context.Background()))
+	assert.Equal(t, int32(1), base.runs.Load())
+
+	collection.minInterval = 0
+	require.NoError(t, collection.Collect(context.Background()))
+	assert.Equal(t, int32(2), base.runs.Load())
+}
+
+func TestScrapeCollection_ScrapeTimeout(t *testing.T) {
+	registry := NewRegistry()
+	slow := &countingCollector{name: "quality", duration: 200 * time.Millisecond}
+	registry.Register(slow)
+	collection := NewScrapeCollection(registry, time.Hour)
+
+	// The scrape gives up at its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := collection.Collect(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Eventually(t, func() bool { return slow.cancelled.Load() == 1 }, time.Second, 10*time.Millisecond,
+		"the run stops at the deadline of the scrape that started it")
+
+	// A cancelled scrape does not cancel the run it started
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	slow = &countingCollector{name: "quality", duration: 50 * time.Millisecond}
+	registry = NewRegistry()
+	registry.Register(slow)
+	collection = NewScrapeCollection(registry, time.Hour)
+	assert.ErrorIs(t, collection.Collect(ctx), context.Canceled)
+	assert.Eventually(t, collection.fresh, time.Second, 10*time.Millisecond)
+	assert.Zero(t, slow.cancelled.Load())
+}
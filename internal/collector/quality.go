@@ -33,8 +33,13 @@ func (c *QualityCollector) collectFromServer(ctx context.Context, server string)
 	client := c.GetClient()
 	m := c.GetMetrics()
 
+	// Query the address the base collector reports, with an address family
+	target, err := c.queryTarget(ctx, server)
+	if err != nil {
+		return err
+	}
+
 	var responses []*ntp.Response
-	var err error
 	var stats *ntp.Statistics
 
 	// Use adaptive sampling if enabled
@@ -46,7 +51,7 @@ func (c *QualityCollector) collectFromServer(ctx context.Context, server string)
 			MaxDuration:      cfg.NTP.AdaptiveSampling.MaxDuration,
 		}, client)
 
-		responses, err = sampler.Sample(ctx, server)
+		responses, err = sampler.Sample(ctx, target)
 		if err != nil {
 			logger.Error("collector", "Adaptive sampling failed", err)
 			return fmt.Errorf("failed to adaptively sample server %s: %w", server, err)
@@ -61,7 +66,7 @@ func (c *QualityCollector) collectFromServer(ctx context.Context, server string)
 		})
 	} else {
 		// Standard fixed sampling
-		responses, err = client.QueryMultiple(ctx, server, cfg.NTP.SamplesPerServer)
+		responses, err = client.QueryMultiple(ctx, target, cfg.NTP.SamplesPerServer)
 		if err != nil {
 			logger.Error("collector", "Multiple queries failed", err)
 			return fmt.Errorf("failed to query multiple samples from server %s: %w", server, err)
@@ -71,6 +76,9 @@ func (c *QualityCollector) collectFromServer(ctx context.Context, server string)
 		stats = ntp.CalculateStatistics(responses, cfg.NTP.SamplesPerServer)
 	}
 
+	// Keep the minimum-delay sample across cycles with sample_filter: clock_filter
+	c.filterSamples(server, responses...)
+
 	// Update quality metrics
 	m.JitterSeconds.WithLabelValues(server).Set(stats.Jitter.Seconds())
 	m.StabilitySeconds.WithLabelValues(server).Set(stats.StdDevOffset.Seconds())
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+)
+
+// defaultPoolMaxServers mirrors ntp.NewPool when max_servers is not set
+const defaultPoolMaxServers = 4
+
+// RateBudget is the rate of NTP queries the collectors send, used to size and
+// check the rate limits
+type RateBudget struct {
+	PerServerRate  float64 // Queries per second one server or address gets
+	PerServerBurst int     // Queries one server gets when every collector runs at once
+	TotalRate      float64 // Queries per second sent to all servers, pools included
+}
+
+// NewRateBudget computes the query rate of the enabled collectors against
+// servers servers (configured and discovered) and the configured pools. Each
+// collector runs on its own interval: the quality collector sends
+// SamplesPerServer queries to each server (HighDriftSamples at most with
+// adaptive sampling) and every other collector one. The base collector
+// queries each address selected by the address family of a server, counting
+// two for all_addresses, and SamplesPerServer queries to each pool server.
+func NewRateBudget(cfg *config.Config, collectors []string, servers int) RateBudget {
+	samples := cfg.NTP.SamplesPerServer
+	if cfg.NTP.AdaptiveSampling.Enabled && cfg.NTP.AdaptiveSampling.HighDriftSamples > samples {
+		samples = cfg.NTP.AdaptiveSampling.HighDriftSamples
+	}
+
+	var budget RateBudget
+	var baseRate, otherRate float64 // Per server, of the base and other collectors
+	for _, name := range collectors {
+		queries := 1
+		if name == "quality" {
+			queries = samples
+		}
+		budget.PerServerBurst += queries
+
+		rate := float64(queries) / budgetInterval(&cfg.NTP, name).Seconds()
+		if name == "base" {
+			baseRate = rate
+		} else {
+			otherRate += rate
+		}
+	}
+	budget.PerServerRate = baseRate + otherRate
+
+	// Discovered servers get the default address family, configured ones may
+	// have their own
+	discovered := float64(max(servers-len(cfg.NTP.Servers), 0))
+	budget.TotalRate = discovered * (otherRate + float64(budgetAddresses(cfg.NTP.AddressFamily))*baseRate)
+	for _, server := range cfg.NTP.Servers {
+		budget.TotalRate += otherRate + float64(budgetAddresses(cfg.NTP.AddressFamilyFor(server)))*baseRate
+	}
+
+	if baseRate > 0 && len(cfg.NTP.Pools) > 0 {
+		poolRate := float64(cfg.NTP.SamplesPerServer) / budgetInterval(&cfg.NTP, "base").Seconds()
+		budget.PerServerRate = max(budget.PerServerRate, poolRate)
+		budget.PerServerBurst = max(budget.PerServerBurst, cfg.NTP.SamplesPerServer)
+		for _, pool := range cfg.NTP.Pools {
+			maxServers := pool.MaxServers
+			if maxServers == 0 {
+				maxServers = defaultPoolMaxServers
+			}
+			budget.TotalRate += float64(maxServers) * poolRate
+		}
+	}
+	return budget
+}
+
+// budgetInterval is the shortest interval between two runs of a collector:
+// its own interval, the shortest poll with poll scheduling, or the minimum
+// interval between collections on scrape
+func budgetInterval(cfg *config.NTPConfig, collector string) time.Duration {
+	switch {
+	case cfg.PollScheduling.Enabled:
+		return time.Duration(1<<cfg.PollScheduling.MinPoll) * time.Second
+	case cfg.CollectionMode == config.CollectionModeOnScrape && cfg.CollectionMinInterval > 0:
+		return cfg.CollectionMinInterval
+	}
+	return cfg.CollectorInterval(collector)
+}
+
+// budgetAddresses is the number of addresses queried for an address family
+func budgetAddresses(family string) int {
+	if family == ntp.AddressFamilyBoth || family == ntp.AddressFamilyAll {
+		return 2
+	}
+	return 1
+}
+
+// Warnings describes the rate limits below the rate the collectors send
+// queries at: collections would then be throttled longer every run
+func (b RateBudget) Warnings(globalRate, perServerRate float64) []string {
+	var warnings []string
+	if perServerRate < b.PerServerRate {
+		warnings = append(warnings, fmt.Sprintf(
+			"per-server rate limit of %g/s is below the %.3g queries per second the collectors send to each server",
+			perServerRate, b.PerServerRate))
+	}
+	if globalRate < b.TotalRate {
+		warnings = append(warnings, fmt.Sprintf(
+			"global rate limit of %g/s is below the %.3g queries per second the collectors send",
+			globalRate, b.TotalRate))
+	}
+	return warnings
+}
+
+// rateLimitMetrics reports rate limiter decisions as metrics
+type rateLimitMetrics struct {
+	metrics *metrics.NTPMetrics
+}
+
+// ObserveWait implements ntp.RateLimitObserver
+func (r rateLimitMetrics) ObserveWait(server string, wait time.Duration) {
+	r.metrics.RateLimitWaitSeconds.WithLabelValues(server).Observe(wait.Seconds())
+}
+
+// ObserveThrottled implements ntp.RateLimitObserver
+func (r rateLimitMetrics) ObserveThrottled(scope, server string) {
+	r.metrics.RateLimitThrottledTotal.WithLabelValues(scope, server).Inc()
+}
+
+// ObserveRejected implements ntp.RateLimitObserver
+func (r rateLimitMetrics) ObserveRejected(scope, server string) {
+	r.metrics.RateLimitRejectedTotal.WithLabelValues(scope, server).Inc()
+}
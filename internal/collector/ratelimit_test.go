@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateBudget(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.Servers = []string{"a.example", "b.example"}
+	cfg.NTP.Pools = []config.PoolConfig{{Name: "pool.example", MaxServers: 2}, {Name: "other.example"}}
+	cfg.NTP.SamplesPerServer = 3
+	cfg.NTP.ScrapeInterval = 30 * time.Second
+	collectors := []string{"base", "quality", "security"}
+
+	t.Run("scrape interval", func(t *testing.T) {
+		budget := NewRateBudget(cfg, collectors, 2)
+		assert.InDelta(t, 5.0/30, budget.PerServerRate, 1e-9) // 3 samples + 2 single-query collectors
+		assert.Equal(t, 5, budget.PerServerBurst)
+		assert.InDelta(t, (2*5+(2+4)*3)/30.0, budget.TotalRate, 1e-9)
+	})
+
+	t.Run("collector intervals", func(t *testing.T) {
+		scheduled := *cfg
+		scheduled.NTP.CollectorIntervals = map[string]time.Duration{"base": 10 * time.Second, "quality": 5 * time.Minute}
+
+		budget := NewRateBudget(&scheduled, collectors, 2)
+		assert.InDelta(t, 3.0/10, budget.PerServerRate, 1e-9, "pool servers get the samples of every base run")
+
+		scheduled.NTP.Pools = nil
+		budget = NewRateBudget(&scheduled, collectors, 2)
+		assert.InDelta(t, 1.0/10+3.0/300+1.0/30, budget.PerServerRate, 1e-9)
+		assert.Equal(t, 5, budget.PerServerBurst)
+	})
+
+	t.Run("discovered servers and address families", func(t *testing.T) {
+		families := *cfg
+		families.NTP.Pools = nil
+		families.NTP.AddressFamilies = map[string]string{"a.example": ntp.AddressFamilyBoth}
+
+		budget := NewRateBudget(&families, []string{"base", "security"}, 5)
+		assert.InDelta(t, 2.0/30, budget.PerServerRate, 1e-9)
+		// a.example: two addresses for base, the first one for security; b.example and 3 discovered servers: 2 queries
+		assert.InDelta(t, (3+4*2)/30.0, budget.TotalRate, 1e-9)
+
+		// The default family applies to the discovered servers too
+		families.NTP.AddressFamily = ntp.AddressFamilyBoth
+		budget = NewRateBudget(&families, []string{"base", "security"}, 5)
+		assert.InDelta(t, (3+3+3*3)/30.0, budget.TotalRate, 1e-9)
+	})
+
+	t.Run("adaptive sampling", func(t *testing.T) {
+		adaptive := *cfg
+		adaptive.NTP.AdaptiveSampling.Enabled = true
+		adaptive.NTP.AdaptiveSampling.HighDriftSamples = 8
+
+		budget := NewRateBudget(&adaptive, collectors, 2)
+		assert.Equal(t, 10, budget.PerServerBurst)
+	})
+
+	t.Run("poll scheduling", func(t *testing.T) {
+		polled := *cfg
+		polled.NTP.PollScheduling = config.PollSchedulingConfig{Enabled: true, MinPoll: 4, MaxPoll: 10}
+
+		budget := NewRateBudget(&polled, collectors, 2)
+		assert.InDelta(t, 5.0/16, budget.PerServerRate, 1e-9)
+	})
+
+	t.Run("no collectors", func(t *testing.T) {
+		budget := NewRateBudget(cfg, nil, 2)
+		assert.Zero(t, budget.PerServerRate)
+		assert.Zero(t, budget.TotalRate)
+	})
+}
+
+func TestRateBudget_Warnings(t *testing.T) {
+	budget := RateBudget{PerServerRate: 0.5, PerServerBurst: 5, TotalRate: 10}
+
+	assert.Empty(t, budget.Warnings(10, 1))
+
+	warnings := budget.Warnings(5, 0.1)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "per-server rate limit of 0.1/s")
+	assert.Contains(t, warnings[1], "global rate limit of 5/s")
+
+	assert.Empty(t, RateBudget{}.Warnings(0, 0))
+}
+
+func TestShared_PlanRateLimit(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.Servers = []string{"a.example"}
+	cfg.NTP.SamplesPerServer = 3
+	cfg.NTP.ScrapeInterval = 30 * time.Second
+	cfg.NTP.RateLimit.Enabled = true
+	collectors := []string{"base", "quality", "security"}
+
+	t.Run("disabled", func(t *testing.T) {
+		disabled := *cfg
+		disabled.NTP.RateLimit.Enabled = false
+
+		shared := NewShared(&disabled, metrics.NewNTPMetrics())
+		assert.Nil(t, shared.RateLimiter())
+		shared.PlanRateLimit(collectors)
+		shared.replanRateLimit()
+	})
+
+	t.Run("configured rate", func(t *testing.T) {
+		m := metrics.NewNTPMetrics()
+		shared := NewShared(cfg, m)
+		require.NotNil(t, shared.RateLimiter())
+
+		shared.PlanRateLimit(collectors)
+		perSecond, burst := shared.RateLimiter().PerServerLimit()
+		assert.Equal(t, float64(cfg.NTP.RateLimit.PerServerRate), perSecond)
+		assert.Equal(t, cfg.NTP.RateLimit.BurstSize, burst)
+		assert.Equal(t, perSecond, testutil.ToFloat64(m.RateLimitPerServerRate))
+	})
+
+	t.Run("auto rate", func(t *testing.T) {
+		auto := *cfg
+		auto.NTP.RateLimit.AutoPerServerRate = true
+		auto.NTP.RateLimit.BurstSize = 2
+		auto.NTP.CollectorIntervals = map[string]time.Duration{"quality": 5 * time.Minute}
+
+		m := metrics.NewNTPMetrics()
+		shared := NewShared(&auto, m)
+		shared.PlanRateLimit(collectors)
+
+		perSecond, burst := shared.RateLimiter().PerServerLimit()
+		assert.InDelta(t, 2.0/30+3.0/300, perSecond, 1e-9)
+		assert.Equal(t, 5, burst) // Every collector at once fits in the burst
+		assert.InDelta(t, perSecond, testutil.ToFloat64(m.RateLimitPerServerRate), 1e-9)
+		assert.Equal(t, 1, shared.plannedServers())
+	})
+}
+
+func TestShared_RateLimitMetrics(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.RateLimit.Enabled = true
+	cfg.NTP.RateLimit.GlobalRate = 1000
+	cfg.NTP.RateLimit.PerServerRate = 1
+	cfg.NTP.RateLimit.BurstSize = 1
+
+	m := metrics.NewNTPMetrics()
+	shared := NewShared(cfg, m)
+	limiter := shared.RateLimiter()
+	require.NotNil(t, limiter)
+
+	require.NoError(t, limiter.Wait(context.Background(), "a.example"))
+
+	// The per-server limiter is empty: a short deadline is rejected
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, limiter.Wait(ctx, "a.example"))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.RateLimitWaitSeconds))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RateLimitThrottledTotal.WithLabelValues(ntp.RateLimitScopeServer, "a.example")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RateLimitRejectedTotal.WithLabelValues(ntp.RateLimitScopeServer, "a.example")))
+
+	shared.UpdateRateLimitMetrics()
+	assert.Less(t, testutil.ToFloat64(m.RateLimitTokens.WithLabelValues(ntp.RateLimitScopeServer, "a.example")), 0.5)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.RateLimitPerServerRate))
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+)
+
+// schedule is when and for how long a collector runs
+type schedule struct {
+	interval time.Duration // Between the starts of two runs
+	timeout  time.Duration // Time limit of one run, 0 for none
+	delay    time.Duration // Before the first run
+}
+
+// newSchedule returns the schedule of a collector. The first run is delayed
+// by a random offset of up to jitter, capped to the interval.
+func newSchedule(cfg *config.NTPConfig, collector string) schedule {
+	s := schedule{
+		interval: cfg.CollectorInterval(collector),
+		timeout:  cfg.CollectorTimeout(collector),
+	}
+	if jitter := min(cfg.CollectorJitter, s.interval); jitter > 0 {
+		s.delay = rand.N(jitter)
+	}
+	return s
+}
+
+// Run runs every enabled collector in its own goroutine, each on its own
+// interval (collector_intervals, scrape_interval by default), until ctx is
+// done. Expensive collectors can so run less often than the base metrics,
+// and jittered start offsets keep them from querying the servers at once.
+func (r *Registry) Run(ctx context.Context, cfg *config.NTPConfig) {
+	var wg sync.WaitGroup
+	for _, c := range r.collectors {
+		if !c.Enabled() {
+			continue
+		}
+
+		s := newSchedule(cfg, c.Name())
+		logger.SafeInfo("collector", "Collector scheduled", map[string]interface{}{
+			"collector": c.Name(),
+			"interval":  s.interval.String(),
+			"timeout":   s.timeout.String(),
+			"delay":     s.delay.String(),
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runCollector(ctx, c, s)
+		}()
+	}
+	wg.Wait()
+}
+
+// runCollector runs c on its schedule until ctx is done. A run lasting longer
+// than the interval is an overrun: the runs it overlaps are skipped.
+func (r *Registry) runCollector(ctx context.Context, c Collector, s schedule) {
+	timer := time.NewTimer(s.delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		err := r.collect(ctx, c, s.timeout)
+		r.updateShared()
+		elapsed := time.Since(start)
+		logger.Metric("collection", c.Name(), elapsed, err == nil)
+
+		if r.shared != nil && r.shared.metrics != nil {
+			m := r.shared.metrics
+			m.CollectorLastRunTimestamp.WithLabelValues(c.Name()).Set(float64(time.Now().Unix()))
+			if elapsed > s.interval {
+				m.CollectorOverrunsTotal.WithLabelValues(c.Name()).Inc()
+			}
+		}
+		if elapsed > s.interval {
+			logger.SafeWarn("collector", "Collector run overran its interval", map[string]interface{}{
+				"collector": c.Name(),
+				"interval":  s.interval.String(),
+				"duration":  elapsed.String(),
+			})
+		}
+
+		// The next run starts one interval after this one did, or at once
+		// after an overrun
+		timer.Reset(max(s.interval-elapsed, 0))
+	}
+}
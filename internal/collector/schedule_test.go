@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCollector counts its runs and lasts duration unless its context ends first
+type countingCollector struct {
+	name      string
+	duration  time.Duration
+	runs      atomic.Int32
+	cancelled atomic.Int32
+}
+
+func (c *countingCollector) Collect(ctx context.Context) error {
+	c.runs.Add(1)
+	select {
+	case <-time.After(c.duration):
+		return nil
+	case <-ctx.Done():
+		c.cancelled.Add(1)
+		return ctx.Err()
+	}
+}
+
+func (c *countingCollector) Name() string  { return c.name }
+func (c *countingCollector) Enabled() bool { return true }
+
+func TestNewSchedule(t *testing.T) {
+	cfg := &config.NTPConfig{
+		ScrapeInterval:     30 * time.Second,
+		CollectorIntervals: map[string]time.Duration{"security": 5 * time.Second},
+		CollectorTimeouts:  map[string]time.Duration{"security": 2 * time.Second},
+		CollectorJitter:    time.Minute,
+	}
+
+	for i := 0; i < 20; i++ {
+		s := newSchedule(cfg, "security")
+		assert.Equal(t, 5*time.Second, s.interval)
+		assert.Equal(t, 2*time.Second, s.timeout)
+		assert.GreaterOrEqual(t, s.delay, time.Duration(0))
+		assert.Less(t, s.delay, s.interval, "the start offset is capped to the interval")
+	}
+
+	cfg.CollectorJitter = 0
+	assert.Zero(t, newSchedule(cfg, "base").delay)
+}
+
+func TestRegistry_Run(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.CollectorIntervals = map[string]time.Duration{"base": 20 * time.Millisecond, "security": 50 * time.Millisecond}
+	cfg.NTP.CollectorTimeouts = map[string]time.Duration{"security": 80 * time.Millisecond}
+	m := metrics.NewNTPMetrics()
+
+	registry := NewRegistryWithShared(NewShared(cfg, m))
+	base := &countingCollector{name: "base"}
+	security := &countingCollector{name: "security", duration: time.Hour}
+	registry.Register(base)
+	registry.Register(security)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	registry.Run(ctx, &cfg.NTP)
+
+	// The fast collector is not held back by the slow one
+	assert.GreaterOrEqual(t, base.runs.Load(), int32(8))
+	assert.LessOrEqual(t, security.runs.Load(), int32(5))
+	assert.GreaterOrEqual(t, security.cancelled.Load(), int32(2), "runs are bounded by the collector timeout")
+
+	assert.Zero(t, testutil.ToFloat64(m.CollectorOverrunsTotal.WithLabelValues("base")))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(m.CollectorOverrunsTotal.WithLabelValues("security")), 2.0)
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(m.CollectorLastRunTimestamp.WithLabelValues("base")), 2)
+}
+
+func TestRegistry_RunCancelled(t *testing.T) {
+	cfg := &config.NTPConfig{} // No scrape_interval: the default applies
+	registry := NewRegistry()
+	registry.Register(&countingCollector{name: "base"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		registry.Run(ctx, cfg)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
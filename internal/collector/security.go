@@ -34,13 +34,19 @@ func (c *SecurityCollector) collectFromServer(ctx context.Context, server string
 	client := c.GetClient()
 	m := c.GetMetrics()
 
-	// Query NTP server
-	resp, err := client.Query(ctx, server)
+	// Query the address the base collector reports, with an address family
+	target, err := c.queryTarget(ctx, server)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Query(ctx, target)
 	if err != nil {
 		logger.Error("collector", "Query failed", err)
 		return fmt.Errorf("failed to query NTP server %s for security metrics: %w", server, err)
 	}
 
+	c.filterSamples(server, resp)
+
 	// Validate response
 	validation := c.validator.Validate(resp)
 
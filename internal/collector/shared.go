@@ -0,0 +1,531 @@
+package collector
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/discovery"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/sony/gobreaker"
+)
+
+// Shared holds the NTP client used by every collector of a registry, so that
+// all of them go through the same rate limiters and circuit breakers and the
+// breaker state exported as metrics is the one actually applied
+type Shared struct {
+	cfg       *config.Config
+	client    ntp.NTPQuerier
+	breakers  *ntp.CircuitBreakerClient
+	backoff   *ntp.KoDBackoff
+	limiter   *ntp.RateLimiter
+	dnsCache  *ntp.DNSCache
+	filter    *ntp.ClockFilter
+	scheduler *ntp.PollScheduler
+	overrides *ntp.OverrideStore
+	discovery *discovery.Manager
+	metrics   *metrics.NTPMetrics
+
+	// Servers no longer discovered, forgotten after the current collection
+	removedMu sync.Mutex
+	removed   map[string]bool
+
+	// Override series exported, to delete only those of overrides gone
+	overrideMu     sync.Mutex
+	overrideSeries map[[2]string]bool // {server, mode}
+
+	// Backoff series exported, to delete only those of backoffs ended
+	backoffMu     sync.Mutex
+	backoffSeries map[[2]string]bool // {server, code}
+
+	// Collectors and number of servers the rate limits were last planned for
+	planMu         sync.Mutex
+	planCollectors []string
+	planServers    int
+
+	// DNS cache counters already exported, to add only the increase
+	dnsHits   uint64
+	dnsMisses uint64
+}
+
+// errBreakersDisabled is returned by override operations when circuit breakers are disabled
+var errBreakersDisabled = errors.New("circuit breakers are disabled")
+
+// NewShared creates the shared NTP client from configuration
+func NewShared(cfg *config.Config, m *metrics.NTPMetrics) *Shared {
+	s := &Shared{cfg: cfg, metrics: m, overrides: ntp.NewOverrideStore(), filter: ntp.NewClockFilter()}
+
+	var base *ntp.Client
+	s.client, base, s.breakers = newNTPClient(cfg, s.onBreakerStateChange)
+	s.backoff = base.KoDBackoff()
+	s.limiter = base.RateLimiter()
+
+	s.dnsCache = newDNSCache(cfg)
+	if s.dnsCache != nil {
+		base.SetDNSCache(s.dnsCache)
+	}
+
+	if cfg.NTP.PollScheduling.Enabled {
+		s.scheduler = ntp.NewPollScheduler(cfg.NTP.PollScheduling.MinPoll, cfg.NTP.PollScheduling.MaxPoll)
+	}
+
+	if s.breakers != nil {
+		s.breakers.SetOverrides(s.overrides)
+	}
+	if s.limiter != nil && m != nil {
+		s.limiter.SetObserver(rateLimitMetrics{metrics: m})
+	}
+	return s
+}
+
+// Client returns the shared NTP client
+func (s *Shared) Client() ntp.NTPQuerier {
+	return s.client
+}
+
+// Breakers returns the circuit breaker client, or nil when circuit breakers are disabled
+func (s *Shared) Breakers() *ntp.CircuitBreakerClient {
+	return s.breakers
+}
+
+// DNSCache returns the DNS cache shared by pools and the NTP client, nil when disabled
+func (s *Shared) DNSCache() *ntp.DNSCache {
+	return s.dnsCache
+}
+
+// ClockFilter returns the clock filter registers shared by the collectors
+// using sample_filter: clock_filter, so that every sample of a server goes
+// through the same register
+func (s *Shared) ClockFilter() *ntp.ClockFilter {
+	return s.filter
+}
+
+// PollScheduler returns the per-server poll scheduler, nil when poll scheduling is disabled
+func (s *Shared) PollScheduler() *ntp.PollScheduler {
+	return s.scheduler
+}
+
+// PollTargets returns the names polls are scheduled for: the configured and
+// discovered servers, then the pools
+func (s *Shared) PollTargets() []string {
+	names := append([]string(nil), s.cfg.NTP.Servers...)
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	if s.discovery != nil {
+		for _, server := range s.discovery.Servers() {
+			if !seen[server] {
+				seen[server] = true
+				names = append(names, server)
+			}
+		}
+	}
+	for _, pool := range s.cfg.NTP.Pools {
+		names = append(names, pool.Name)
+	}
+	return names
+}
+
+// SetDiscovery adds the servers of a discovery manager to the collected
+// ones. It must be called before collectors are registered.
+func (s *Shared) SetDiscovery(manager *discovery.Manager) {
+	s.discovery = manager
+	manager.OnRemove(s.onServersRemoved)
+}
+
+// Discovery returns the discovery manager, nil when discovery is disabled
+func (s *Shared) Discovery() *discovery.Manager {
+	return s.discovery
+}
+
+// onServersRemoved queues servers no longer discovered until the end of the
+// current collection
+func (s *Shared) onServersRemoved(servers []string) {
+	s.removedMu.Lock()
+	defer s.removedMu.Unlock()
+
+	if s.removed == nil {
+		s.removed = make(map[string]bool)
+	}
+	for _, server := range servers {
+		s.removed[server] = true
+	}
+}
+
+// hasRemovedServers reports whether removed servers are queued
+func (s *Shared) hasRemovedServers() bool {
+	s.removedMu.Lock()
+	defer s.removedMu.Unlock()
+	return len(s.removed) > 0
+}
+
+// takeRemovedServers returns and clears the queued removed servers, sorted,
+// leaving out the ones configured statically or discovered again since
+func (s *Shared) takeRemovedServers() []string {
+	s.removedMu.Lock()
+	queued := s.removed
+	s.removed = nil
+	s.removedMu.Unlock()
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	for _, server := range s.cfg.NTP.Servers {
+		delete(queued, server)
+	}
+	if s.discovery != nil {
+		for _, server := range s.discovery.Servers() {
+			delete(queued, server)
+		}
+	}
+
+	servers := make([]string, 0, len(queued))
+	for server := range queued {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// forgetServer deletes the series of a server and drops its circuit breaker
+// and rate limiter. A Kiss-of-Death backoff is kept, so that a server asking
+// not to be queried is not queried again if it comes back.
+func (s *Shared) forgetServer(server string) {
+	deleted := 0
+	if s.metrics != nil {
+		deleted = s.metrics.DeleteServer(server)
+	}
+	if s.breakers != nil {
+		s.breakers.Forget(server)
+	}
+	if s.limiter != nil {
+		s.limiter.Forget(server)
+	}
+	s.filter.Forget(server)
+	if s.scheduler != nil {
+		s.scheduler.Forget(server)
+	}
+
+	logger.SafeInfo("collector", "Removed target forgotten", map[string]interface{}{
+		"server": server,
+		"series": deleted,
+	})
+}
+
+// UpdateTargetMetrics exports the discovered targets as target_info
+func (s *Shared) UpdateTargetMetrics() {
+	if s.discovery == nil || s.metrics == nil {
+		return
+	}
+
+	targets := s.discovery.Targets()
+	labels := make([]metrics.TargetLabels, 0, len(targets))
+	for _, t := range targets {
+		labels = append(labels, metrics.TargetLabels{Server: t.Server, Source: t.Source, Labels: t.Labels})
+	}
+	s.metrics.TargetInfo.Set(labels)
+}
+
+// RateLimiter returns the shared rate limiter, or nil when rate limiting is disabled
+func (s *Shared) RateLimiter() *ntp.RateLimiter {
+	return s.limiter
+}
+
+// PlanRateLimit checks the rate limits against the rate the enabled
+// collectors send queries at, after sizing the per-server rate from it when
+// rate_limit.auto_per_server_rate is set. Limits too low for that rate are
+// logged as warnings. The plan is made again when discovery changes the
+// number of servers.
+func (s *Shared) PlanRateLimit(collectors []string) {
+	if s.limiter == nil {
+		return
+	}
+
+	s.planMu.Lock()
+	s.planCollectors = collectors
+	s.planMu.Unlock()
+	s.planRateLimit(s.serverCount())
+}
+
+// replanRateLimit plans the rate limits again when the number of servers
+// changed since the last plan
+func (s *Shared) replanRateLimit() {
+	if s.limiter == nil {
+		return
+	}
+	if servers := s.serverCount(); servers != s.plannedServers() {
+		s.planRateLimit(servers)
+	}
+}
+
+func (s *Shared) plannedServers() int {
+	s.planMu.Lock()
+	defer s.planMu.Unlock()
+	return s.planServers
+}
+
+// serverCount returns the number of configured and discovered servers
+func (s *Shared) serverCount() int {
+	return len(s.PollTargets()) - len(s.cfg.NTP.Pools)
+}
+
+func (s *Shared) planRateLimit(servers int) {
+	s.planMu.Lock()
+	defer s.planMu.Unlock()
+	s.planServers = servers
+
+	rl := s.cfg.NTP.RateLimit
+	budget := NewRateBudget(s.cfg, s.planCollectors, servers)
+
+	if rl.AutoPerServerRate && budget.PerServerRate > 0 {
+		burst := max(rl.BurstSize, budget.PerServerBurst)
+		s.limiter.SetPerServerLimit(budget.PerServerRate, burst)
+		logger.SafeInfo("collector", "Per-server rate limit sized from collector intervals", map[string]interface{}{
+			"rate":       budget.PerServerRate,
+			"burst":      burst,
+			"collectors": s.planCollectors,
+			"servers":    servers,
+		})
+	}
+
+	perServerRate, _ := s.limiter.PerServerLimit()
+	for _, warning := range budget.Warnings(float64(rl.GlobalRate), perServerRate) {
+		logger.Warn("collector", "Rate limit budget too low: "+warning)
+	}
+
+	s.UpdateRateLimitMetrics()
+}
+
+// Snapshot returns the state of every circuit breaker (empty when disabled)
+func (s *Shared) Snapshot() []ntp.BreakerSnapshot {
+	if s.breakers == nil {
+		return []ntp.BreakerSnapshot{}
+	}
+	return s.breakers.Snapshot()
+}
+
+// Reset closes a server's breaker and reports whether it existed
+func (s *Shared) Reset(server string) bool {
+	if s.breakers == nil {
+		return false
+	}
+	defer s.UpdateBreakerMetrics()
+	return s.breakers.Reset(server)
+}
+
+// ResetAll closes every breaker and returns the affected servers
+func (s *Shared) ResetAll() []string {
+	if s.breakers == nil {
+		return []string{}
+	}
+	defer s.UpdateBreakerMetrics()
+	return s.breakers.ResetAll()
+}
+
+// SetOverride installs a manual override for a server
+func (s *Shared) SetOverride(server string, mode ntp.OverrideMode, duration time.Duration, reason string) (ntp.Override, error) {
+	if s.breakers == nil {
+		return ntp.Override{}, errBreakersDisabled
+	}
+
+	o, err := s.overrides.Set(server, mode, duration, reason)
+	if err != nil {
+		return ntp.Override{}, err
+	}
+
+	logger.SafeWarn("collector", "Circuit breaker override set", map[string]interface{}{
+		"server":   o.Server,
+		"mode":     string(o.Mode),
+		"reason":   o.Reason,
+		"duration": duration.String(),
+	})
+	s.UpdateBreakerMetrics()
+	return o, nil
+}
+
+// ClearOverride removes a server's manual override and reports whether one existed
+func (s *Shared) ClearOverride(server string) bool {
+	cleared := s.overrides.Clear(server)
+	if cleared {
+		logger.SafeInfo("collector", "Circuit breaker override cleared", map[string]interface{}{
+			"server": server,
+		})
+	}
+	s.UpdateBreakerMetrics()
+	return cleared
+}
+
+// Overrides returns the active manual overrides
+func (s *Shared) Overrides() []ntp.Override {
+	return s.overrides.List()
+}
+
+// updateOverrideMetrics exports the active overrides. Series are deleted one
+// by one rather than reset, so that a concurrent scrape always sees the
+// overrides still in force.
+func (s *Shared) updateOverrideMetrics() {
+	s.overrideMu.Lock()
+	defer s.overrideMu.Unlock()
+
+	active := make(map[[2]string]bool)
+	for _, o := range s.overrides.List() {
+		series := [2]string{o.Server, string(o.Mode)}
+		active[series] = true
+		s.metrics.CircuitBreakerOverride.WithLabelValues(series[:]...).Set(1)
+	}
+	for series := range s.overrideSeries {
+		if !active[series] {
+			s.metrics.CircuitBreakerOverride.DeleteLabelValues(series[:]...)
+		}
+	}
+	s.overrideSeries = active
+}
+
+// onBreakerStateChange counts transitions and updates the state gauge immediately
+func (s *Shared) onBreakerStateChange(server string, from, to gobreaker.State) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.CircuitBreakerTransitionsTotal.WithLabelValues(server, from.String(), to.String()).Inc()
+	s.metrics.CircuitBreakerState.WithLabelValues(server).Set(float64(to))
+}
+
+// UpdateBreakerMetrics refreshes the circuit breaker state and count gauges
+func (s *Shared) UpdateBreakerMetrics() {
+	if s.breakers == nil || s.metrics == nil {
+		return
+	}
+
+	s.updateOverrideMetrics()
+
+	for _, snap := range s.breakers.Snapshot() {
+		s.metrics.CircuitBreakerState.WithLabelValues(snap.Server).Set(breakerStateValue(snap.State))
+		s.metrics.CircuitBreakerRequests.WithLabelValues(snap.Server).Set(float64(snap.Requests))
+		s.metrics.CircuitBreakerSuccesses.WithLabelValues(snap.Server).Set(float64(snap.TotalSuccesses))
+		s.metrics.CircuitBreakerFailures.WithLabelValues(snap.Server).Set(float64(snap.TotalFailures))
+		s.metrics.CircuitBreakerConsecutiveFailures.WithLabelValues(snap.Server).Set(float64(snap.ConsecutiveFailures))
+	}
+}
+
+// UpdateBackoffMetrics refreshes the remaining Kiss-of-Death backoff per
+// server. As for overrides, only the series of backoffs ended are deleted.
+func (s *Shared) UpdateBackoffMetrics() {
+	if s.backoff == nil || s.metrics == nil {
+		return
+	}
+
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	now := time.Now()
+	active := make(map[[2]string]bool)
+	for _, state := range s.backoff.Snapshot() {
+		series := [2]string{state.Server, state.Code}
+		active[series] = true
+		s.metrics.ServerBackoffSeconds.WithLabelValues(series[:]...).Set(state.Remaining(now).Seconds())
+	}
+	for series := range s.backoffSeries {
+		if !active[series] {
+			s.metrics.ServerBackoffSeconds.DeleteLabelValues(series[:]...)
+		}
+	}
+	s.backoffSeries = active
+}
+
+// UpdateRateLimitMetrics refreshes the rate limiter token and rate gauges
+func (s *Shared) UpdateRateLimitMetrics() {
+	if s.limiter == nil || s.metrics == nil {
+		return
+	}
+
+	global, perServer := s.limiter.Tokens()
+	s.metrics.RateLimitTokens.WithLabelValues(ntp.RateLimitScopeGlobal, "").Set(global)
+	for server, tokens := range perServer {
+		s.metrics.RateLimitTokens.WithLabelValues(ntp.RateLimitScopeServer, server).Set(tokens)
+	}
+
+	perServerRate, _ := s.limiter.PerServerLimit()
+	s.metrics.RateLimitPerServerRate.Set(perServerRate)
+}
+
+// UpdateDNSMetrics exports the DNS cache statistics
+func (s *Shared) UpdateDNSMetrics() {
+	if s.dnsCache == nil || s.metrics == nil {
+		return
+	}
+
+	stats := s.dnsCache.Stats()
+	if hits, ok := stats["hits"].(uint64); ok && hits >= s.dnsHits {
+		s.metrics.DNSCacheHitsTotal.Add(float64(hits - s.dnsHits))
+		s.dnsHits = hits
+	}
+	if misses, ok := stats["misses"].(uint64); ok && misses >= s.dnsMisses {
+		s.metrics.DNSCacheMissesTotal.Add(float64(misses - s.dnsMisses))
+		s.dnsMisses = misses
+	}
+	if valid, ok := stats["valid_entries"].(int); ok {
+		s.metrics.DNSCacheEntries.WithLabelValues("valid").Set(float64(valid))
+	}
+	if expired, ok := stats["expired_entries"].(int); ok {
+		s.metrics.DNSCacheEntries.WithLabelValues("expired").Set(float64(expired))
+	}
+}
+
+// newDNSCache creates the DNS cache from configuration, resolving through the
+// configured upstream servers or the system resolver; nil when disabled
+func newDNSCache(cfg *config.Config) *ntp.DNSCache {
+	if !cfg.NTP.DNSCache.Enabled {
+		return nil
+	}
+
+	cacheConfig := ntp.DNSCacheConfig{
+		MinTTL: cfg.NTP.DNSCache.MinTTL,
+		MaxTTL: cfg.NTP.DNSCache.MaxTTL,
+	}
+
+	if len(cfg.NTP.DNS.Servers) > 0 {
+		upstreams := make([]ntp.DNSUpstream, 0, len(cfg.NTP.DNS.Servers))
+		for _, server := range cfg.NTP.DNS.Servers {
+			upstream, err := ntp.ParseDNSUpstream(server)
+			if err != nil {
+				logger.SafeWarn("collector", "Ignoring invalid DNS server", map[string]interface{}{
+					"server": server,
+					"error":  err.Error(),
+				})
+				continue
+			}
+			upstreams = append(upstreams, upstream)
+		}
+
+		resolver, err := ntp.NewUpstreamResolver(ntp.UpstreamResolverConfig{
+			Servers:       upstreams,
+			Timeout:       cfg.NTP.DNS.Timeout,
+			TLSServerName: cfg.NTP.DNS.TLSServerName,
+		})
+		if err != nil {
+			logger.Error("collector", "Falling back to the system DNS resolver", err)
+		} else {
+			cacheConfig.Resolver = resolver
+		}
+	}
+
+	return ntp.NewDNSCache(cacheConfig)
+}
+
+// breakerStateValue maps a state name to the circuit_breaker_state gauge value
+func breakerStateValue(state string) float64 {
+	switch state {
+	case gobreaker.StateHalfOpen.String():
+		return float64(gobreaker.StateHalfOpen)
+	case gobreaker.StateOpen.String():
+		return float64(gobreaker.StateOpen)
+	default:
+		return float64(gobreaker.StateClosed)
+	}
+}
@@ -0,0 +1,372 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/discovery"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShared(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := metrics.NewNTPMetrics()
+
+	t.Run("circuit_breaker_enabled", func(t *testing.T) {
+		cfg.NTP.CircuitBreaker.Enabled = true
+		shared := NewShared(cfg, m)
+		require.NotNil(t, shared.Breakers())
+		assert.Same(t, shared.Breakers(), shared.Client())
+	})
+
+	t.Run("circuit_breaker_disabled", func(t *testing.T) {
+		cfg.NTP.CircuitBreaker.Enabled = false
+		shared := NewShared(cfg, m)
+		assert.Nil(t, shared.Breakers())
+		assert.NotNil(t, shared.Client())
+		assert.NotPanics(t, shared.UpdateBreakerMetrics)
+	})
+}
+
+func TestShared_BreakerMetrics(t *testing.T) {
+	m := metrics.NewNTPMetrics()
+	mock := ntp.NewMockNTPClient()
+	mock.SetupSuccessfulServer("good.example", time.Millisecond, 2)
+	mock.SetupUnreachableServer("bad.example")
+
+	shared := &Shared{metrics: m, overrides: ntp.NewOverrideStore()}
+	shared.breakers = ntp.NewCircuitBreakerClient(mock, ntp.CircuitBreakerConfig{
+		MaxRequests:   1,
+		Interval:      time.Minute,
+		Timeout:       time.Minute,
+		ReadyToTrip:   func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 2 },
+		OnStateChange: shared.onBreakerStateChange,
+	})
+	shared.client = shared.breakers
+
+	ctx := context.Background()
+	_, err := shared.Client().Query(ctx, "good.example")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _ = shared.Client().Query(ctx, "bad.example")
+	}
+
+	shared.UpdateBreakerMetrics()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CircuitBreakerTransitionsTotal.WithLabelValues("bad.example", "closed", "open")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.CircuitBreakerState.WithLabelValues("bad.example")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.CircuitBreakerState.WithLabelValues("good.example")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CircuitBreakerSuccesses.WithLabelValues("good.example")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.CircuitBreakerFailures.WithLabelValues("bad.example")), "counts reset when the breaker opens")
+}
+
+func TestRegistryWithShared_InjectsClient(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := metrics.NewNTPMetrics()
+	shared := NewShared(cfg, m)
+
+	r := NewRegistryWithShared(shared)
+	base := NewBaseCollector(cfg, m)
+	quality := NewQualityCollector(cfg, m)
+	r.Register(base)
+	r.Register(quality)
+	r.Register(&mockCollector{name: "mock", enabled: true})
+
+	assert.Same(t, shared.Client(), base.GetClient())
+	assert.Same(t, shared.Client(), quality.GetClient())
+	assert.Equal(t, 3, r.Count())
+}
+
+func TestShared_Overrides(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.CircuitBreaker.Enabled = true
+	m := metrics.NewNTPMetrics()
+
+	shared := NewShared(cfg, m)
+	_, err := shared.SetOverride("stratum1.internal", ntp.OverrideOpen, time.Hour, "maintenance")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CircuitBreakerOverride.WithLabelValues("stratum1.internal", "open")))
+
+	_, err = shared.Client().Query(context.Background(), "stratum1.internal")
+	assert.ErrorIs(t, err, ntp.ErrForcedOpen)
+
+	// Changing or clearing one override leaves the series of the others
+	_, err = shared.SetOverride("stratum2.internal", ntp.OverrideClosed, time.Hour, "pinned")
+	require.NoError(t, err)
+	_, err = shared.SetOverride("stratum1.internal", ntp.OverrideClosed, time.Hour, "back")
+	require.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.CircuitBreakerOverride))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CircuitBreakerOverride.WithLabelValues("stratum1.internal", "closed")))
+
+	assert.True(t, shared.ClearOverride("stratum1.internal"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.CircuitBreakerOverride))
+	assert.True(t, shared.ClearOverride("stratum2.internal"))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.CircuitBreakerOverride))
+}
+
+func TestShared_OverridesWithBreakersDisabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.CircuitBreaker.Enabled = false
+
+	shared := NewShared(cfg, metrics.NewNTPMetrics())
+	_, err := shared.SetOverride("a.example", ntp.OverrideOpen, 0, "")
+	assert.Error(t, err)
+	assert.Empty(t, shared.ResetAll())
+	assert.Empty(t, shared.Snapshot())
+}
+
+func TestShared_BackoffMetrics(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := metrics.NewNTPMetrics()
+	shared := NewShared(cfg, m)
+	require.NotNil(t, shared.backoff)
+
+	shared.backoff.Record("pool.example", ntp.KissCodeRate)
+	shared.backoff.Record("private.example", ntp.KissCodeDeny)
+	shared.UpdateBackoffMetrics()
+
+	rate := testutil.ToFloat64(m.ServerBackoffSeconds.WithLabelValues("pool.example", "RATE"))
+	assert.InDelta(t, cfg.NTP.RateLimit.BackoffDuration.Seconds(), rate, 1)
+	deny := testutil.ToFloat64(m.ServerBackoffSeconds.WithLabelValues("private.example", "DENY"))
+	assert.InDelta(t, cfg.NTP.RateLimit.KoDSuspend.Seconds(), deny, 1)
+
+	// A new code replaces the series of the previous one
+	shared.backoff.Record("pool.example", ntp.KissCodeDeny)
+	shared.UpdateBackoffMetrics()
+	assert.Equal(t, 2, testutil.CollectAndCount(m.ServerBackoffSeconds))
+	assert.False(t, m.ServerBackoffSeconds.DeleteLabelValues("pool.example", "RATE"))
+
+	shared.backoff.RecordSuccess("pool.example")
+	shared.backoff.RecordSuccess("private.example")
+	shared.UpdateBackoffMetrics()
+	assert.Equal(t, 0, testutil.CollectAndCount(m.ServerBackoffSeconds))
+}
+
+// staticResolver answers every lookup with the same address
+type staticResolver struct{}
+
+func (staticResolver) Lookup(ctx context.Context, hostname string) (ntp.DNSAnswer, error) {
+	return ntp.DNSAnswer{IPs: []string{"192.0.2.1"}, TTL: time.Hour}, nil
+}
+
+func TestShared_DNSCache(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		cfg.NTP.DNSCache.Enabled = false
+		assert.Nil(t, NewShared(cfg, metrics.NewNTPMetrics()).DNSCache())
+	})
+
+	t.Run("upstream servers", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		cfg.NTP.DNS.Servers = []string{"tls://192.0.2.53"}
+		assert.NotNil(t, NewShared(cfg, metrics.NewNTPMetrics()).DNSCache())
+	})
+
+	t.Run("injected into collectors", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		shared := NewShared(cfg, metrics.NewNTPMetrics())
+		require.NotNil(t, shared.DNSCache())
+
+		base := NewBaseCollector(cfg, metrics.NewNTPMetrics())
+		NewRegistryWithShared(shared).Register(base)
+		assert.Same(t, shared.DNSCache(), base.GetDNSCache())
+	})
+}
+
+func TestShared_DNSMetrics(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := metrics.NewNTPMetrics()
+	shared := NewShared(cfg, m)
+	shared.dnsCache = ntp.NewDNSCache(ntp.DNSCacheConfig{Resolver: staticResolver{}})
+
+	for i := 0; i < 3; i++ {
+		_, err := shared.dnsCache.Resolve(context.Background(), "pool.example")
+		require.NoError(t, err)
+	}
+	shared.UpdateDNSMetrics()
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.DNSCacheHitsTotal))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DNSCacheMissesTotal))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DNSCacheEntries.WithLabelValues("valid")))
+
+	// Counters only grow by what happened since the previous update
+	_, err := shared.dnsCache.Resolve(context.Background(), "pool.example")
+	require.NoError(t, err)
+	shared.UpdateDNSMetrics()
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.DNSCacheHitsTotal))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DNSCacheMissesTotal))
+}
+
+func TestShared_Discovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	writeTargets := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	writeTargets(`[{"targets": ["static.example", "gone.example", "kept.example"], "labels": {"site": "par1"}}]`)
+
+	cfg := config.DefaultConfig()
+	cfg.NTP.Servers = []string{"static.example"}
+	cfg.NTP.Pools = nil
+	m := metrics.NewNTPMetrics()
+	manager := discovery.NewManager(discovery.NewFileProvider(path, 0))
+	manager.Refresh(context.Background())
+
+	shared := NewShared(cfg, m)
+	shared.SetDiscovery(manager)
+
+	mock := ntp.NewMockNTPClient()
+	for _, server := range []string{"static.example", "gone.example", "kept.example"} {
+		mock.SetupSuccessfulServer(server, time.Millisecond, 2)
+	}
+
+	r := NewRegistryWithShared(shared)
+	base := NewBaseCollector(cfg, m)
+	r.Register(base)
+	base.SetClient(mock)
+	assert.Equal(t, []string{"static.example", "gone.example", "kept.example"}, base.Servers(), "configured servers come first, without duplicates")
+
+	require.NoError(t, r.CollectAll(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ServerReachable.WithLabelValues("gone.example")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.TargetInfo))
+
+	// The removed target's series go away after the next collection; a
+	// configured server dropped from the file keeps its series
+	writeTargets(`[{"targets": ["kept.example"]}]`)
+	manager.Refresh(context.Background())
+	assert.Equal(t, []string{"static.example", "kept.example"}, base.Servers())
+
+	require.NoError(t, r.CollectAll(context.Background()))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.ServerReachable))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ServerReachable.WithLabelValues("static.example")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.RTTSeconds), "gone.example series are deleted")
+	assert.Equal(t, 1, testutil.CollectAndCount(m.TargetInfo))
+}
+
+func TestRegistry_CollectDue(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.Servers = []string{"stable.example", "down.example"}
+	cfg.NTP.PollScheduling = config.PollSchedulingConfig{Enabled: true, MinPoll: 4, MaxPoll: 6}
+	m := metrics.NewNTPMetrics()
+
+	mock := ntp.NewMockNTPClient()
+	mock.SetupSuccessfulServer("stable.example", time.Millisecond, 2)
+	mock.SetupUnreachableServer("down.example")
+
+	shared := NewShared(cfg, m)
+	registry := NewRegistryWithShared(shared)
+	base := NewBaseCollector(cfg, m)
+	registry.Register(base)
+	base.SetClient(mock)
+
+	// Every server is due at first
+	now := time.Now()
+	next, err := registry.CollectDue(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mock.GetCallCount("stable.example"))
+	assert.Equal(t, 1, mock.GetCallCount("down.example"))
+	assert.WithinDuration(t, now.Add(16*time.Second), next, 5*time.Second)
+	assert.Equal(t, 16.0, testutil.ToFloat64(m.ScheduledPollSeconds.WithLabelValues("stable.example")))
+
+	// Nothing is due before the minimum poll interval
+	_, err = registry.CollectDue(context.Background(), now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, mock.GetCallCount("stable.example"))
+
+	// The stable server backs off while the unreachable one stays at min poll
+	for i := 1; i <= 8; i++ {
+		_, err = registry.CollectDue(context.Background(), now.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 64*time.Second, shared.PollScheduler().Interval("stable.example"))
+	assert.Equal(t, 16*time.Second, shared.PollScheduler().Interval("down.example"))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.HoldoverActive), "the stable server still answers")
+
+	// Collecting a subset leaves the collector collecting everything afterwards
+	assert.Len(t, base.Servers(), 2)
+}
+
+// hookCollector records its lifecycle hooks and fails with err
+type hookCollector struct {
+	mockCollector
+	initialized bool
+	closed      bool
+}
+
+func (c *hookCollector) Init(ctx context.Context) error {
+	c.initialized = true
+	return c.err
+}
+
+func (c *hookCollector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRegistry_CollectAllConcurrent(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NTP.CollectorTimeouts = map[string]time.Duration{"quality": 50 * time.Millisecond}
+	m := metrics.NewNTPMetrics()
+
+	registry := NewRegistryWithShared(NewShared(cfg, m))
+	registry.Register(&countingCollector{name: "base", duration: 20 * time.Millisecond})
+	registry.Register(&countingCollector{name: "quality", duration: time.Hour})
+	registry.Register(&mockCollector{name: "security", enabled: true, err: errors.New("boom")})
+
+	start := time.Now()
+	err := registry.CollectAll(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "collectors run concurrently and the slow one is cut by its timeout")
+
+	// Every error is returned
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "quality")
+	assert.Contains(t, err.Error(), "security: boom")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CollectorUp.WithLabelValues("base")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.CollectorUp.WithLabelValues("quality")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.CollectorUp.WithLabelValues("security")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CollectorErrorsTotal.WithLabelValues("quality", "timeout")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CollectorErrorsTotal.WithLabelValues("security", "error")))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(m.CollectorLastSuccess.WithLabelValues("base")), 2)
+	assert.Zero(t, testutil.ToFloat64(m.CollectorLastSuccess.WithLabelValues("security")))
+}
+
+func TestRegistry_Lifecycle(t *testing.T) {
+	ok := &hookCollector{mockCollector: mockCollector{name: "ok", enabled: true}}
+	failing := &hookCollector{mockCollector: mockCollector{name: "failing", enabled: true, err: errors.New("no kernel access")}}
+	disabled := &hookCollector{mockCollector: mockCollector{name: "disabled"}}
+
+	cfg := config.DefaultConfig()
+	cfg.NTP.EnableKernel = true
+	hybrid := NewHybridCollector(cfg, metrics.NewNTPMetrics())
+
+	registry := NewRegistry()
+	for _, c := range []Collector{ok, failing, disabled, hybrid} {
+		registry.Register(c)
+	}
+
+	err := registry.Init(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failing: no kernel access")
+	assert.True(t, ok.initialized)
+	assert.False(t, disabled.initialized, "disabled collectors are not initialized")
+
+	// Close stops the clock watcher started by Init
+	require.NoError(t, registry.Close())
+	assert.True(t, ok.closed)
+	assert.True(t, failing.closed)
+	select {
+	case <-hybrid.watchDone:
+	default:
+		t.Fatal("the clock watcher is still running")
+	}
+}
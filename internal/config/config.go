@@ -12,22 +12,43 @@
 //                                               Use: Kubernetes (ConfigMap + env vars)
 //                                               Priority: Env Vars > YAML > Defaults
 //
-// Environment variables supported:
+//   LoadFromRequiredYamlWithEnvOverrides(path) - Same, but a missing or unparsable file
+//                                               is fatal instead of ignored
+//
+//   LoadWithProvenance(path)                  - Same layering, unvalidated, with the source of
+//                                               each value (used by `ntp-exporter config`)
+//
+// YAML files are decoded strictly: unknown keys (typos) are errors reported
+// as file:line:column.
+//
+// Environment variables supported (bound through the `env` struct tags; any
+// variable can also be read from a file with a _FILE suffix, e.g.
+// METRICS_LABELS_FILE=/run/secrets/labels; malformed values are errors):
 //
 //   SERVER:
 //     - NTP_EXPORTER_ADDRESS, NTP_EXPORTER_PORT
 //     - SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT
 //     - TLS_ENABLED, TLS_CERT_FILE, TLS_KEY_FILE
 //     - ENABLE_CORS, ALLOWED_ORIGINS (comma-separated)
+//     - ADMIN_ENABLED, ADMIN_TOKEN (usually ADMIN_TOKEN_FILE)
 //
 //   NTP:
 //     - NTP_SERVERS (comma-separated), NTP_TIMEOUT, NTP_VERSION
-//     - NTP_SAMPLES, NTP_MAX_CONCURRENCY, NTP_ENABLE_KERNEL
-//     - NTP_SCRAPE_INTERVAL, NTP_MAX_CLOCK_OFFSET
+//     - NTP_SAMPLES, NTP_MAX_CONCURRENCY, NTP_ENABLE_KERNEL, NTP_PROC_ROOT, NTP_SYS_ROOT
+//     - NTP_SCRAPE_INTERVAL, NTP_MAX_CLOCK_OFFSET, NTP_OFFSET_BOUNDS_CHECK
+//     - NTP_COLLECTION_MODE (background|on_scrape), NTP_COLLECTION_MIN_INTERVAL
+//     - NTP_ADDRESS_FAMILY, NTP_ADDRESS_FAMILIES (server=family,...)
+//     - NTP_SAMPLE_FILTER, NTP_SAMPLE_FILTERS (collector=filter,...)
+//     - NTP_COLLECTOR_INTERVALS, NTP_COLLECTOR_TIMEOUTS (collector=duration,...)
+//     - NTP_COLLECTOR_JITTER
+//     - NTP_POOLS_<i>_NAME, NTP_POOLS_<i>_STRATEGY, NTP_POOLS_<i>_MAX_SERVERS,
+//       NTP_POOLS_<i>_FALLBACK (i starts at 0)
 //
 //   RATE_LIMIT:
 //     - RATE_LIMIT_ENABLED, RATE_LIMIT_GLOBAL, RATE_LIMIT_PER_SERVER
 //     - RATE_LIMIT_BURST_SIZE, RATE_LIMIT_BACKOFF_DURATION
+//     - RATE_LIMIT_MAX_BACKOFF, RATE_LIMIT_KOD_SUSPEND
+//     - RATE_LIMIT_AUTO_PER_SERVER_RATE
 //
 //   CIRCUIT_BREAKER:
 //     - CIRCUIT_BREAKER_ENABLED, CIRCUIT_BREAKER_MAX_REQUESTS
@@ -42,21 +63,34 @@
 //   WORKER_POOL:
 //     - WORKER_POOL_ENABLED, WORKER_POOL_SIZE
 //
+//   POLL_SCHEDULING:
+//     - POLL_SCHEDULING_ENABLED, POLL_SCHEDULING_MIN_POLL, POLL_SCHEDULING_MAX_POLL
+//
 //   DNS_CACHE:
 //     - DNS_CACHE_ENABLED, DNS_CACHE_MIN_TTL, DNS_CACHE_MAX_TTL
 //     - DNS_CACHE_CLEANUP_WORKERS
 //
+//   DNS:
+//     - DNS_SERVERS (comma-separated), DNS_TIMEOUT, DNS_TLS_SERVER_NAME
+//
+//   DISCOVERY:
+//     - NTP_DISCOVERY_HOST_CONFIG, NTP_DISCOVERY_HOST_ROOT
+//     - NTP_DISCOVERY_SRV (comma-separated), NTP_DISCOVERY_FILES (comma-separated)
+//     - NTP_DISCOVERY_REFRESH_INTERVAL
+//
 //   LOGGING:
 //     - LOG_LEVEL (trace|debug|info|warn|error|fatal|panic)
+//     - LOG_FORMAT (json|console), LOG_OUTPUT (stdout|stderr)
 //     - LOG_ENABLE_FILE, LOG_FILE_PATH
-//     - Note: LOG_FORMAT is NOT supported (JSON only)
 //
 //   METRICS:
 //     - METRICS_NAMESPACE, METRICS_SUBSYSTEM
+//     - METRICS_LABELS (key=value,key2=value2)
 //
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -76,113 +110,268 @@ type Config struct {
 
 // ServerConfig contains HTTP server configuration
 type ServerConfig struct {
-	Address        string        `yaml:"address"`
-	Port           int           `yaml:"port"`
-	ReadTimeout    time.Duration `yaml:"read_timeout"`
-	WriteTimeout   time.Duration `yaml:"write_timeout"`
-	EnableCORS     bool          `yaml:"enable_cors"`
-	AllowedOrigins []string      `yaml:"allowed_origins"`
-	TLSEnabled     bool          `yaml:"tls_enabled"`
-	TLSCertFile    string        `yaml:"tls_cert_file"`
-	TLSKeyFile     string        `yaml:"tls_key_file"`
+	Address        string        `yaml:"address" env:"NTP_EXPORTER_ADDRESS"`
+	Port           int           `yaml:"port" env:"NTP_EXPORTER_PORT"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	EnableCORS     bool          `yaml:"enable_cors" env:"ENABLE_CORS"`
+	AllowedOrigins []string      `yaml:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	TLSEnabled     bool          `yaml:"tls_enabled" env:"TLS_ENABLED"`
+	TLSCertFile    string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile     string        `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	Admin          AdminConfig   `yaml:"admin"`
+}
+
+// AdminConfig contains the authenticated admin API configuration
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled" env:"ADMIN_ENABLED"`
+	Token   string `yaml:"token" env:"ADMIN_TOKEN" secret:"true"` // Bearer token required by /api/v1/admin/*
 }
 
 // NTPConfig contains NTP client configuration
 type NTPConfig struct {
-	Servers          []string               `yaml:"servers"`
-	Pools            []PoolConfig           `yaml:"pools"`
-	Timeout          time.Duration          `yaml:"timeout"`
-	Version          int                    `yaml:"version"`
-	SamplesPerServer int                    `yaml:"samples_per_server"`
-	MaxConcurrency   int                    `yaml:"max_concurrency"`
-	EnableKernel     bool                   `yaml:"enable_kernel"`
-	ScrapeInterval   time.Duration          `yaml:"scrape_interval"`    // Interval between NTP collections
-	MaxClockOffset   time.Duration          `yaml:"max_clock_offset"`   // Maximum acceptable clock offset threshold
-	RateLimit        RateLimitConfig        `yaml:"rate_limit"`
-	CircuitBreaker   CircuitBreakerConfig   `yaml:"circuit_breaker"`
-	AdaptiveSampling AdaptiveSamplingConfig `yaml:"adaptive_sampling"`
-	WorkerPool       WorkerPoolConfig       `yaml:"worker_pool"`
-	DNSCache         DNSCacheConfig         `yaml:"dns_cache"`
+	Servers               []string                 `yaml:"servers" env:"NTP_SERVERS"`
+	Pools                 []PoolConfig             `yaml:"pools" env:"NTP_POOLS"`
+	Timeout               time.Duration            `yaml:"timeout" env:"NTP_TIMEOUT"`
+	Version               int                      `yaml:"version" env:"NTP_VERSION"`
+	SamplesPerServer      int                      `yaml:"samples_per_server" env:"NTP_SAMPLES"`
+	MaxConcurrency        int                      `yaml:"max_concurrency" env:"NTP_MAX_CONCURRENCY"`
+	EnableKernel          bool                     `yaml:"enable_kernel" env:"NTP_ENABLE_KERNEL"`
+	ProcRoot              string                   `yaml:"proc_root" env:"NTP_PROC_ROOT"`                             // proc filesystem scanned for time daemons, the host's /proc in a container
+	SysRoot               string                   `yaml:"sys_root" env:"NTP_SYS_ROOT"`                               // sysfs root the clocksource is read from
+	ScrapeInterval        time.Duration            `yaml:"scrape_interval" env:"NTP_SCRAPE_INTERVAL"`                 // Interval between NTP collections
+	CollectionMode        string                   `yaml:"collection_mode" env:"NTP_COLLECTION_MODE"`                 // background or on_scrape; empty is background
+	CollectionMinInterval time.Duration            `yaml:"collection_min_interval" env:"NTP_COLLECTION_MIN_INTERVAL"` // on_scrape: results are reused for scrapes within this interval
+	MaxClockOffset        time.Duration            `yaml:"max_clock_offset" env:"NTP_MAX_CLOCK_OFFSET"`               // Maximum acceptable clock offset threshold
+	OffsetBoundsCheck     bool                     `yaml:"offset_bounds_check" env:"NTP_OFFSET_BOUNDS_CHECK"`         // Exceeded only when the whole offset interval is outside max_clock_offset
+	RateLimit             RateLimitConfig          `yaml:"rate_limit"`
+	CircuitBreaker        CircuitBreakerConfig     `yaml:"circuit_breaker"`
+	AdaptiveSampling      AdaptiveSamplingConfig   `yaml:"adaptive_sampling"`
+	WorkerPool            WorkerPoolConfig         `yaml:"worker_pool"`
+	PollScheduling        PollSchedulingConfig     `yaml:"poll_scheduling"`
+	DNSCache              DNSCacheConfig           `yaml:"dns_cache"`
+	DNS                   DNSConfig                `yaml:"dns"`
+	AddressFamily         string                   `yaml:"address_family" env:"NTP_ADDRESS_FAMILY"`           // ipv4, ipv6, both or all_addresses; empty lets the NTP library pick one address
+	AddressFamilies       map[string]string        `yaml:"address_families" env:"NTP_ADDRESS_FAMILIES"`       // Per-server address_family overrides
+	SampleFilter          string                   `yaml:"sample_filter" env:"NTP_SAMPLE_FILTER"`             // median or clock_filter; empty is median
+	SampleFilters         map[string]string        `yaml:"sample_filters" env:"NTP_SAMPLE_FILTERS"`           // Per-collector sample_filter overrides
+	CollectorIntervals    map[string]time.Duration `yaml:"collector_intervals" env:"NTP_COLLECTOR_INTERVALS"` // Per-collector scrape_interval overrides
+	CollectorTimeouts     map[string]time.Duration `yaml:"collector_timeouts" env:"NTP_COLLECTOR_TIMEOUTS"`   // Per-collector time limit of one run; none by default
+	CollectorJitter       time.Duration            `yaml:"collector_jitter" env:"NTP_COLLECTOR_JITTER"`       // Maximum random delay of the first run of each collector
+	Discovery             DiscoveryConfig          `yaml:"discovery"`
+}
+
+// Collection modes
+const (
+	CollectionModeBackground = "background" // Collectors run on their own intervals
+	CollectionModeOnScrape   = "on_scrape"  // A scrape of /metrics runs the collectors
+)
+
+// AddressFamilyFor returns the address family mode of a server
+func (c *NTPConfig) AddressFamilyFor(server string) string {
+	if family, ok := c.AddressFamilies[server]; ok {
+		return family
+	}
+	return c.AddressFamily
+}
+
+// Sample filters
+const (
+	SampleFilterMedian      = "median"       // Median and mean over the samples of a cycle
+	SampleFilterClockFilter = "clock_filter" // RFC 5905 clock filter across cycles
+)
+
+// SampleFilterFor returns the sample filter of a collector
+func (c *NTPConfig) SampleFilterFor(collector string) string {
+	filter, ok := c.SampleFilters[collector]
+	if !ok {
+		filter = c.SampleFilter
+	}
+	if filter == "" {
+		return SampleFilterMedian
+	}
+	return filter
+}
+
+// CollectorInterval returns the collection interval of a collector, the
+// default scrape interval when none is set
+func (c *NTPConfig) CollectorInterval(collector string) time.Duration {
+	if interval, ok := c.CollectorIntervals[collector]; ok && interval > 0 {
+		return interval
+	}
+	if c.ScrapeInterval > 0 {
+		return c.ScrapeInterval
+	}
+	return DefaultScrapeInterval
+}
+
+// CollectorTimeout returns the time limit of one run of a collector, 0 for none
+func (c *NTPConfig) CollectorTimeout(collector string) time.Duration {
+	return c.CollectorTimeouts[collector]
 }
 
 // PoolConfig represents NTP pool configuration
 type PoolConfig struct {
-	Name       string `yaml:"name"`
-	Strategy   string `yaml:"strategy"`
-	MaxServers int    `yaml:"max_servers"`
-	Fallback   string `yaml:"fallback"`
+	Name       string `yaml:"name" env:"NAME"`
+	Strategy   string `yaml:"strategy" env:"STRATEGY"`
+	MaxServers int    `yaml:"max_servers" env:"MAX_SERVERS"`
+	Fallback   string `yaml:"fallback" env:"FALLBACK"`
 }
 
 // RateLimitConfig contains rate limiting configuration
 type RateLimitConfig struct {
-	Enabled         bool          `yaml:"enabled"`
-	GlobalRate      int           `yaml:"global_rate"`
-	PerServerRate   int           `yaml:"per_server_rate"`
-	BurstSize       int           `yaml:"burst_size"`
-	BackoffDuration time.Duration `yaml:"backoff_duration"`
+	Enabled         bool          `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	GlobalRate      int           `yaml:"global_rate" env:"RATE_LIMIT_GLOBAL"`
+	PerServerRate   int           `yaml:"per_server_rate" env:"RATE_LIMIT_PER_SERVER"`
+	BurstSize       int           `yaml:"burst_size" env:"RATE_LIMIT_BURST_SIZE"`
+	BackoffDuration time.Duration `yaml:"backoff_duration" env:"RATE_LIMIT_BACKOFF_DURATION"` // First backoff after a RATE Kiss-of-Death, doubled on each repeat
+	MaxBackoff      time.Duration `yaml:"max_backoff" env:"RATE_LIMIT_MAX_BACKOFF"`           // Cap of the RATE backoff
+	KoDSuspend      time.Duration `yaml:"kod_suspend" env:"RATE_LIMIT_KOD_SUSPEND"`           // Suspension after a DENY or RSTR Kiss-of-Death
+
+	// AutoPerServerRate sizes the per-server rate from the queries the
+	// collectors send to a server over their intervals instead of using
+	// PerServerRate
+	AutoPerServerRate bool `yaml:"auto_per_server_rate" env:"RATE_LIMIT_AUTO_PER_SERVER_RATE"`
 }
 
 // CircuitBreakerConfig contains circuit breaker configuration
 type CircuitBreakerConfig struct {
-	Enabled          bool          `yaml:"enabled"`
-	MaxRequests      uint32        `yaml:"max_requests"`
-	Interval         time.Duration `yaml:"interval"`
-	Timeout          time.Duration `yaml:"timeout"`
-	FailureThreshold float64       `yaml:"failure_threshold"`
+	Enabled          bool          `yaml:"enabled" env:"CIRCUIT_BREAKER_ENABLED"`
+	MaxRequests      uint32        `yaml:"max_requests" env:"CIRCUIT_BREAKER_MAX_REQUESTS"`
+	Interval         time.Duration `yaml:"interval" env:"CIRCUIT_BREAKER_INTERVAL"`
+	Timeout          time.Duration `yaml:"timeout" env:"CIRCUIT_BREAKER_TIMEOUT"`
+	FailureThreshold float64       `yaml:"failure_threshold" env:"CIRCUIT_BREAKER_FAILURE_THRESHOLD"`
 }
 
 // AdaptiveSamplingConfig contains adaptive sampling configuration
 type AdaptiveSamplingConfig struct {
-	Enabled          bool          `yaml:"enabled"`
-	DefaultSamples   int           `yaml:"default_samples"`
-	HighDriftSamples int           `yaml:"high_drift_samples"`
-	DriftThreshold   time.Duration `yaml:"drift_threshold"`
-	MaxDuration      time.Duration `yaml:"max_duration"`
+	Enabled          bool          `yaml:"enabled" env:"ADAPTIVE_SAMPLING_ENABLED"`
+	DefaultSamples   int           `yaml:"default_samples" env:"ADAPTIVE_SAMPLING_DEFAULT_SAMPLES"`
+	HighDriftSamples int           `yaml:"high_drift_samples" env:"ADAPTIVE_SAMPLING_HIGH_DRIFT_SAMPLES"`
+	DriftThreshold   time.Duration `yaml:"drift_threshold" env:"ADAPTIVE_SAMPLING_DRIFT_THRESHOLD"`
+	MaxDuration      time.Duration `yaml:"max_duration" env:"ADAPTIVE_SAMPLING_MAX_DURATION"`
 }
 
 // WorkerPoolConfig contains worker pool configuration
 type WorkerPoolConfig struct {
-	Enabled bool `yaml:"enabled"`
-	Size    int  `yaml:"size"`
+	Enabled bool `yaml:"enabled" env:"WORKER_POOL_ENABLED"`
+	Size    int  `yaml:"size" env:"WORKER_POOL_SIZE"`
+}
+
+// PollSchedulingConfig contains per-server poll scheduling configuration.
+// Poll intervals are powers of two in seconds, given by their exponent.
+type PollSchedulingConfig struct {
+	Enabled bool `yaml:"enabled" env:"POLL_SCHEDULING_ENABLED"`
+	MinPoll int  `yaml:"min_poll" env:"POLL_SCHEDULING_MIN_POLL"` // Shortest interval, 2^min_poll seconds
+	MaxPoll int  `yaml:"max_poll" env:"POLL_SCHEDULING_MAX_POLL"` // Longest interval, 2^max_poll seconds
 }
 
 // DNSCacheConfig contains DNS cache configuration
 type DNSCacheConfig struct {
-	Enabled        bool          `yaml:"enabled"`
-	MinTTL         time.Duration `yaml:"min_ttl"`
-	MaxTTL         time.Duration `yaml:"max_ttl"`
-	CleanupWorkers int           `yaml:"cleanup_workers"`
+	Enabled        bool          `yaml:"enabled" env:"DNS_CACHE_ENABLED"`
+	MinTTL         time.Duration `yaml:"min_ttl" env:"DNS_CACHE_MIN_TTL"`
+	MaxTTL         time.Duration `yaml:"max_ttl" env:"DNS_CACHE_MAX_TTL"`
+	CleanupWorkers int           `yaml:"cleanup_workers" env:"DNS_CACHE_CLEANUP_WORKERS"`
+}
+
+// DNSConfig selects the resolver used for pools and server hostnames
+type DNSConfig struct {
+	// Servers are upstream DNS servers tried in order: "host[:port]" or
+	// "udp://host[:port]", "tcp://host[:port]", "tls://host[:port]" for
+	// DNS-over-TLS, or an https:// DNS-over-HTTPS URL. Empty uses the system
+	// resolver, which does not report record TTLs.
+	Servers       []string      `yaml:"servers" env:"DNS_SERVERS"`
+	Timeout       time.Duration `yaml:"timeout" env:"DNS_TIMEOUT"`                 // Per upstream server
+	TLSServerName string        `yaml:"tls_server_name" env:"DNS_TLS_SERVER_NAME"` // DNS-over-TLS certificate name, default the server host
+}
+
+// DiscoveryConfig adds servers discovered at runtime to the static servers
+type DiscoveryConfig struct {
+	HostConfig      bool          `yaml:"host_config" env:"NTP_DISCOVERY_HOST_CONFIG"`           // Servers of the host's chrony, ntpd or timesyncd configuration
+	HostRoot        string        `yaml:"host_root" env:"NTP_DISCOVERY_HOST_ROOT"`               // Where the host filesystem is mounted, "/" by default
+	SRV             []string      `yaml:"srv" env:"NTP_DISCOVERY_SRV"`                           // SRV record names, e.g. _ntp._udp.example.com
+	Files           []string      `yaml:"files" env:"NTP_DISCOVERY_FILES"`                       // file_sd JSON or YAML target files, reloaded on change
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"NTP_DISCOVERY_REFRESH_INTERVAL"` // SRV lookup and target file reload interval
+}
+
+// Enabled reports whether any discovery provider is configured
+func (c *DiscoveryConfig) Enabled() bool {
+	return c.HostConfig || len(c.SRV) > 0 || len(c.Files) > 0
 }
 
 // LoggingConfig contains logging configuration
 type LoggingConfig struct {
-	Level      string `yaml:"level"`
-	Format     string `yaml:"format"`
-	Output     string `yaml:"output"`
-	EnableFile bool   `yaml:"enable_file"`
-	FilePath   string `yaml:"file_path"`
+	Level      string `yaml:"level" env:"LOG_LEVEL"`
+	Format     string `yaml:"format" env:"LOG_FORMAT"`
+	Output     string `yaml:"output" env:"LOG_OUTPUT"`
+	EnableFile bool   `yaml:"enable_file" env:"LOG_ENABLE_FILE"`
+	FilePath   string `yaml:"file_path" env:"LOG_FILE_PATH"`
 }
 
 // MetricsConfig contains Prometheus metrics configuration
 type MetricsConfig struct {
-	Namespace string            `yaml:"namespace"`
-	Subsystem string            `yaml:"subsystem"`
-	Labels    map[string]string `yaml:"labels"`
+	Namespace string            `yaml:"namespace" env:"METRICS_NAMESPACE"`
+	Subsystem string            `yaml:"subsystem" env:"METRICS_SUBSYSTEM"`
+	Labels    map[string]string `yaml:"labels" env:"METRICS_LABELS"`
 }
 
-// LoadFromYamlFile reads configuration from a YAML file only (no env var overrides)
-// Use case: Local development, testing
-func LoadFromYamlFile(path string) (*Config, error) {
+// ParseError is a YAML decoding error located in the configuration file
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+	Err    error
+}
+
+// Error formats the error as file:line:column: message
+func (e *ParseError) Error() string {
+	return e.File + ":" + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column) + ": " + e.Msg
+}
+
+// Unwrap returns the underlying YAML error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// decodeYAML strictly decodes a configuration file: unknown or duplicated keys
+// are rejected, and errors carry the file position when the parser knows it
+func decodeYAML(path string, data []byte, cfg *Config) error {
+	err := yaml.UnmarshalWithOptions(data, cfg, yaml.DisallowUnknownField())
+	if err == nil {
+		return nil
+	}
+
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) && yamlErr.GetToken() != nil {
+		pos := yamlErr.GetToken().Position
+		return &ParseError{File: path, Line: pos.Line, Column: pos.Column, Msg: yamlErr.GetMessage(), Err: err}
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// readYamlFile reads and strictly decodes a configuration file, without defaults
+func readYamlFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		logger.Error("config", "Failed to read config file", err)
 		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
 	}
 
 	cfg := &Config{}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		logger.Error("config", "Failed to parse config file", err)
-		return nil, fmt.Errorf("failed to parse YAML config file %s: %w", path, err)
+	if err := decodeYAML(path, data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML config file: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// LoadFromYamlFile reads configuration from a YAML file only (no env var overrides)
+// Use case: Local development, testing
+func LoadFromYamlFile(path string) (*Config, error) {
+	cfg, err := readYamlFile(path)
+	if err != nil {
+		logger.Error("config", "Failed to load config file", err)
+		return nil, err
 	}
 
 	// Apply defaults
@@ -200,18 +389,44 @@ func LoadFromYamlFile(path string) (*Config, error) {
 // LoadFromYamlWithEnvOverrides loads base config from YAML, then overrides with environment variables
 // Use case: Kubernetes with ConfigMaps + env vars, Docker with config file + env vars
 // Priority: Environment Variables > YAML File > Defaults
+// A missing or unparsable file is logged and ignored (env vars and defaults only),
+// use LoadFromRequiredYamlWithEnvOverrides to make it fatal. The merged
+// configuration is validated once, so a file may rely on env vars to be valid.
 func LoadFromYamlWithEnvOverrides(path string) (*Config, error) {
-	// First, try to load from YAML file
-	cfg, err := LoadFromYamlFile(path)
+	return loadYamlWithEnvOverrides(path, false)
+}
+
+// LoadFromRequiredYamlWithEnvOverrides behaves like LoadFromYamlWithEnvOverrides
+// but fails when the file is missing, unreadable or unparsable instead of falling
+// back to env vars and defaults
+func LoadFromRequiredYamlWithEnvOverrides(path string) (*Config, error) {
+	return loadYamlWithEnvOverrides(path, true)
+}
+
+// loadYamlWithEnvOverrides implements the YAML + env loaders
+func loadYamlWithEnvOverrides(path string, required bool) (*Config, error) {
+	// First, try to read the YAML file; it is validated only once the env
+	// overrides apply, as a value may come from either
+	cfg, err := readYamlFile(path)
 	if err != nil {
-		logger.Warn("config", "Failed to load YAML config file, falling back to env vars only")
+		if required {
+			logger.Error("config", "Failed to load config file", err)
+			return nil, err
+		}
+		logger.SafeWarn("config", "Failed to load YAML config file, falling back to env vars only", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
 		// If file doesn't exist, start from defaults
 		cfg = &Config{}
-		ApplyDefaults(cfg)
 	}
+	ApplyDefaults(cfg)
 
 	// Override with environment variables
-	applyEnvOverrides(cfg)
+	if err := applyEnvOverrides(cfg, nil); err != nil {
+		logger.Error("config", "Invalid environment variables", err)
+		return nil, fmt.Errorf("invalid environment variables: %w", err)
+	}
 
 	// Validate final configuration
 	if err := Validate(cfg); err != nil {
@@ -222,242 +437,6 @@ func LoadFromYamlWithEnvOverrides(path string) (*Config, error) {
 	return cfg, nil
 }
 
-// applyEnvOverrides applies environment variable overrides to an existing config
-func applyEnvOverrides(cfg *Config) {
-	// ---------------------------------------------------------------------------
-	// SERVER - HTTP Server configuration
-	// ---------------------------------------------------------------------------
-	if addr := os.Getenv("NTP_EXPORTER_ADDRESS"); addr != "" {
-		cfg.Server.Address = addr
-	}
-	if port := os.Getenv("NTP_EXPORTER_PORT"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			cfg.Server.Port = p
-		}
-	}
-	if readTimeout := os.Getenv("SERVER_READ_TIMEOUT"); readTimeout != "" {
-		if t, err := time.ParseDuration(readTimeout); err == nil {
-			cfg.Server.ReadTimeout = t
-		}
-	}
-	if writeTimeout := os.Getenv("SERVER_WRITE_TIMEOUT"); writeTimeout != "" {
-		if t, err := time.ParseDuration(writeTimeout); err == nil {
-			cfg.Server.WriteTimeout = t
-		}
-	}
-	if tlsEnabled := os.Getenv("TLS_ENABLED"); tlsEnabled != "" {
-		if b, err := strconv.ParseBool(tlsEnabled); err == nil {
-			cfg.Server.TLSEnabled = b
-		}
-	}
-	if tlsCert := os.Getenv("TLS_CERT_FILE"); tlsCert != "" {
-		cfg.Server.TLSCertFile = tlsCert
-	}
-	if tlsKey := os.Getenv("TLS_KEY_FILE"); tlsKey != "" {
-		cfg.Server.TLSKeyFile = tlsKey
-	}
-	if enableCORS := os.Getenv("ENABLE_CORS"); enableCORS != "" {
-		if b, err := strconv.ParseBool(enableCORS); err == nil {
-			cfg.Server.EnableCORS = b
-		}
-	}
-	if allowedOrigins := os.Getenv("ALLOWED_ORIGINS"); allowedOrigins != "" {
-		cfg.Server.AllowedOrigins = parseCommaSeparated(allowedOrigins)
-	}
-
-	// ---------------------------------------------------------------------------
-	// NTP - NTP client configuration
-	// ---------------------------------------------------------------------------
-	if servers := os.Getenv("NTP_SERVERS"); servers != "" {
-		cfg.NTP.Servers = parseCommaSeparated(servers)
-	}
-	if enableKernel := os.Getenv("NTP_ENABLE_KERNEL"); enableKernel != "" {
-		if k, err := strconv.ParseBool(enableKernel); err == nil {
-			cfg.NTP.EnableKernel = k
-		}
-	}
-	if timeout := os.Getenv("NTP_TIMEOUT"); timeout != "" {
-		if t, err := time.ParseDuration(timeout); err == nil {
-			cfg.NTP.Timeout = t
-		}
-	}
-	if version := os.Getenv("NTP_VERSION"); version != "" {
-		if v, err := strconv.Atoi(version); err == nil {
-			cfg.NTP.Version = v
-		}
-	}
-	if samples := os.Getenv("NTP_SAMPLES"); samples != "" {
-		if s, err := strconv.Atoi(samples); err == nil {
-			cfg.NTP.SamplesPerServer = s
-		}
-	}
-	if maxConcurrency := os.Getenv("NTP_MAX_CONCURRENCY"); maxConcurrency != "" {
-		if c, err := strconv.Atoi(maxConcurrency); err == nil {
-			cfg.NTP.MaxConcurrency = c
-		}
-	}
-	if scrapeInterval := os.Getenv("NTP_SCRAPE_INTERVAL"); scrapeInterval != "" {
-		if s, err := time.ParseDuration(scrapeInterval); err == nil {
-			cfg.NTP.ScrapeInterval = s
-		}
-	}
-	if maxClockOffset := os.Getenv("NTP_MAX_CLOCK_OFFSET"); maxClockOffset != "" {
-		if m, err := time.ParseDuration(maxClockOffset); err == nil {
-			cfg.NTP.MaxClockOffset = m
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// RATE LIMIT - Rate limiting configuration
-	// ---------------------------------------------------------------------------
-	if rateLimitEnabled := os.Getenv("RATE_LIMIT_ENABLED"); rateLimitEnabled != "" {
-		if b, err := strconv.ParseBool(rateLimitEnabled); err == nil {
-			cfg.NTP.RateLimit.Enabled = b
-		}
-	}
-	if globalRate := os.Getenv("RATE_LIMIT_GLOBAL"); globalRate != "" {
-		if r, err := strconv.Atoi(globalRate); err == nil {
-			cfg.NTP.RateLimit.GlobalRate = r
-		}
-	}
-	if perServerRate := os.Getenv("RATE_LIMIT_PER_SERVER"); perServerRate != "" {
-		if r, err := strconv.Atoi(perServerRate); err == nil {
-			cfg.NTP.RateLimit.PerServerRate = r
-		}
-	}
-	if burstSize := os.Getenv("RATE_LIMIT_BURST_SIZE"); burstSize != "" {
-		if b, err := strconv.Atoi(burstSize); err == nil {
-			cfg.NTP.RateLimit.BurstSize = b
-		}
-	}
-	if backoffDuration := os.Getenv("RATE_LIMIT_BACKOFF_DURATION"); backoffDuration != "" {
-		if d, err := time.ParseDuration(backoffDuration); err == nil {
-			cfg.NTP.RateLimit.BackoffDuration = d
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// CIRCUIT BREAKER - Circuit breaker configuration
-	// ---------------------------------------------------------------------------
-	if cbEnabled := os.Getenv("CIRCUIT_BREAKER_ENABLED"); cbEnabled != "" {
-		if b, err := strconv.ParseBool(cbEnabled); err == nil {
-			cfg.NTP.CircuitBreaker.Enabled = b
-		}
-	}
-	if maxRequests := os.Getenv("CIRCUIT_BREAKER_MAX_REQUESTS"); maxRequests != "" {
-		if r, err := strconv.ParseUint(maxRequests, 10, 32); err == nil {
-			cfg.NTP.CircuitBreaker.MaxRequests = uint32(r)
-		}
-	}
-	if cbInterval := os.Getenv("CIRCUIT_BREAKER_INTERVAL"); cbInterval != "" {
-		if i, err := time.ParseDuration(cbInterval); err == nil {
-			cfg.NTP.CircuitBreaker.Interval = i
-		}
-	}
-	if cbTimeout := os.Getenv("CIRCUIT_BREAKER_TIMEOUT"); cbTimeout != "" {
-		if t, err := time.ParseDuration(cbTimeout); err == nil {
-			cfg.NTP.CircuitBreaker.Timeout = t
-		}
-	}
-	if failureThreshold := os.Getenv("CIRCUIT_BREAKER_FAILURE_THRESHOLD"); failureThreshold != "" {
-		if f, err := strconv.ParseFloat(failureThreshold, 64); err == nil {
-			cfg.NTP.CircuitBreaker.FailureThreshold = f
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// ADAPTIVE SAMPLING - Adaptive sampling configuration
-	// ---------------------------------------------------------------------------
-	if asEnabled := os.Getenv("ADAPTIVE_SAMPLING_ENABLED"); asEnabled != "" {
-		if b, err := strconv.ParseBool(asEnabled); err == nil {
-			cfg.NTP.AdaptiveSampling.Enabled = b
-		}
-	}
-	if defaultSamples := os.Getenv("ADAPTIVE_SAMPLING_DEFAULT_SAMPLES"); defaultSamples != "" {
-		if s, err := strconv.Atoi(defaultSamples); err == nil {
-			cfg.NTP.AdaptiveSampling.DefaultSamples = s
-		}
-	}
-	if highDriftSamples := os.Getenv("ADAPTIVE_SAMPLING_HIGH_DRIFT_SAMPLES"); highDriftSamples != "" {
-		if s, err := strconv.Atoi(highDriftSamples); err == nil {
-			cfg.NTP.AdaptiveSampling.HighDriftSamples = s
-		}
-	}
-	if driftThreshold := os.Getenv("ADAPTIVE_SAMPLING_DRIFT_THRESHOLD"); driftThreshold != "" {
-		if d, err := time.ParseDuration(driftThreshold); err == nil {
-			cfg.NTP.AdaptiveSampling.DriftThreshold = d
-		}
-	}
-	if maxDuration := os.Getenv("ADAPTIVE_SAMPLING_MAX_DURATION"); maxDuration != "" {
-		if d, err := time.ParseDuration(maxDuration); err == nil {
-			cfg.NTP.AdaptiveSampling.MaxDuration = d
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// WORKER POOL - Worker pool configuration
-	// ---------------------------------------------------------------------------
-	if wpEnabled := os.Getenv("WORKER_POOL_ENABLED"); wpEnabled != "" {
-		if b, err := strconv.ParseBool(wpEnabled); err == nil {
-			cfg.NTP.WorkerPool.Enabled = b
-		}
-	}
-	if wpSize := os.Getenv("WORKER_POOL_SIZE"); wpSize != "" {
-		if s, err := strconv.Atoi(wpSize); err == nil {
-			cfg.NTP.WorkerPool.Size = s
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// DNS CACHE - DNS cache configuration
-	// ---------------------------------------------------------------------------
-	if dnsCacheEnabled := os.Getenv("DNS_CACHE_ENABLED"); dnsCacheEnabled != "" {
-		if b, err := strconv.ParseBool(dnsCacheEnabled); err == nil {
-			cfg.NTP.DNSCache.Enabled = b
-		}
-	}
-	if minTTL := os.Getenv("DNS_CACHE_MIN_TTL"); minTTL != "" {
-		if t, err := time.ParseDuration(minTTL); err == nil {
-			cfg.NTP.DNSCache.MinTTL = t
-		}
-	}
-	if maxTTL := os.Getenv("DNS_CACHE_MAX_TTL"); maxTTL != "" {
-		if t, err := time.ParseDuration(maxTTL); err == nil {
-			cfg.NTP.DNSCache.MaxTTL = t
-		}
-	}
-	if cleanupWorkers := os.Getenv("DNS_CACHE_CLEANUP_WORKERS"); cleanupWorkers != "" {
-		if w, err := strconv.Atoi(cleanupWorkers); err == nil {
-			cfg.NTP.DNSCache.CleanupWorkers = w
-		}
-	}
-
-	// ---------------------------------------------------------------------------
-	// LOGGING - Logging configuration
-	// ---------------------------------------------------------------------------
-	if level := os.Getenv("LOG_LEVEL"); level != "" {
-		cfg.Logging.Level = level
-	}
-	if enableFile := os.Getenv("LOG_ENABLE_FILE"); enableFile != "" {
-		if b, err := strconv.ParseBool(enableFile); err == nil {
-			cfg.Logging.EnableFile = b
-		}
-	}
-	if filePath := os.Getenv("LOG_FILE_PATH"); filePath != "" {
-		cfg.Logging.FilePath = filePath
-	}
-
-	// ---------------------------------------------------------------------------
-	// METRICS - Prometheus metrics configuration
-	// ---------------------------------------------------------------------------
-	if namespace := os.Getenv("METRICS_NAMESPACE"); namespace != "" {
-		cfg.Metrics.Namespace = namespace
-	}
-	if subsystem := os.Getenv("METRICS_SUBSYSTEM"); subsystem != "" {
-		cfg.Metrics.Subsystem = subsystem
-	}
-}
-
 // LoadFromEnvVarsOnly loads configuration from environment variables only (no YAML file)
 // Use case: Docker containers, Kubernetes pods without ConfigMaps
 // Priority: Environment Variables > Defaults
@@ -466,7 +445,10 @@ func LoadFromEnvVarsOnly() (*Config, error) {
 	ApplyDefaults(cfg)
 
 	// Apply environment variable overrides
-	applyEnvOverrides(cfg)
+	if err := applyEnvOverrides(cfg, nil); err != nil {
+		logger.Error("config", "Invalid environment variables", err)
+		return nil, fmt.Errorf("invalid environment variables: %w", err)
+	}
 
 	if err := Validate(cfg); err != nil {
 		logger.Error("config", "Invalid configuration from environment", err)
@@ -78,6 +78,121 @@ func TestLoadFromYamlFile_InvalidYAML(t *testing.T) {
 	}
 }
 
+func TestLoadFromYamlFile_UnknownField(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "typo.yaml")
+
+	content := "ntp:\n  servers:\n    - pool.ntp.org\n  sample_per_server: 5\n"
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+
+	cfg, err := LoadFromYamlFile(configFile)
+
+	require.Error(t, err)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), configFile+":4:3:")
+	assert.Contains(t, err.Error(), `unknown field "sample_per_server"`)
+
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 4, parseErr.Line)
+	assert.Equal(t, 3, parseErr.Column)
+}
+
+func TestLoadFromYamlFile_TypeErrorPosition(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "type.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("server:\n  port: abc\n"), 0644))
+
+	_, err := LoadFromYamlFile(configFile)
+
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, configFile, parseErr.File)
+	assert.Equal(t, 2, parseErr.Line)
+}
+
+func TestLoadFromYamlWithEnvOverrides_FallbackOnInvalidFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "typo.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("circuit_breakr:\n  enabled: true\n"), 0644))
+
+	cfg, err := LoadFromYamlWithEnvOverrides(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConfig().NTP.Servers, cfg.NTP.Servers)
+}
+
+func TestLoadFromRequiredYamlWithEnvOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("missing_file", func(t *testing.T) {
+		cfg, err := LoadFromRequiredYamlWithEnvOverrides(filepath.Join(tmpDir, "missing.yaml"))
+		assert.Error(t, err)
+		assert.Nil(t, cfg)
+	})
+
+	t.Run("invalid_file", func(t *testing.T) {
+		configFile := filepath.Join(tmpDir, "typo.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte("circuit_breakr:\n  enabled: true\n"), 0644))
+
+		_, err := LoadFromRequiredYamlWithEnvOverrides(configFile)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown field "circuit_breakr"`)
+	})
+
+	t.Run("valid_file", func(t *testing.T) {
+		configFile := filepath.Join(tmpDir, "ok.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte("ntp:\n  servers:\n    - time.google.com\n"), 0644))
+
+		cfg, err := LoadFromRequiredYamlWithEnvOverrides(configFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"time.google.com"}, cfg.NTP.Servers)
+	})
+}
+
+func TestLoadFromYamlWithEnvOverrides_ValidatedAfterEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "admin.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("server:\n  admin:\n    enabled: true\nntp:\n  servers:\n    - time.google.com\n"), 0644))
+
+	// The file alone lacks the admin token
+	_, err := LoadFromYamlFile(configFile)
+	require.Error(t, err)
+
+	tokenFile := filepath.Join(tmpDir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("0123456789abcdef"), 0600))
+	t.Setenv("ADMIN_TOKEN_FILE", tokenFile)
+
+	for name, load := range map[string]func(string) (*Config, error){
+		"optional": LoadFromYamlWithEnvOverrides,
+		"required": LoadFromRequiredYamlWithEnvOverrides,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := load(configFile)
+			require.NoError(t, err)
+			assert.True(t, cfg.Server.Admin.Enabled)
+			assert.Equal(t, "0123456789abcdef", cfg.Server.Admin.Token)
+			assert.Equal(t, []string{"time.google.com"}, cfg.NTP.Servers)
+		})
+	}
+}
+
+func TestLoadFromYamlWithEnvOverrides_InvalidAfterEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "port.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("server:\n  port: 99999\n"), 0644))
+
+	// A file that parses is not dropped for failing validation
+	_, err := LoadFromYamlWithEnvOverrides(configFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "configuration validation failed")
+}
+
+// TestLoadFromYamlFile_ExampleConfig keeps the shipped example in sync with strict decoding
+func TestLoadFromYamlFile_ExampleConfig(t *testing.T) {
+	_, err := LoadFromYamlFile("../../deployments/docker/config-example.yaml")
+	assert.NoError(t, err)
+}
+
 func TestLoadFromYamlFile_InvalidConfig(t *testing.T) {
 	tmpDir := t.TempDir()
 	configFile := filepath.Join(tmpDir, "invalid.yaml")
@@ -2,6 +2,10 @@ package config
 
 import "time"
 
+// DefaultScrapeInterval is the interval between collections when
+// scrape_interval is not set
+const DefaultScrapeInterval = 30 * time.Second
+
 // ApplyDefaults sets default values for unspecified configuration fields
 func ApplyDefaults(cfg *Config) {
 	// Server defaults
@@ -23,7 +27,7 @@ func ApplyDefaults(cfg *Config) {
 	}
 
 	// NTP defaults
-	if len(cfg.NTP.Servers) == 0 && len(cfg.NTP.Pools) == 0 {
+	if len(cfg.NTP.Servers) == 0 && len(cfg.NTP.Pools) == 0 && !cfg.NTP.Discovery.Enabled() {
 		cfg.NTP.Servers = []string{
 			"pool.ntp.org",
 			"time.google.com",
@@ -41,8 +45,20 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.NTP.MaxConcurrency == 0 {
 		cfg.NTP.MaxConcurrency = 10
 	}
+	if cfg.NTP.ProcRoot == "" {
+		cfg.NTP.ProcRoot = "/proc"
+	}
+	if cfg.NTP.SysRoot == "" {
+		cfg.NTP.SysRoot = "/sys"
+	}
 	if cfg.NTP.ScrapeInterval == 0 {
-		cfg.NTP.ScrapeInterval = 30 * time.Second
+		cfg.NTP.ScrapeInterval = DefaultScrapeInterval
+	}
+	if cfg.NTP.CollectionMode == "" {
+		cfg.NTP.CollectionMode = CollectionModeBackground
+	}
+	if cfg.NTP.CollectionMinInterval == 0 {
+		cfg.NTP.CollectionMinInterval = 10 * time.Second
 	}
 	if cfg.NTP.MaxClockOffset == 0 {
 		cfg.NTP.MaxClockOffset = 100 * time.Millisecond
@@ -61,6 +77,12 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.NTP.RateLimit.BackoffDuration == 0 {
 		cfg.NTP.RateLimit.BackoffDuration = 1 * time.Minute
 	}
+	if cfg.NTP.RateLimit.MaxBackoff == 0 {
+		cfg.NTP.RateLimit.MaxBackoff = 1 * time.Hour
+	}
+	if cfg.NTP.RateLimit.KoDSuspend == 0 {
+		cfg.NTP.RateLimit.KoDSuspend = 24 * time.Hour
+	}
 
 	// Circuit breaker defaults (enabled by default for fault tolerance)
 	cfg.NTP.CircuitBreaker.Enabled = true // Always enabled
@@ -96,6 +118,14 @@ func ApplyDefaults(cfg *Config) {
 		cfg.NTP.WorkerPool.Size = 5
 	}
 
+	// Poll scheduling defaults (disabled by default), ntpd's 64s to 1024s
+	if cfg.NTP.PollScheduling.MinPoll == 0 {
+		cfg.NTP.PollScheduling.MinPoll = 6
+	}
+	if cfg.NTP.PollScheduling.MaxPoll == 0 {
+		cfg.NTP.PollScheduling.MaxPoll = 10
+	}
+
 	// DNS cache defaults (enabled by default for performance)
 	cfg.NTP.DNSCache.Enabled = true // Always enabled
 	if cfg.NTP.DNSCache.MinTTL == 0 {
@@ -108,6 +138,19 @@ func ApplyDefaults(cfg *Config) {
 		cfg.NTP.DNSCache.CleanupWorkers = 1
 	}
 
+	// DNS resolver defaults (system resolver unless servers are configured)
+	if cfg.NTP.DNS.Timeout == 0 {
+		cfg.NTP.DNS.Timeout = 5 * time.Second
+	}
+
+	// Discovery defaults (no provider unless host_config, srv or files are configured)
+	if cfg.NTP.Discovery.RefreshInterval == 0 {
+		cfg.NTP.Discovery.RefreshInterval = 5 * time.Minute
+	}
+	if cfg.NTP.Discovery.HostRoot == "" {
+		cfg.NTP.Discovery.HostRoot = "/"
+	}
+
 	// Logging defaults
 	if cfg.Logging.Level == "" {
 		cfg.Logging.Level = "info"
@@ -32,6 +32,8 @@ func TestApplyDefaults_EmptyConfig(t *testing.T) {
 	assert.Equal(t, 60, cfg.NTP.RateLimit.PerServerRate)
 	assert.Equal(t, 10, cfg.NTP.RateLimit.BurstSize)
 	assert.Equal(t, 1*time.Minute, cfg.NTP.RateLimit.BackoffDuration)
+	assert.Equal(t, 1*time.Hour, cfg.NTP.RateLimit.MaxBackoff)
+	assert.Equal(t, 24*time.Hour, cfg.NTP.RateLimit.KoDSuspend)
 
 	// Logging defaults
 	assert.Equal(t, "info", cfg.Logging.Level)
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileEnvSuffix is appended to a variable name to read its value from a file
+// (e.g. TLS_KEY_FILE_FILE or METRICS_LABELS_FILE for mounted secrets)
+const fileEnvSuffix = "_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnvOverrides applies environment variable overrides to an existing config.
+// Variable names come from the `env` struct tags; lists of structs are indexed
+// (NTP_POOLS_0_NAME), other lists are comma-separated and maps are written as
+// key=value pairs. Every malformed variable is reported in the returned error.
+// When prov is non-nil, each overridden field is recorded with its variable name.
+func applyEnvOverrides(cfg *Config, prov Provenance) error {
+	b := &envBinder{prov: prov}
+	b.bindStruct(reflect.ValueOf(cfg).Elem(), "", "")
+	return errors.Join(b.errs...)
+}
+
+// envBinder walks a configuration struct and collects binding errors
+type envBinder struct {
+	prov Provenance
+	errs []error
+}
+
+// bindStruct binds every tagged field of v. prefix is prepended to variable
+// names inside indexed lists, path is the YAML path of v.
+func (b *envBinder) bindStruct(v reflect.Value, prefix, path string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := yamlName(sf)
+		if name == "" {
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		field := v.Field(i)
+		envName := sf.Tag.Get("env")
+
+		switch {
+		case envName == "" && field.Kind() == reflect.Struct:
+			b.bindStruct(field, prefix, fieldPath)
+		case envName == "":
+			continue
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			b.bindStructSlice(field, prefix+envName, fieldPath)
+		default:
+			b.bindField(field, prefix+envName, fieldPath)
+		}
+	}
+}
+
+// bindStructSlice binds NAME_<index>_<FIELD> variables, growing the list when
+// the environment references indexes beyond its current length. New indexes
+// must follow the list without gaps, which also bounds the growth to the
+// number of variables.
+func (b *envBinder) bindStructSlice(v reflect.Value, envName, path string) {
+	n := v.Len()
+	for _, idx := range envIndexes(envName + "_") {
+		if idx < n {
+			continue
+		}
+		if idx > n {
+			b.errs = append(b.errs, fmt.Errorf("%s_%d_*: index %d is missing, indexes must follow on from 0", envName, idx, n))
+			break
+		}
+		n++
+	}
+	if n > v.Len() {
+		grown := reflect.MakeSlice(v.Type(), n, n)
+		reflect.Copy(grown, v)
+		v.Set(grown)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		idx := strconv.Itoa(i)
+		b.bindStruct(v.Index(i), envName+"_"+idx+"_", path+"["+idx+"]")
+	}
+}
+
+// bindField sets a single field from its variable, if present
+func (b *envBinder) bindField(field reflect.Value, envName, path string) {
+	raw, source, ok, err := lookupEnv(envName)
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if err := setFromString(field, raw); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("%s: %w", source, err))
+		return
+	}
+	b.prov.recordEnv(path, source)
+}
+
+// lookupEnv returns the value of name, or the content of the file named by
+// name_FILE. Empty variables are treated as unset. source is the variable
+// that provided the value.
+func lookupEnv(name string) (value, source string, ok bool, err error) {
+	value = os.Getenv(name)
+	filePath := os.Getenv(name + fileEnvSuffix)
+
+	switch {
+	case value != "" && filePath != "":
+		return "", "", false, fmt.Errorf("%s and %s%s are mutually exclusive", name, name, fileEnvSuffix)
+	case filePath != "":
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", "", false, fmt.Errorf("%s%s: %w", name, fileEnvSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), name + fileEnvSuffix, true, nil
+	case value != "":
+		return value, name, true, nil
+	default:
+		return "", "", false, nil
+	}
+}
+
+// envIndexes returns the distinct indexes used by variables named
+// prefix<index>_..., in increasing order. An index too large for an int
+// counts as math.MaxInt.
+func envIndexes(prefix string) []int {
+	var indexes []int
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		rest := kv[len(prefix):]
+		end := strings.IndexByte(rest, '_')
+		if end <= 0 || strings.Trim(rest[:end], "0123456789") != "" {
+			continue
+		}
+		idx, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			idx = math.MaxInt
+		}
+		if !slices.Contains(indexes, idx) {
+			indexes = append(indexes, idx)
+		}
+	}
+	slices.Sort(indexes)
+	return indexes
+}
+
+// setFromString parses raw according to the field type
+func setFromString(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean: %w", err)
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer: %w", err)
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer: %w", err)
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number: %w", err)
+		}
+		field.SetFloat(v)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		field.Set(reflect.ValueOf(parseCommaSeparated(raw)))
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", field.Type())
+		}
+		pairs, err := parseKeyValuePairs(raw)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(field.Type(), len(pairs))
+		for key, value := range pairs {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setFromString(elem, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(field.Type().Key()), elem)
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// parseKeyValuePairs parses "key=value,key2=value2" into a map
+func parseKeyValuePairs(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, item := range parseCommaSeparated(s) {
+		key, value, found := strings.Cut(item, "=")
+		key = trim(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", item)
+		}
+		result[key] = trim(value)
+	}
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvOverrides_AllTypes(t *testing.T) {
+	t.Setenv("NTP_EXPORTER_PORT", "8080")
+	t.Setenv("NTP_TIMEOUT", "3s")
+	t.Setenv("NTP_ENABLE_KERNEL", "true")
+	t.Setenv("CIRCUIT_BREAKER_MAX_REQUESTS", "7")
+	t.Setenv("CIRCUIT_BREAKER_FAILURE_THRESHOLD", "0.25")
+	t.Setenv("ALLOWED_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("LOG_FORMAT", "console")
+	t.Setenv("LOG_OUTPUT", "stderr")
+	t.Setenv("METRICS_LABELS", "site=par1, env=prod")
+	t.Setenv("NTP_COLLECTOR_INTERVALS", "base=15s, quality=5m")
+
+	cfg := DefaultConfig()
+	require.NoError(t, applyEnvOverrides(cfg, nil))
+
+	assert.Equal(t, 8080, cfg.Server.Port)
+	assert.Equal(t, 3*time.Second, cfg.NTP.Timeout)
+	assert.True(t, cfg.NTP.EnableKernel)
+	assert.Equal(t, uint32(7), cfg.NTP.CircuitBreaker.MaxRequests)
+	assert.Equal(t, 0.25, cfg.NTP.CircuitBreaker.FailureThreshold)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.Server.AllowedOrigins)
+	assert.Equal(t, "console", cfg.Logging.Format)
+	assert.Equal(t, "stderr", cfg.Logging.Output)
+	assert.Equal(t, map[string]string{"site": "par1", "env": "prod"}, cfg.Metrics.Labels)
+	assert.Equal(t, map[string]time.Duration{"base": 15 * time.Second, "quality": 5 * time.Minute}, cfg.NTP.CollectorIntervals)
+}
+
+func TestApplyEnvOverrides_Pools(t *testing.T) {
+	t.Setenv("NTP_POOLS_0_STRATEGY", "all")
+	t.Setenv("NTP_POOLS_1_NAME", "time.cloudflare.com")
+	t.Setenv("NTP_POOLS_1_MAX_SERVERS", "2")
+
+	cfg := DefaultConfig()
+	cfg.NTP.Pools = []PoolConfig{{Name: "pool.ntp.org", Strategy: "best_n", MaxServers: 4}}
+
+	prov := Provenance{}
+	require.NoError(t, applyEnvOverrides(cfg, prov))
+
+	require.Len(t, cfg.NTP.Pools, 2)
+	assert.Equal(t, PoolConfig{Name: "pool.ntp.org", Strategy: "all", MaxServers: 4}, cfg.NTP.Pools[0])
+	assert.Equal(t, PoolConfig{Name: "time.cloudflare.com", MaxServers: 2}, cfg.NTP.Pools[1])
+	assert.Equal(t, "env NTP_POOLS_1_NAME", prov.Lookup("ntp.pools[1].name").String())
+}
+
+func TestApplyEnvOverrides_PoolIndexGaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		index string
+		want  string
+	}{
+		{"gap", "5", "NTP_POOLS_5_*: index 1 is missing"},
+		{"huge", "999999999", "NTP_POOLS_999999999_*: index 1 is missing"},
+		{"overflow", "99999999999999999999", "index 1 is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NTP_POOLS_0_NAME", "pool.ntp.org")
+			t.Setenv("NTP_POOLS_"+tt.index+"_NAME", "time.cloudflare.com")
+
+			cfg := DefaultConfig()
+			err := applyEnvOverrides(cfg, nil)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+			assert.Equal(t, []PoolConfig{{Name: "pool.ntp.org"}}, cfg.NTP.Pools, "the entries before the gap are still bound")
+		})
+	}
+}
+
+func TestApplyEnvOverrides_AggregatesErrors(t *testing.T) {
+	t.Setenv("NTP_TIMEOUT", "5")
+	t.Setenv("NTP_EXPORTER_PORT", "http")
+	t.Setenv("TLS_ENABLED", "maybe")
+	t.Setenv("METRICS_LABELS", "site")
+	t.Setenv("NTP_VERSION", "3")
+
+	cfg := DefaultConfig()
+	err := applyEnvOverrides(cfg, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NTP_TIMEOUT: invalid duration")
+	assert.Contains(t, err.Error(), "NTP_EXPORTER_PORT: invalid integer")
+	assert.Contains(t, err.Error(), "TLS_ENABLED: invalid boolean")
+	assert.Contains(t, err.Error(), `METRICS_LABELS: invalid key=value pair "site"`)
+	assert.Equal(t, 3, cfg.NTP.Version, "valid variables should still be applied")
+}
+
+func TestApplyEnvOverrides_FileSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "namespace")
+	require.NoError(t, os.WriteFile(path, []byte("from_file\n"), 0600))
+	t.Setenv("METRICS_NAMESPACE_FILE", path)
+
+	cfg := DefaultConfig()
+	prov := Provenance{}
+	require.NoError(t, applyEnvOverrides(cfg, prov))
+
+	assert.Equal(t, "from_file", cfg.Metrics.Namespace)
+	assert.Equal(t, "env METRICS_NAMESPACE_FILE", prov.Lookup("metrics.namespace").String())
+}
+
+func TestApplyEnvOverrides_FileSuffixErrors(t *testing.T) {
+	t.Run("both_set", func(t *testing.T) {
+		t.Setenv("METRICS_NAMESPACE", "ntp")
+		t.Setenv("METRICS_NAMESPACE_FILE", "/run/secrets/namespace")
+
+		err := applyEnvOverrides(DefaultConfig(), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mutually exclusive")
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		t.Setenv("METRICS_NAMESPACE_FILE", "/nonexistent/namespace")
+
+		err := applyEnvOverrides(DefaultConfig(), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "METRICS_NAMESPACE_FILE")
+	})
+}
+
+func TestLoadFromEnvVarsOnly_InvalidValue(t *testing.T) {
+	t.Setenv("NTP_TIMEOUT", "5")
+
+	cfg, err := LoadFromEnvVarsOnly()
+
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+// TestEnvTags_Coverage ensures every configuration field can be set from the environment
+func TestEnvTags_Coverage(t *testing.T) {
+	seen := make(map[string]string)
+
+	var walk func(t *testing.T, typ reflect.Type, path string)
+	walk = func(t *testing.T, typ reflect.Type, path string) {
+		for i := 0; i < typ.NumField(); i++ {
+			sf := typ.Field(i)
+			fieldPath := path + "." + sf.Name
+			env := sf.Tag.Get("env")
+
+			if env == "" {
+				if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+					walk(t, sf.Type, fieldPath)
+					continue
+				}
+				t.Errorf("%s has no env tag", fieldPath)
+				continue
+			}
+
+			if other, ok := seen[env]; ok {
+				t.Errorf("%s and %s share env tag %s", fieldPath, other, env)
+			}
+			seen[env] = fieldPath
+
+			if sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct {
+				walk(t, sf.Type.Elem(), fieldPath+"[]")
+			}
+		}
+	}
+
+	walk(t, reflect.TypeOf(Config{}), "Config")
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// SourceKind identifies the configuration layer that set a field
+type SourceKind int
+
+const (
+	// SourceDefault means the value comes from ApplyDefaults (or the zero value)
+	SourceDefault SourceKind = iota
+	// SourceFile means the value was set in the YAML configuration file
+	SourceFile
+	// SourceEnv means the value was set by an environment variable
+	SourceEnv
+)
+
+// redactedValue replaces secret values in dumps
+const redactedValue = "<redacted>"
+
+// Source describes where the effective value of a field came from
+type Source struct {
+	Kind   SourceKind
+	File   string
+	Line   int
+	Column int
+	EnvVar string
+}
+
+// String formats the source for display ("default", "config.yaml:12:3", "env NTP_TIMEOUT")
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		return s.File + ":" + strconv.Itoa(s.Line) + ":" + strconv.Itoa(s.Column)
+	case SourceEnv:
+		return "env " + s.EnvVar
+	default:
+		return "default"
+	}
+}
+
+// Provenance maps dotted YAML paths (e.g. "ntp.timeout", "ntp.pools[0].name")
+// to the source of their effective value
+type Provenance map[string]Source
+
+// Lookup returns the source of a path. Lists of scalars and maps are recorded
+// as a whole; anything unrecorded comes from defaults.
+func (p Provenance) Lookup(path string) Source {
+	if src, ok := p[path]; ok {
+		return src
+	}
+	return Source{Kind: SourceDefault}
+}
+
+// recordEnv marks a path as set by an environment variable (no-op on nil)
+func (p Provenance) recordEnv(path, envVar string) {
+	if p == nil {
+		return
+	}
+	p[path] = Source{Kind: SourceEnv, EnvVar: envVar}
+}
+
+// LoadWithProvenance loads configuration the same way the exporter does
+// (defaults, then the optional YAML file, then environment overrides) and
+// records which layer set each field. The result is not validated.
+func LoadWithProvenance(path string) (*Config, Provenance, error) {
+	prov := Provenance{}
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		if err := decodeYAML(path, data, cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML config file: %w", err)
+		}
+		if err := recordYAMLPositions(data, path, prov); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML config file %s: %w", path, err)
+		}
+	}
+
+	ApplyDefaults(cfg)
+	if err := applyEnvOverrides(cfg, prov); err != nil {
+		return nil, nil, fmt.Errorf("invalid environment variables: %w", err)
+	}
+
+	return cfg, prov, nil
+}
+
+// recordYAMLPositions records the file position of every key set in the YAML document
+func recordYAMLPositions(data []byte, path string, prov Provenance) error {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range file.Docs {
+		recordYAMLNode(doc.Body, "", path, prov)
+	}
+	return nil
+}
+
+// recordYAMLNode walks mappings and sequences of mappings, recording key positions
+func recordYAMLNode(node ast.Node, prefix, file string, prov Provenance) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		for _, value := range n.Values {
+			recordYAMLNode(value, prefix, file, prov)
+		}
+	case *ast.MappingValueNode:
+		tok := n.Key.GetToken()
+		if tok == nil {
+			return
+		}
+		path := tok.Value
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		prov[path] = Source{
+			Kind:   SourceFile,
+			File:   file,
+			Line:   tok.Position.Line,
+			Column: tok.Position.Column,
+		}
+		recordYAMLNode(n.Value, path, file, prov)
+	case *ast.SequenceNode:
+		for i, value := range n.Values {
+			recordYAMLNode(value, prefix+"["+strconv.Itoa(i)+"]", file, prov)
+		}
+	case *ast.AnchorNode:
+		recordYAMLNode(n.Value, prefix, file, prov)
+	case *ast.TagNode:
+		recordYAMLNode(n.Value, prefix, file, prov)
+	}
+}
+
+// Field is a leaf of the configuration tree with its dotted YAML path
+type Field struct {
+	Path   string
+	Value  string
+	Secret bool
+}
+
+// Fields flattens the configuration into leaves ordered as in the struct
+// definitions. Lists of scalars and maps are reported as a single field,
+// lists of structs are expanded per element.
+func Fields(cfg *Config) []Field {
+	var fields []Field
+	collectFields(reflect.ValueOf(cfg).Elem(), "", false, &fields)
+	return fields
+}
+
+// collectFields appends the leaves of v to fields
+func collectFields(v reflect.Value, path string, secret bool, fields *[]Field) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := yamlName(sf)
+			if name == "" {
+				continue
+			}
+			childPath := name
+			if path != "" {
+				childPath = path + "." + name
+			}
+			collectFields(v.Field(i), childPath, secret || isSecret(sf), fields)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < v.Len(); i++ {
+				collectFields(v.Index(i), path+"["+strconv.Itoa(i)+"]", secret, fields)
+			}
+			if v.Len() == 0 {
+				*fields = append(*fields, Field{Path: path, Value: "[]", Secret: secret})
+			}
+			return
+		}
+		*fields = append(*fields, Field{Path: path, Value: formatValue(v), Secret: secret})
+	default:
+		*fields = append(*fields, Field{Path: path, Value: formatValue(v), Secret: secret})
+	}
+}
+
+// formatValue renders a leaf value for display
+func formatValue(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatValue(v.Index(i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys))
+		for _, k := range keys {
+			items = append(items, k+": "+formatValue(v.MapIndex(reflect.ValueOf(k))))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Redacted returns a deep copy of the configuration with every field tagged
+// `secret:"true"` replaced by a placeholder, suitable for dumping
+func Redacted(cfg *Config) (*Config, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+
+	clone := &Config{}
+	if err := yaml.Unmarshal(data, clone); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+
+	redactSecrets(reflect.ValueOf(clone).Elem(), false)
+	return clone, nil
+}
+
+// redactSecrets blanks non-empty string fields tagged as secrets, recursively
+func redactSecrets(v reflect.Value, secret bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			redactSecrets(v.Field(i), secret || isSecret(t.Field(i)))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redactSecrets(v.Index(i), secret)
+		}
+	case reflect.String:
+		if secret && v.String() != "" && v.CanSet() {
+			v.SetString(redactedValue)
+		}
+	}
+}
+
+// yamlName returns the YAML key of a struct field ("" when not serialized)
+func yamlName(sf reflect.StructField) string {
+	if sf.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// isSecret reports whether a struct field holds a secret
+func isSecret(sf reflect.StructField) bool {
+	return sf.Tag.Get("secret") == "true"
+}
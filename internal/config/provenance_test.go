@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWithProvenance(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	yamlContent := `ntp:
+  servers:
+    - time.google.com
+  timeout: 3s
+  pools:
+    - name: pool.ntp.org
+      max_servers: 2
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(yamlContent), 0644))
+	t.Setenv("NTP_VERSION", "3")
+
+	cfg, prov, err := LoadWithProvenance(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3*time.Second, cfg.NTP.Timeout)
+	assert.Equal(t, 3, cfg.NTP.Version)
+
+	assert.Equal(t, Source{Kind: SourceFile, File: configPath, Line: 4, Column: 3}, prov.Lookup("ntp.timeout"))
+	assert.Equal(t, configPath+":2:3", prov.Lookup("ntp.servers").String())
+	assert.Equal(t, configPath+":6:7", prov.Lookup("ntp.pools[0].name").String())
+	assert.Equal(t, "env NTP_VERSION", prov.Lookup("ntp.version").String())
+	assert.Equal(t, "default", prov.Lookup("ntp.samples_per_server").String())
+	assert.Equal(t, "default", prov.Lookup("ntp.pools[0].strategy").String())
+}
+
+func TestLoadWithProvenance_EnvOnly(t *testing.T) {
+	t.Setenv("NTP_SERVERS", "time.google.com")
+
+	cfg, prov, err := LoadWithProvenance("")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"time.google.com"}, cfg.NTP.Servers)
+	assert.Equal(t, "env NTP_SERVERS", prov.Lookup("ntp.servers").String())
+	assert.Equal(t, SourceDefault, prov.Lookup("server.port").Kind)
+}
+
+func TestLoadWithProvenance_FileNotFound(t *testing.T) {
+	_, _, err := LoadWithProvenance("/nonexistent/config.yaml")
+	assert.Error(t, err)
+}
+
+func TestFields(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NTP.Servers = []string{"a", "b"}
+	cfg.NTP.Pools = []PoolConfig{{Name: "pool.ntp.org", MaxServers: 4}}
+	cfg.Metrics.Labels = map[string]string{"site": "par", "env": "prod"}
+
+	values := make(map[string]string)
+	for _, f := range Fields(cfg) {
+		values[f.Path] = f.Value
+	}
+
+	assert.Equal(t, `["a", "b"]`, values["ntp.servers"])
+	assert.Equal(t, `"pool.ntp.org"`, values["ntp.pools[0].name"])
+	assert.Equal(t, "4", values["ntp.pools[0].max_servers"])
+	assert.Equal(t, "5s", values["ntp.timeout"])
+	assert.Equal(t, `{env: "prod", site: "par"}`, values["metrics.labels"])
+}
+
+func TestRedactSecrets(t *testing.T) {
+	type auth struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password" secret:"true"`
+	}
+	type holder struct {
+		Auth   auth     `yaml:"auth"`
+		Tokens []string `yaml:"tokens" secret:"true"`
+		Empty  string   `yaml:"empty" secret:"true"`
+	}
+
+	h := holder{Auth: auth{User: "admin", Password: "hunter2"}, Tokens: []string{"t1"}}
+	redactSecrets(reflect.ValueOf(&h).Elem(), false)
+
+	assert.Equal(t, "admin", h.Auth.User)
+	assert.Equal(t, redactedValue, h.Auth.Password)
+	assert.Equal(t, []string{redactedValue}, h.Tokens)
+	assert.Empty(t, h.Empty, "unset secrets should stay empty")
+}
+
+func TestRedacted_DoesNotModifyOriginal(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NTP.Servers = []string{"time.google.com"}
+
+	clone, err := Redacted(cfg)
+	require.NoError(t, err)
+
+	clone.NTP.Servers[0] = "changed"
+	assert.Equal(t, "time.google.com", cfg.NTP.Servers[0])
+	assert.Equal(t, cfg.NTP.Timeout, clone.NTP.Timeout)
+}
@@ -2,107 +2,347 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// Validate checks if the configuration is valid
+// Validate checks if the configuration is valid and returns the first problem found
 func Validate(cfg *Config) error {
-	if err := validateServer(&cfg.Server); err != nil {
-		return err
+	if errs := ValidateAll(cfg); len(errs) > 0 {
+		return errs[0]
 	}
 
-	if err := validateNTP(&cfg.NTP); err != nil {
-		return err
-	}
+	return nil
+}
 
-	if err := validateLogging(&cfg.Logging); err != nil {
-		return err
+// ValidateAll checks the whole configuration and returns every problem found,
+// each prefixed with the section it belongs to
+func ValidateAll(cfg *Config) []error {
+	sections := []struct {
+		name string
+		err  error
+	}{
+		{"server", validateServer(&cfg.Server)},
+		{"ntp", validateNTP(&cfg.NTP)},
+		{"logging", validateLogging(&cfg.Logging)},
+		{"metrics", validateMetrics(&cfg.Metrics)},
 	}
 
-	if err := validateMetrics(&cfg.Metrics); err != nil {
-		return err
+	var errs []error
+	for _, section := range sections {
+		for _, err := range unjoin(section.err) {
+			errs = append(errs, fmt.Errorf("%s: %w", section.name, err))
+		}
 	}
 
-	return nil
+	return errs
 }
 
+// unjoin splits an error built with errors.Join back into its parts
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// minAdminTokenLength is the shortest accepted admin bearer token
+const minAdminTokenLength = 16
+
 func validateServer(cfg *ServerConfig) error {
+	var errs []error
+
 	if cfg.Port < 1 || cfg.Port > 65535 {
-		return errors.New("port must be between 1 and 65535, got " + strconv.Itoa(cfg.Port))
+		errs = append(errs, errors.New("port must be between 1 and 65535, got "+strconv.Itoa(cfg.Port)))
 	}
 
 	if cfg.ReadTimeout < 1*time.Second || cfg.ReadTimeout > 60*time.Second {
-		return errors.New("read_timeout must be between 1s and 60s")
+		errs = append(errs, errors.New("read_timeout must be between 1s and 60s"))
 	}
 
 	if cfg.WriteTimeout < 1*time.Second || cfg.WriteTimeout > 60*time.Second {
-		return errors.New("write_timeout must be between 1s and 60s")
+		errs = append(errs, errors.New("write_timeout must be between 1s and 60s"))
 	}
 
 	if cfg.TLSEnabled {
 		if cfg.TLSCertFile == "" {
-			return errors.New("tls_cert_file is required when tls_enabled is true")
+			errs = append(errs, errors.New("tls_cert_file is required when tls_enabled is true"))
 		}
 		if cfg.TLSKeyFile == "" {
-			return errors.New("tls_key_file is required when tls_enabled is true")
+			errs = append(errs, errors.New("tls_key_file is required when tls_enabled is true"))
 		}
 	}
 
-	return nil
+	if cfg.Admin.Enabled && len(cfg.Admin.Token) < minAdminTokenLength {
+		errs = append(errs, fmt.Errorf("admin.token must be at least %d characters when admin.enabled is true", minAdminTokenLength))
+	}
+
+	return errors.Join(errs...)
 }
 
 func validateNTP(cfg *NTPConfig) error {
-	if len(cfg.Servers) == 0 && len(cfg.Pools) == 0 {
-		return errors.New("at least one NTP server or pool must be configured")
+	var errs []error
+
+	if len(cfg.Servers) == 0 && len(cfg.Pools) == 0 && !cfg.Discovery.Enabled() {
+		errs = append(errs, errors.New("at least one NTP server or pool must be configured, or a discovery source"))
 	}
 
 	if cfg.Timeout < 1*time.Second || cfg.Timeout > 60*time.Second {
-		return errors.New("timeout must be between 1s and 60s")
+		errs = append(errs, errors.New("timeout must be between 1s and 60s"))
 	}
 
 	if cfg.Version < 2 || cfg.Version > 4 {
-		return errors.New("ntp version must be 2, 3, or 4, got " + strconv.Itoa(cfg.Version))
+		errs = append(errs, errors.New("ntp version must be 2, 3, or 4, got "+strconv.Itoa(cfg.Version)))
 	}
 
 	if cfg.SamplesPerServer < 1 || cfg.SamplesPerServer > 20 {
-		return errors.New("samples_per_server must be between 1 and 20, got " + strconv.Itoa(cfg.SamplesPerServer))
+		errs = append(errs, errors.New("samples_per_server must be between 1 and 20, got "+strconv.Itoa(cfg.SamplesPerServer)))
 	}
 
 	if cfg.MaxConcurrency < 1 || cfg.MaxConcurrency > 100 {
-		return errors.New("max_concurrency must be between 1 and 100, got " + strconv.Itoa(cfg.MaxConcurrency))
+		errs = append(errs, errors.New("max_concurrency must be between 1 and 100, got "+strconv.Itoa(cfg.MaxConcurrency)))
 	}
 
 	// Validate pools
 	for i, pool := range cfg.Pools {
 		if pool.Name == "" {
-			return errors.New("pool[" + strconv.Itoa(i) + "]: name is required")
+			errs = append(errs, errors.New("pool["+strconv.Itoa(i)+"]: name is required"))
 		}
 		if pool.Strategy != "" && pool.Strategy != "best_n" && pool.Strategy != "round_robin" && pool.Strategy != "all" {
-			return errors.New("pool[" + strconv.Itoa(i) + "]: invalid strategy (must be best_n, round_robin, or all)")
+			errs = append(errs, errors.New("pool["+strconv.Itoa(i)+"]: invalid strategy (must be best_n, round_robin, or all)"))
 		}
 		if pool.MaxServers < 1 || pool.MaxServers > 20 {
-			return errors.New("pool[" + strconv.Itoa(i) + "]: max_servers must be between 1 and 20, got " + strconv.Itoa(pool.MaxServers))
+			errs = append(errs, errors.New("pool["+strconv.Itoa(i)+"]: max_servers must be between 1 and 20, got "+strconv.Itoa(pool.MaxServers)))
 		}
 	}
 
 	// Validate rate limiting
 	if cfg.RateLimit.Enabled {
 		if cfg.RateLimit.GlobalRate < 1 {
-			return errors.New("rate_limit.global_rate must be at least 1")
+			errs = append(errs, errors.New("rate_limit.global_rate must be at least 1"))
 		}
 		if cfg.RateLimit.PerServerRate < 1 {
-			return errors.New("rate_limit.per_server_rate must be at least 1")
+			errs = append(errs, errors.New("rate_limit.per_server_rate must be at least 1"))
 		}
 		if cfg.RateLimit.BurstSize < 1 {
-			return errors.New("rate_limit.burst_size must be at least 1")
+			errs = append(errs, errors.New("rate_limit.burst_size must be at least 1"))
+		}
+	}
+
+	// Validate poll scheduling
+	if cfg.PollScheduling.Enabled {
+		ps := cfg.PollScheduling
+		if ps.MinPoll < 3 || ps.MaxPoll > 17 || ps.MinPoll > ps.MaxPoll {
+			errs = append(errs, fmt.Errorf("poll_scheduling: min_poll and max_poll must satisfy 3 <= min_poll <= max_poll <= 17, got %d and %d", ps.MinPoll, ps.MaxPoll))
+		}
+	}
+
+	// Kiss-of-Death backoff applies whether or not rate limiting is enabled
+	if cfg.RateLimit.BackoffDuration < 0 || cfg.RateLimit.MaxBackoff < 0 || cfg.RateLimit.KoDSuspend < 0 {
+		errs = append(errs, errors.New("rate_limit backoff durations must not be negative"))
+	}
+	if cfg.RateLimit.MaxBackoff > 0 && cfg.RateLimit.MaxBackoff < cfg.RateLimit.BackoffDuration {
+		errs = append(errs, errors.New("rate_limit.max_backoff must not be less than rate_limit.backoff_duration"))
+	}
+
+	// Validate address families
+	if !validAddressFamilies[cfg.AddressFamily] {
+		errs = append(errs, fmt.Errorf("invalid address_family %q (must be ipv4, ipv6, both or all_addresses)", cfg.AddressFamily))
+	}
+	servers := make([]string, 0, len(cfg.AddressFamilies))
+	for server := range cfg.AddressFamilies {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	for _, server := range servers {
+		if family := cfg.AddressFamilies[server]; family == "" || !validAddressFamilies[family] {
+			errs = append(errs, fmt.Errorf("address_families[%s]: invalid address family %q (must be ipv4, ipv6, both or all_addresses)", server, family))
+		}
+	}
+
+	// Validate sample filters
+	if !validSampleFilters[cfg.SampleFilter] {
+		errs = append(errs, fmt.Errorf("invalid sample_filter %q (must be median or clock_filter)", cfg.SampleFilter))
+	}
+	collectors := make([]string, 0, len(cfg.SampleFilters))
+	for collector := range cfg.SampleFilters {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+	for _, collector := range collectors {
+		if !collectorNames[collector] {
+			errs = append(errs, fmt.Errorf("sample_filters[%s]: unknown collector (must be base, quality, security or hybrid)", collector))
+		}
+		if filter := cfg.SampleFilters[collector]; filter == "" || !validSampleFilters[filter] {
+			errs = append(errs, fmt.Errorf("sample_filters[%s]: invalid sample filter %q (must be median or clock_filter)", collector, filter))
+		}
+	}
+
+	// Validate the collection mode
+	switch cfg.CollectionMode {
+	case "", CollectionModeBackground:
+	case CollectionModeOnScrape:
+		if cfg.PollScheduling.Enabled {
+			errs = append(errs, errors.New("poll_scheduling cannot be enabled with collection_mode on_scrape"))
+		}
+		// Each scrape runs every collector, so only collector_timeouts still apply
+		if len(cfg.CollectorIntervals) > 0 || cfg.CollectorJitter > 0 {
+			errs = append(errs, errors.New("collector_intervals and collector_jitter cannot be set with collection_mode on_scrape"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid collection_mode %q (must be background or on_scrape)", cfg.CollectionMode))
+	}
+	if cfg.CollectionMinInterval < 0 {
+		errs = append(errs, errors.New("collection_min_interval must not be negative"))
+	}
+
+	// Validate collector schedules
+	if cfg.ScrapeInterval < 0 {
+		errs = append(errs, errors.New("scrape_interval must not be negative"))
+	}
+	for _, collector := range sortedKeys(cfg.CollectorIntervals) {
+		if !collectorNames[collector] {
+			errs = append(errs, fmt.Errorf("collector_intervals[%s]: unknown collector (must be base, quality, security or hybrid)", collector))
+		}
+		if cfg.CollectorIntervals[collector] <= 0 {
+			errs = append(errs, fmt.Errorf("collector_intervals[%s]: interval must be positive", collector))
+		}
+	}
+	for _, collector := range sortedKeys(cfg.CollectorTimeouts) {
+		if !collectorNames[collector] {
+			errs = append(errs, fmt.Errorf("collector_timeouts[%s]: unknown collector (must be base, quality, security or hybrid)", collector))
+		}
+		if cfg.CollectorTimeouts[collector] < 0 {
+			errs = append(errs, fmt.Errorf("collector_timeouts[%s]: timeout must not be negative", collector))
+		}
+	}
+	if cfg.CollectorJitter < 0 {
+		errs = append(errs, errors.New("collector_jitter must not be negative"))
+	}
+	// The poll loop runs every collector at each poll, on the per-server
+	// intervals, so only collector_timeouts still apply
+	if cfg.PollScheduling.Enabled && (len(cfg.CollectorIntervals) > 0 || cfg.CollectorJitter > 0) {
+		errs = append(errs, errors.New("collector_intervals and collector_jitter cannot be set with poll_scheduling enabled"))
+	}
+
+	// Validate DNS cache and resolver
+	if cfg.DNSCache.MinTTL < 0 || cfg.DNSCache.MaxTTL < 0 {
+		errs = append(errs, errors.New("dns_cache TTLs must not be negative"))
+	}
+	if cfg.DNSCache.MaxTTL > 0 && cfg.DNSCache.MaxTTL < cfg.DNSCache.MinTTL {
+		errs = append(errs, errors.New("dns_cache.max_ttl must not be less than dns_cache.min_ttl"))
+	}
+	if cfg.DNS.Timeout < 0 {
+		errs = append(errs, errors.New("dns.timeout must not be negative"))
+	}
+	for i, server := range cfg.DNS.Servers {
+		if err := validateDNSServer(server); err != nil {
+			errs = append(errs, fmt.Errorf("dns.servers[%d]: %w", i, err))
 		}
 	}
 
+	if cfg.ProcRoot != "" && !filepath.IsAbs(cfg.ProcRoot) {
+		errs = append(errs, fmt.Errorf("proc_root must be an absolute path, got %q", cfg.ProcRoot))
+	}
+	if cfg.SysRoot != "" && !filepath.IsAbs(cfg.SysRoot) {
+		errs = append(errs, fmt.Errorf("sys_root must be an absolute path, got %q", cfg.SysRoot))
+	}
+
+	// Validate discovery
+	if cfg.Discovery.RefreshInterval < 0 {
+		errs = append(errs, errors.New("discovery.refresh_interval must not be negative"))
+	}
+	if cfg.Discovery.HostRoot != "" && !filepath.IsAbs(cfg.Discovery.HostRoot) {
+		errs = append(errs, fmt.Errorf("discovery.host_root must be an absolute path, got %q", cfg.Discovery.HostRoot))
+	}
+	for i, name := range cfg.Discovery.SRV {
+		if !strings.HasPrefix(name, "_") || strings.ContainsAny(name, "/: ") {
+			errs = append(errs, fmt.Errorf("discovery.srv[%d]: invalid SRV record name %q (e.g. _ntp._udp.example.com)", i, name))
+		}
+	}
+	for i, file := range cfg.Discovery.Files {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".json", ".yml", ".yaml":
+		default:
+			errs = append(errs, fmt.Errorf("discovery.files[%d]: target file %q must be .json, .yml or .yaml", i, file))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validAddressFamilies lists the address_family values; empty is the default
+var validAddressFamilies = map[string]bool{
+	"":              true,
+	"ipv4":          true,
+	"ipv6":          true,
+	"both":          true,
+	"all_addresses": true,
+}
+
+// validSampleFilters lists the sample_filter values; empty is the default
+var validSampleFilters = map[string]bool{
+	"":                      true,
+	SampleFilterMedian:      true,
+	SampleFilterClockFilter: true,
+}
+
+// collectorNames lists the collectors per-collector settings can be given for
+var collectorNames = map[string]bool{
+	"base":     true,
+	"quality":  true,
+	"security": true,
+	"hybrid":   true,
+}
+
+// sortedKeys returns the keys of a per-collector map in order, so that
+// errors are reported deterministically
+func sortedKeys(m map[string]time.Duration) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateDNSServer checks the transport and address of an upstream DNS server
+func validateDNSServer(server string) error {
+	scheme, rest, found := strings.Cut(server, "://")
+	if !found {
+		scheme, rest = "udp", server
+	}
+
+	switch scheme {
+	case "udp", "tcp", "tls":
+		if rest == "" || strings.Contains(rest, "/") {
+			return fmt.Errorf("invalid address %q", server)
+		}
+	case "https":
+		u, err := url.Parse(server)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid DNS-over-HTTPS URL %q", server)
+		}
+	default:
+		return fmt.Errorf("unsupported transport %q (must be udp, tcp, tls or https)", scheme)
+	}
 	return nil
 }
 
 func validateLogging(cfg *LoggingConfig) error {
+	var errs []error
+
 	validLevels := map[string]bool{
 		"trace": true,
 		"debug": true,
@@ -114,7 +354,7 @@ func validateLogging(cfg *LoggingConfig) error {
 	}
 
 	if !validLevels[cfg.Level] {
-		return errors.New("invalid log level (must be trace, debug, info, warn, error, fatal, or panic)")
+		errs = append(errs, errors.New("invalid log level (must be trace, debug, info, warn, error, fatal, or panic)"))
 	}
 
 	validFormats := map[string]bool{
@@ -123,20 +363,90 @@ func validateLogging(cfg *LoggingConfig) error {
 	}
 
 	if !validFormats[cfg.Format] {
-		return errors.New("invalid log format (must be json or console)")
+		errs = append(errs, errors.New("invalid log format (must be json or console)"))
 	}
 
 	if cfg.EnableFile && cfg.FilePath == "" {
-		return errors.New("file_path is required when enable_file is true")
+		errs = append(errs, errors.New("file_path is required when enable_file is true"))
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// labelNameRE matches valid Prometheus label names
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ReservedLabelNames are the variable labels of the exporter's own metrics,
+// histogram buckets included. A constant label of the same name would make
+// their registration fail.
+var ReservedLabelNames = []string{
+	"address", "clock", "clocksource", "code", "collector", "commit", "daemon",
+	"family", "from", "go_version", "kod", "le", "mode", "node", "pool",
+	"quantile", "reason", "scope", "server", "source", "state", "status",
+	"stratum", "to", "version",
 }
 
 func validateMetrics(cfg *MetricsConfig) error {
+	var errs []error
+
 	if cfg.Namespace == "" {
-		return errors.New("namespace is required")
+		errs = append(errs, errors.New("namespace is required"))
 	}
 
-	return nil
+	names := make([]string, 0, len(cfg.Labels))
+	for name := range cfg.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, errors.New("labels: invalid label name "+strconv.Quote(name)))
+		} else if slices.Contains(ReservedLabelNames, name) {
+			errs = append(errs, errors.New("labels: "+strconv.Quote(name)+" is a label of the exporter's own metrics"))
+		}
+	}
+
+	for _, name := range names {
+		if cfg.Labels[name] == "" {
+			errs = append(errs, errors.New("labels: value of "+strconv.Quote(name)+" is empty"))
+		}
+	}
+
+	_, err := ExpandLabels(cfg.Labels)
+	errs = append(errs, unjoin(err)...)
+
+	return errors.Join(errs...)
+}
+
+// ExpandLabels resolves ${VAR} and $VAR references in metrics label values
+// from the environment (e.g. probe: ${NODE_NAME}); $$ stands for a literal $.
+// Referencing an unset or empty variable is an error.
+func ExpandLabels(labels map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expanded := make(map[string]string, len(labels))
+	var errs []error
+	for _, name := range names {
+		var missing []string
+		expanded[name] = os.Expand(labels[name], func(variable string) string {
+			if variable == "$" {
+				return "$"
+			}
+			value := os.Getenv(variable)
+			if value == "" {
+				missing = append(missing, variable)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			errs = append(errs, fmt.Errorf("labels: %s references unset environment variable %s", name, strings.Join(missing, ", ")))
+		}
+	}
+
+	return expanded, errors.Join(errs...)
 }
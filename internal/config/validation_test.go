@@ -1,10 +1,15 @@
 package config
 
 import (
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/maximewewer/ntp-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidate_ValidConfig(t *testing.T) {
@@ -15,6 +20,24 @@ func TestValidate_ValidConfig(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateAll_ReportsEveryError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.Port = 0
+	cfg.NTP.Timeout = 0
+	cfg.NTP.Version = 9
+	cfg.Logging.Level = "loud"
+
+	errs := ValidateAll(cfg)
+
+	if assert.Len(t, errs, 4) {
+		assert.Contains(t, errs[0].Error(), "server: port must be between")
+		assert.Contains(t, errs[1].Error(), "ntp: timeout must be between")
+		assert.Contains(t, errs[2].Error(), "ntp: ntp version must be")
+		assert.Contains(t, errs[3].Error(), "logging: invalid log level")
+	}
+	assert.Equal(t, errs[0], Validate(cfg))
+}
+
 func TestValidateServer_ValidPort(t *testing.T) {
 	tests := []struct {
 		name string
@@ -124,6 +147,39 @@ func TestValidateServer_TLSConfig(t *testing.T) {
 	}
 }
 
+func TestValidateServer_Admin(t *testing.T) {
+	tests := []struct {
+		name    string
+		admin   AdminConfig
+		wantErr bool
+	}{
+		{"disabled_without_token", AdminConfig{}, false},
+		{"enabled_with_token", AdminConfig{Enabled: true, Token: "0123456789abcdef"}, false},
+		{"enabled_without_token", AdminConfig{Enabled: true}, true},
+		{"enabled_short_token", AdminConfig{Enabled: true, Token: "secret"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &ServerConfig{
+				Port:         9559,
+				ReadTimeout:  10 * time.Second,
+				WriteTimeout: 10 * time.Second,
+				Admin:        tt.admin,
+			}
+
+			err := validateServer(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "admin.token")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestValidateNTP_ServersOrPools(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -447,6 +503,23 @@ func TestValidateNTP_RateLimit(t *testing.T) {
 			wantErr: true,
 			errMsg:  "burst_size",
 		},
+		{
+			name:      "kod_backoff_when_disabled",
+			rateLimit: RateLimitConfig{BackoffDuration: time.Minute, MaxBackoff: time.Hour, KoDSuspend: 24 * time.Hour},
+			wantErr:   false,
+		},
+		{
+			name:      "max_backoff_below_backoff",
+			rateLimit: RateLimitConfig{BackoffDuration: time.Hour, MaxBackoff: time.Minute},
+			wantErr:   true,
+			errMsg:    "max_backoff",
+		},
+		{
+			name:      "negative_kod_suspend",
+			rateLimit: RateLimitConfig{KoDSuspend: -time.Hour},
+			wantErr:   true,
+			errMsg:    "must not be negative",
+		},
 	}
 
 	for _, tt := range tests {
@@ -472,6 +545,368 @@ func TestValidateNTP_RateLimit(t *testing.T) {
 	}
 }
 
+func TestValidateNTP_AddressFamily(t *testing.T) {
+	tests := []struct {
+		name      string
+		family    string
+		perServer map[string]string
+		wantErr   bool
+		errMsg    string
+	}{
+		{"default", "", nil, false, ""},
+		{"global_both", "both", nil, false, ""},
+		{"per_server", "ipv4", map[string]string{"time.example": "all_addresses", "v6.example": "ipv6"}, false, ""},
+		{"invalid_global", "dual", nil, true, "address_family"},
+		{"invalid_per_server", "", map[string]string{"time.example": "ip6"}, true, "address_families[time.example]"},
+		{"empty_per_server", "", map[string]string{"time.example": ""}, true, "address_families[time.example]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          []string{"pool.ntp.org"},
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				AddressFamily:    tt.family,
+				AddressFamilies:  tt.perServer,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNTPConfig_AddressFamilyFor(t *testing.T) {
+	cfg := &NTPConfig{AddressFamily: "ipv4", AddressFamilies: map[string]string{"time.example": "all_addresses"}}
+	assert.Equal(t, "all_addresses", cfg.AddressFamilyFor("time.example"))
+	assert.Equal(t, "ipv4", cfg.AddressFamilyFor("other.example"))
+}
+
+func TestValidateNTP_SampleFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       string
+		perCollector map[string]string
+		wantErr      bool
+		errMsg       string
+	}{
+		{"default", "", nil, false, ""},
+		{"clock_filter", "clock_filter", nil, false, ""},
+		{"per_collector", "median", map[string]string{"base": "clock_filter", "quality": "median"}, false, ""},
+		{"invalid_global", "mean", nil, true, "sample_filter"},
+		{"invalid_per_collector", "", map[string]string{"base": "min_delay"}, true, "sample_filters[base]"},
+		{"unknown_collector", "", map[string]string{"kernel": "clock_filter"}, true, "sample_filters[kernel]: unknown collector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          []string{"pool.ntp.org"},
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				SampleFilter:     tt.filter,
+				SampleFilters:    tt.perCollector,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNTPConfig_SampleFilterFor(t *testing.T) {
+	cfg := &NTPConfig{SampleFilters: map[string]string{"base": "clock_filter"}}
+	assert.Equal(t, SampleFilterClockFilter, cfg.SampleFilterFor("base"))
+	assert.Equal(t, SampleFilterMedian, cfg.SampleFilterFor("quality"))
+
+	cfg.SampleFilter = "clock_filter"
+	assert.Equal(t, SampleFilterClockFilter, cfg.SampleFilterFor("quality"))
+}
+
+func TestValidateNTP_PollScheduling(t *testing.T) {
+	tests := []struct {
+		name    string
+		poll    PollSchedulingConfig
+		wantErr bool
+	}{
+		{"disabled", PollSchedulingConfig{}, false},
+		{"ntpd_defaults", PollSchedulingConfig{Enabled: true, MinPoll: 6, MaxPoll: 10}, false},
+		{"fixed_interval", PollSchedulingConfig{Enabled: true, MinPoll: 5, MaxPoll: 5}, false},
+		{"min_too_low", PollSchedulingConfig{Enabled: true, MinPoll: 2, MaxPoll: 10}, true},
+		{"max_too_high", PollSchedulingConfig{Enabled: true, MinPoll: 6, MaxPoll: 18}, true},
+		{"min_above_max", PollSchedulingConfig{Enabled: true, MinPoll: 10, MaxPoll: 6}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          []string{"pool.ntp.org"},
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				PollScheduling:   tt.poll,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "poll_scheduling")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNTP_CollectorSchedules(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals map[string]time.Duration
+		timeouts  map[string]time.Duration
+		jitter    time.Duration
+		poll      bool
+		mode      string
+		errMsg    string
+	}{
+		{"none", nil, nil, 0, false, "", ""},
+		{"valid", map[string]time.Duration{"base": 15 * time.Second, "security": 10 * time.Minute}, map[string]time.Duration{"security": time.Minute}, 30 * time.Second, false, "", ""},
+		{"unknown_collector", map[string]time.Duration{"kernel": time.Minute}, nil, 0, false, "", "collector_intervals[kernel]"},
+		{"zero_interval", map[string]time.Duration{"quality": 0}, nil, 0, false, "", "collector_intervals[quality]"},
+		{"negative_timeout", nil, map[string]time.Duration{"base": -time.Second}, 0, false, "", "collector_timeouts[base]"},
+		{"timeouts_with_poll_scheduling", nil, map[string]time.Duration{"security": time.Minute}, 0, true, "", ""},
+		{"intervals_with_poll_scheduling", map[string]time.Duration{"quality": 5 * time.Minute}, nil, 0, true, "", "poll_scheduling"},
+		{"jitter_with_poll_scheduling", nil, nil, 10 * time.Second, true, "", "poll_scheduling"},
+		{"negative_jitter", nil, nil, -time.Second, false, "", "collector_jitter"},
+		{"timeouts_on_scrape", nil, map[string]time.Duration{"security": time.Minute}, 0, false, CollectionModeOnScrape, ""},
+		{"intervals_on_scrape", map[string]time.Duration{"quality": 5 * time.Minute}, nil, 0, false, CollectionModeOnScrape, "collection_mode on_scrape"},
+		{"jitter_on_scrape", nil, nil, 10 * time.Second, false, CollectionModeOnScrape, "collection_mode on_scrape"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:            []string{"pool.ntp.org"},
+				Timeout:            5 * time.Second,
+				Version:            4,
+				SamplesPerServer:   3,
+				MaxConcurrency:     10,
+				CollectorIntervals: tt.intervals,
+				CollectorTimeouts:  tt.timeouts,
+				CollectorJitter:    tt.jitter,
+				CollectionMode:     tt.mode,
+				PollScheduling:     PollSchedulingConfig{Enabled: tt.poll, MinPoll: 6, MaxPoll: 10},
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.errMsg != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNTP_CollectionMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		interval time.Duration
+		poll     bool
+		errMsg   string
+	}{
+		{"default", "", 0, false, ""},
+		{"background", CollectionModeBackground, 0, true, ""},
+		{"on_scrape", CollectionModeOnScrape, 10 * time.Second, false, ""},
+		{"unknown_mode", "on_demand", 0, false, "collection_mode"},
+		{"negative_min_interval", CollectionModeOnScrape, -time.Second, false, "collection_min_interval"},
+		{"on_scrape_with_poll_scheduling", CollectionModeOnScrape, 0, true, "poll_scheduling"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:               []string{"pool.ntp.org"},
+				Timeout:               5 * time.Second,
+				Version:               4,
+				SamplesPerServer:      3,
+				MaxConcurrency:        10,
+				CollectionMode:        tt.mode,
+				CollectionMinInterval: tt.interval,
+				PollScheduling:        PollSchedulingConfig{Enabled: tt.poll, MinPoll: 6, MaxPoll: 10},
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.errMsg != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNTPConfig_CollectorSchedule(t *testing.T) {
+	cfg := &NTPConfig{
+		CollectorIntervals: map[string]time.Duration{"quality": 5 * time.Minute},
+		CollectorTimeouts:  map[string]time.Duration{"quality": time.Minute},
+	}
+	assert.Equal(t, DefaultScrapeInterval, cfg.CollectorInterval("base"))
+	assert.Equal(t, 5*time.Minute, cfg.CollectorInterval("quality"))
+	assert.Zero(t, cfg.CollectorTimeout("base"))
+	assert.Equal(t, time.Minute, cfg.CollectorTimeout("quality"))
+
+	cfg.ScrapeInterval = 10 * time.Second
+	assert.Equal(t, 10*time.Second, cfg.CollectorInterval("base"))
+}
+
+func TestValidateNTP_DNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		dns      DNSConfig
+		dnsCache DNSCacheConfig
+		wantErr  bool
+		errMsg   string
+	}{
+		{"system_resolver", DNSConfig{}, DNSCacheConfig{}, false, ""},
+		{
+			"every_transport",
+			DNSConfig{Servers: []string{"192.0.2.53", "udp://[2001:db8::53]:53", "tcp://ns.example", "tls://1.1.1.1", "https://dns.example/dns-query"}},
+			DNSCacheConfig{},
+			false, "",
+		},
+		{"unsupported_transport", DNSConfig{Servers: []string{"quic://dns.example"}}, DNSCacheConfig{}, true, "dns.servers[0]"},
+		{"doh_without_host", DNSConfig{Servers: []string{"https:///dns-query"}}, DNSCacheConfig{}, true, "dns.servers[0]"},
+		{"path_on_plain_dns", DNSConfig{Servers: []string{"192.0.2.53", "tcp://ns.example/x"}}, DNSCacheConfig{}, true, "dns.servers[1]"},
+		{"negative_timeout", DNSConfig{Timeout: -time.Second}, DNSCacheConfig{}, true, "dns.timeout"},
+		{"max_ttl_below_min_ttl", DNSConfig{}, DNSCacheConfig{MinTTL: time.Hour, MaxTTL: time.Minute}, true, "dns_cache.max_ttl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          []string{"pool.ntp.org"},
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				DNS:              tt.dns,
+				DNSCache:         tt.dnsCache,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNTP_Discovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		servers   []string
+		discovery DiscoveryConfig
+		wantErr   bool
+		errMsg    string
+	}{
+		{"discovery_only", nil, DiscoveryConfig{SRV: []string{"_ntp._udp.example.com"}}, false, ""},
+		{"files_only", nil, DiscoveryConfig{Files: []string{"/etc/ntp-exporter/targets.json", "targets.YAML"}}, false, ""},
+		{"invalid_srv_name", []string{"pool.ntp.org"}, DiscoveryConfig{SRV: []string{"ntp.example.com"}}, true, "discovery.srv[0]"},
+		{"srv_url", []string{"pool.ntp.org"}, DiscoveryConfig{SRV: []string{"_ntp._udp.example.com", "_ntp://x"}}, true, "discovery.srv[1]"},
+		{"unsupported_file", []string{"pool.ntp.org"}, DiscoveryConfig{Files: []string{"targets.txt"}}, true, "discovery.files[0]"},
+		{"negative_interval", []string{"pool.ntp.org"}, DiscoveryConfig{RefreshInterval: -time.Second}, true, "discovery.refresh_interval"},
+		{"host_config_only", nil, DiscoveryConfig{HostConfig: true, HostRoot: "/host"}, false, ""},
+		{"relative_host_root", nil, DiscoveryConfig{HostConfig: true, HostRoot: "host"}, true, "discovery.host_root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          tt.servers,
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				Discovery:        tt.discovery,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNTP_HostRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		procRoot string
+		sysRoot  string
+		errMsg   string
+	}{
+		{"defaults", "", "", ""},
+		{"host_mounts", "/host/proc", "/host/sys", ""},
+		{"relative_proc_root", "proc", "", "proc_root"},
+		{"relative_sys_root", "", "host/sys", "sys_root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NTPConfig{
+				Servers:          []string{"pool.ntp.org"},
+				Timeout:          5 * time.Second,
+				Version:          4,
+				SamplesPerServer: 3,
+				MaxConcurrency:   10,
+				ProcRoot:         tt.procRoot,
+				SysRoot:          tt.sysRoot,
+			}
+
+			err := validateNTP(cfg)
+
+			if tt.errMsg != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestValidateLogging_Level(t *testing.T) {
 	validLevels := []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
 	invalidLevels := []string{"invalid", "INFO", "warning", ""}
@@ -595,6 +1030,86 @@ func TestValidateMetrics_Namespace(t *testing.T) {
 	}
 }
 
+func TestValidateMetrics_Labels(t *testing.T) {
+	t.Setenv("TEST_NODE_NAME", "node-1")
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr string
+	}{
+		{"valid", map[string]string{"site": "par1", "region": "eu_west"}, ""},
+		{"template", map[string]string{"probe": "${TEST_NODE_NAME}"}, ""},
+		{"invalid_name", map[string]string{"probe-site": "par1"}, `invalid label name "probe-site"`},
+		{"reserved_name", map[string]string{"__name": "x"}, `invalid label name "__name"`},
+		{"empty_value", map[string]string{"site": ""}, `value of "site" is empty`},
+		{"unset_variable", map[string]string{"probe": "${TEST_UNSET_VARIABLE}"}, "unset environment variable TEST_UNSET_VARIABLE"},
+		{"clashes_with_server", map[string]string{"server": "a"}, `"server" is a label of the exporter's own metrics`},
+		{"clashes_with_collector", map[string]string{"collector": "a"}, `"collector" is a label of the exporter's own metrics`},
+		{"clashes_with_reason", map[string]string{"reason": "a"}, `"reason" is a label of the exporter's own metrics`},
+		{"clashes_with_scope", map[string]string{"scope": "a"}, `"scope" is a label of the exporter's own metrics`},
+		{"clashes_with_source", map[string]string{"source": "a"}, `"source" is a label of the exporter's own metrics`},
+		{"clashes_with_node", map[string]string{"node": "${TEST_NODE_NAME}"}, `"node" is a label of the exporter's own metrics`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &MetricsConfig{Namespace: "ntp", Labels: tt.labels}
+
+			err := validateMetrics(cfg)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExpandLabels(t *testing.T) {
+	t.Setenv("TEST_NODE_NAME", "node-1")
+	t.Setenv("TEST_REGION", "eu-west")
+
+	labels, err := ExpandLabels(map[string]string{
+		"node":   "${TEST_NODE_NAME}",
+		"region": "$TEST_REGION",
+		"site":   "dc-${TEST_REGION}-a",
+		"static": "probe",
+		"price":  "$$5",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"node":   "node-1",
+		"region": "eu-west",
+		"site":   "dc-eu-west-a",
+		"static": "probe",
+		"price":  "$5",
+	}, labels)
+}
+
+func TestReservedLabelNames(t *testing.T) {
+	// Constant labels are checked against ReservedLabelNames, which must list
+	// every variable label of the exporter's metrics
+	descs := make(chan *prometheus.Desc, 1024)
+	metrics.NewNTPMetrics().Describe(descs)
+	close(descs)
+
+	variableLabels := regexp.MustCompile(`variableLabels: \{([^}]*)\}`)
+	for desc := range descs {
+		match := variableLabels.FindStringSubmatch(desc.String())
+		require.NotNil(t, match, desc.String())
+		if match[1] == "" {
+			continue
+		}
+		for _, name := range strings.Split(match[1], ",") {
+			assert.Contains(t, ReservedLabelNames, name, desc.String())
+		}
+	}
+}
+
 func TestValidate_CompleteConfig(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{
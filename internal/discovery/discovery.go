@@ -0,0 +1,340 @@
+// Package discovery finds NTP targets at runtime, in addition to the static
+// ntp.servers list.
+//
+// Three providers are available:
+//   - HostConfigProvider: the servers of the host's chrony, ntpd or
+//     systemd-timesyncd configuration, for agents monitoring their node
+//   - SRVProvider: DNS SRV records such as _ntp._udp.example.com, looked up
+//     again every refresh interval
+//   - FileProvider: Prometheus file_sd compatible JSON or YAML target files,
+//     reloaded when they change
+//
+// A Manager merges the targets of its providers, drops the ones failing
+// ntp.ValidateServerAddress and reports the servers that disappeared so that
+// their series can be deleted.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+)
+
+// Target is an NTP server found by a provider
+type Target struct {
+	Server string            `json:"server"`
+	Source string            `json:"source"`           // Name of the provider that found it
+	Labels map[string]string `json:"labels,omitempty"` // Labels attached by the provider
+}
+
+// Provider discovers targets from one source
+type Provider interface {
+	// Name identifies the provider and is used as the source of its targets
+	Name() string
+
+	// Discover returns the current targets of the source
+	Discover(ctx context.Context) ([]Target, error)
+
+	// Watch blocks until ctx is done, calling changed whenever Discover may
+	// return different targets
+	Watch(ctx context.Context, changed func())
+}
+
+// watchInterval calls changed every interval until ctx is done; a zero
+// interval only waits for ctx
+func watchInterval(ctx context.Context, interval time.Duration, changed func()) {
+	if interval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed()
+		}
+	}
+}
+
+// reservedLabels are label names set by the exporter on target_info
+var reservedLabels = map[string]bool{
+	"server": true,
+	"source": true,
+}
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidateLabelName checks that a target label can be exported as a
+// Prometheus label without clashing with the exporter's own labels
+func ValidateLabelName(name string) error {
+	switch {
+	case !labelNamePattern.MatchString(name):
+		return fmt.Errorf("invalid label name %q", name)
+	case strings.HasPrefix(name, "__"):
+		return fmt.Errorf("label name %q is reserved (__ prefix)", name)
+	case reservedLabels[name]:
+		return fmt.Errorf("label name %q is reserved", name)
+	}
+	return nil
+}
+
+// validateTarget checks the server address and label names of a target
+func validateTarget(t Target) error {
+	if err := ntp.ValidateServerAddress(t.Server); err != nil {
+		return err
+	}
+	for name := range t.Labels {
+		if err := ValidateLabelName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Manager merges the targets of several providers
+type Manager struct {
+	providers []Provider
+
+	mu          sync.RWMutex
+	sets        map[string][]Target // Last targets of each provider
+	targets     []Target            // Merged targets, in provider order
+	onRemove    func(servers []string)
+	constLabels map[string]bool // Labels added to every series by the registry
+}
+
+// NewManager creates a manager for the given providers. When two providers
+// find the same server, the first one wins.
+func NewManager(providers ...Provider) *Manager {
+	return &Manager{
+		providers: providers,
+		sets:      make(map[string][]Target),
+		targets:   []Target{},
+	}
+}
+
+// NewManagerFromConfig creates a manager with the host configuration, the
+// SRV records and the target files of the configuration, in that order; nil
+// when none is configured
+func NewManagerFromConfig(cfg config.DiscoveryConfig) *Manager {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	providers := make([]Provider, 0, 1+len(cfg.SRV)+len(cfg.Files))
+	if cfg.HostConfig {
+		providers = append(providers, NewHostConfigProvider(cfg.HostRoot, cfg.RefreshInterval))
+	}
+	for _, name := range cfg.SRV {
+		providers = append(providers, NewSRVProvider(name, cfg.RefreshInterval, nil))
+	}
+	for _, path := range cfg.Files {
+		providers = append(providers, NewFileProvider(path, cfg.RefreshInterval))
+	}
+	return NewManager(providers...)
+}
+
+// OnRemove sets the function called with the servers no longer discovered
+// after a change. It may be called concurrently from the providers' watchers,
+// and a removed server may be discovered again by the time it runs.
+func (m *Manager) OnRemove(fn func(servers []string)) {
+	m.mu.Lock()
+	m.onRemove = fn
+	m.mu.Unlock()
+}
+
+// SetConstLabels sets the constant labels the metrics registry adds to every
+// series. A target label of the same name would make target_info invalid, so
+// it is exported as exported_<name> instead, as Prometheus does on conflicts.
+func (m *Manager) SetConstLabels(labels map[string]string) {
+	m.mu.Lock()
+	m.constLabels = make(map[string]bool, len(labels))
+	for name := range labels {
+		m.constLabels[name] = true
+	}
+	m.mu.Unlock()
+}
+
+// Refresh asks every provider for its targets once
+func (m *Manager) Refresh(ctx context.Context) {
+	for _, p := range m.providers {
+		m.refresh(ctx, p)
+	}
+}
+
+// Run watches every provider and applies their changes until ctx is done
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range m.providers {
+		wg.Add(1)
+		go func(p Provider) {
+			defer wg.Done()
+			p.Watch(ctx, func() { m.refresh(ctx, p) })
+		}(p)
+	}
+	wg.Wait()
+}
+
+// Targets returns the merged targets
+func (m *Manager) Targets() []Target {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	targets := make([]Target, len(m.targets))
+	copy(targets, m.targets)
+	return targets
+}
+
+// Servers returns the servers of the merged targets
+func (m *Manager) Servers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	servers := make([]string, 0, len(m.targets))
+	for _, t := range m.targets {
+		servers = append(servers, t.Server)
+	}
+	return servers
+}
+
+// refresh replaces the targets of one provider. On error the previous
+// targets are kept, so that a DNS or file glitch does not drop every series.
+func (m *Manager) refresh(ctx context.Context, p Provider) {
+	found, err := p.Discover(ctx)
+	if err != nil {
+		logger.SafeWarn("discovery", "Target discovery failed, keeping previous targets", map[string]interface{}{
+			"source": p.Name(),
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	valid := make([]Target, 0, len(found))
+	for _, t := range found {
+		t.Source = p.Name()
+		if err := validateTarget(t); err != nil {
+			logger.SafeWarn("discovery", "Ignoring invalid target", map[string]interface{}{
+				"source": t.Source,
+				"server": t.Server,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		valid = append(valid, t)
+	}
+
+	m.mu.Lock()
+	for i := range valid {
+		valid[i].Labels = renameLabels(valid[i], m.constLabels)
+	}
+	m.sets[p.Name()] = valid
+	previous := m.targets
+	m.targets = m.merge()
+	total := len(m.targets)
+	removed := removedServers(previous, m.targets)
+	changed := len(removed) > 0 || !equalTargets(previous, m.targets)
+	onRemove := m.onRemove
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	logger.SafeInfo("discovery", "Discovered targets changed", map[string]interface{}{
+		"source":  p.Name(),
+		"targets": total,
+		"removed": removed,
+	})
+	if onRemove != nil && len(removed) > 0 {
+		onRemove(removed)
+	}
+}
+
+// renameLabels returns the labels of t, with those named like a constant
+// label prefixed with exported_
+func renameLabels(t Target, constLabels map[string]bool) map[string]string {
+	var renamed map[string]string
+	for name := range t.Labels {
+		if !constLabels[name] {
+			continue
+		}
+		if renamed == nil {
+			renamed = make(map[string]string, len(t.Labels))
+			for k, v := range t.Labels {
+				renamed[k] = v
+			}
+		}
+		delete(renamed, name)
+		renamed["exported_"+name] = t.Labels[name]
+		logger.SafeWarn("discovery", "Renaming target label clashing with a constant label", map[string]interface{}{
+			"source": t.Source,
+			"server": t.Server,
+			"label":  name,
+		})
+	}
+	if renamed == nil {
+		return t.Labels
+	}
+	return renamed
+}
+
+// merge combines the provider sets in provider order, first server wins.
+// Must be called with mu held.
+func (m *Manager) merge() []Target {
+	seen := make(map[string]bool)
+	merged := []Target{}
+	for _, p := range m.providers {
+		for _, t := range m.sets[p.Name()] {
+			if seen[t.Server] {
+				continue
+			}
+			seen[t.Server] = true
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// removedServers returns the servers of previous missing from current, sorted
+func removedServers(previous, current []Target) []string {
+	kept := make(map[string]bool, len(current))
+	for _, t := range current {
+		kept[t.Server] = true
+	}
+
+	var removed []string
+	for _, t := range previous {
+		if !kept[t.Server] {
+			removed = append(removed, t.Server)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// equalTargets reports whether two target lists are identical
+func equalTargets(a, b []Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Server != b[i].Server || a[i].Source != b[i].Source || !maps.Equal(a[i].Labels, b[i].Labels) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider returns the targets it is given and signals changes on demand
+type fakeProvider struct {
+	name    string
+	mu      sync.Mutex
+	targets []Target
+	err     error
+	changes chan struct{}
+}
+
+func newFakeProvider(name string, targets ...Target) *fakeProvider {
+	return &fakeProvider{name: name, targets: targets, changes: make(chan struct{})}
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Discover(context.Context) ([]Target, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.targets, p.err
+}
+
+func (p *fakeProvider) Watch(ctx context.Context, changed func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.changes:
+			changed()
+		}
+	}
+}
+
+func (p *fakeProvider) set(err error, targets ...Target) {
+	p.mu.Lock()
+	p.targets = targets
+	p.err = err
+	p.mu.Unlock()
+}
+
+func TestManager_MergesProviders(t *testing.T) {
+	srv := newFakeProvider("srv:_ntp._udp.example.com",
+		Target{Server: "time1.example.com"},
+		Target{Server: "time2.example.com:10123"},
+	)
+	file := newFakeProvider("file:targets.json",
+		Target{Server: "time1.example.com", Labels: map[string]string{"site": "par1"}},
+		Target{Server: "time3.example.com", Labels: map[string]string{"site": "ams1"}},
+	)
+
+	m := NewManager(srv, file)
+	m.Refresh(context.Background())
+
+	assert.Equal(t, []Target{
+		{Server: "time1.example.com", Source: "srv:_ntp._udp.example.com"},
+		{Server: "time2.example.com:10123", Source: "srv:_ntp._udp.example.com"},
+		{Server: "time3.example.com", Source: "file:targets.json", Labels: map[string]string{"site": "ams1"}},
+	}, m.Targets(), "the first provider wins for a server found twice")
+	assert.Equal(t, []string{"time1.example.com", "time2.example.com:10123", "time3.example.com"}, m.Servers())
+}
+
+func TestManager_DropsInvalidTargets(t *testing.T) {
+	p := newFakeProvider("file:targets.json",
+		Target{Server: "time.example.com"},
+		Target{Server: "localhost"},
+		Target{Server: "10.0.0.1"},
+		Target{Server: "time;rm -rf"},
+		Target{Server: "labelled.example.com", Labels: map[string]string{"server": "x"}},
+		Target{Server: "labelled2.example.com", Labels: map[string]string{"bad-name": "x"}},
+	)
+
+	m := NewManager(p)
+	m.Refresh(context.Background())
+
+	assert.Equal(t, []string{"time.example.com"}, m.Servers())
+}
+
+func TestManager_RenamesConstLabels(t *testing.T) {
+	labels := map[string]string{"env": "prod", "site": "par1"}
+	p := newFakeProvider("file:targets.json",
+		Target{Server: "time1.example.com", Labels: labels},
+		Target{Server: "time2.example.com", Labels: map[string]string{"site": "ams1"}},
+	)
+
+	m := NewManager(p)
+	m.SetConstLabels(map[string]string{"env": "staging", "node": "probe-1"})
+	m.Refresh(context.Background())
+
+	assert.Equal(t, []Target{
+		{Server: "time1.example.com", Source: "file:targets.json", Labels: map[string]string{"exported_env": "prod", "site": "par1"}},
+		{Server: "time2.example.com", Source: "file:targets.json", Labels: map[string]string{"site": "ams1"}},
+	}, m.Targets())
+	assert.Equal(t, map[string]string{"env": "prod", "site": "par1"}, labels, "the provider's labels are left alone")
+}
+
+func TestManager_ReportsRemovedServers(t *testing.T) {
+	p := newFakeProvider("file:targets.json",
+		Target{Server: "a.example.com"},
+		Target{Server: "b.example.com"},
+	)
+
+	var removed [][]string
+	m := NewManager(p)
+	m.OnRemove(func(servers []string) { removed = append(removed, servers) })
+
+	m.Refresh(context.Background())
+	assert.Empty(t, removed)
+
+	// A failing provider keeps its previous targets
+	p.set(errors.New("lookup failed"))
+	m.Refresh(context.Background())
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, m.Servers())
+	assert.Empty(t, removed)
+
+	p.set(nil, Target{Server: "b.example.com"}, Target{Server: "c.example.com"})
+	m.Refresh(context.Background())
+	assert.Equal(t, []string{"b.example.com", "c.example.com"}, m.Servers())
+	assert.Equal(t, [][]string{{"a.example.com"}}, removed)
+}
+
+func TestManager_Run(t *testing.T) {
+	p := newFakeProvider("file:targets.json", Target{Server: "a.example.com"})
+	m := NewManager(p)
+	m.Refresh(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	p.set(nil, Target{Server: "b.example.com"})
+	p.changes <- struct{}{}
+	assert.Eventually(t, func() bool {
+		servers := m.Servers()
+		return len(servers) == 1 && servers[0] == "b.example.com"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestNewManagerFromConfig(t *testing.T) {
+	assert.Nil(t, NewManagerFromConfig(config.DiscoveryConfig{}))
+
+	m := NewManagerFromConfig(config.DiscoveryConfig{
+		HostConfig:      true,
+		HostRoot:        "/host",
+		SRV:             []string{"_ntp._udp.example.com"},
+		Files:           []string{"/etc/ntp-exporter/targets.yml"},
+		RefreshInterval: time.Minute,
+	})
+	require.NotNil(t, m)
+	require.Len(t, m.providers, 3)
+	assert.Equal(t, HostConfigSource, m.providers[0].Name())
+	assert.Equal(t, "srv:_ntp._udp.example.com", m.providers[1].Name())
+	assert.Equal(t, "file:/etc/ntp-exporter/targets.yml", m.providers[2].Name())
+}
+
+func TestValidateLabelName(t *testing.T) {
+	for _, name := range []string{"site", "_private", "rack2"} {
+		assert.NoError(t, ValidateLabelName(name), name)
+	}
+	for _, name := range []string{"", "2rack", "bad-name", "__meta", "server", "source"} {
+		assert.Error(t, ValidateLabelName(name), name)
+	}
+}
@@ -0,0 +1,152 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/goccy/go-yaml"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+)
+
+// TargetGroup is one entry of a file_sd target file: servers sharing labels
+type TargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// FileProvider discovers targets from a Prometheus file_sd compatible JSON
+// or YAML file. The file is reloaded when it changes and every interval, in
+// case change notifications are not available (e.g. network filesystems).
+type FileProvider struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFileProvider creates a provider for a .json, .yml or .yaml target file
+func NewFileProvider(path string, interval time.Duration) *FileProvider {
+	return &FileProvider{
+		path:     filepath.Clean(path),
+		interval: interval,
+	}
+}
+
+// Name returns "file:" followed by the file path
+func (p *FileProvider) Name() string {
+	return "file:" + p.path
+}
+
+// Discover reads the target file. A missing file has no targets, so that
+// deleting it removes its targets.
+func (p *FileProvider) Discover(_ context.Context) ([]Target, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Target{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := ParseTargetGroups(p.path, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+
+	var targets []Target
+	for _, group := range groups {
+		for _, server := range group.Targets {
+			targets = append(targets, Target{Server: server, Labels: group.Labels})
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].Server < targets[j].Server })
+	return targets, nil
+}
+
+// ParseTargetGroups decodes file_sd target groups, as JSON or YAML depending
+// on the file extension. Unknown fields are errors.
+func ParseTargetGroups(path string, data []byte) ([]TargetGroup, error) {
+	var groups []TargetGroup
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&groups); err != nil {
+			return nil, err
+		}
+	case ".yml", ".yaml":
+		if err := yaml.UnmarshalWithOptions(data, &groups, yaml.DisallowUnknownField()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported target file extension %q (must be .json, .yml or .yaml)", filepath.Ext(path))
+	}
+
+	return groups, nil
+}
+
+// Watch calls changed when the target file is written, created, renamed or
+// removed, and every interval. The directory is watched rather than the file
+// so that atomic replacements (rename over the file) are seen.
+func (p *FileProvider) Watch(ctx context.Context, changed func()) {
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer watcher.Close()
+		err = watcher.Add(filepath.Dir(p.path))
+	}
+	if err != nil {
+		logger.SafeWarn("discovery", "Cannot watch target file, reloading it periodically only", map[string]interface{}{
+			"file":  p.path,
+			"error": err.Error(),
+		})
+	} else {
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	var tick <-chan time.Time
+	if p.interval > 0 {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(event.Name) == p.path && event.Op != fsnotify.Chmod {
+				changed()
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			logger.SafeWarn("discovery", "Target file watch error", map[string]interface{}{
+				"file":  p.path,
+				"error": err.Error(),
+			})
+		case <-tick:
+			changed()
+		}
+	}
+}
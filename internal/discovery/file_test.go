@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargetGroups(t *testing.T) {
+	json := `[{"targets": ["time1.example.com", "time2.example.com:10123"], "labels": {"site": "par1"}}]`
+	yml := "- targets: [time1.example.com, time2.example.com:10123]\n  labels:\n    site: par1\n"
+	want := []TargetGroup{{
+		Targets: []string{"time1.example.com", "time2.example.com:10123"},
+		Labels:  map[string]string{"site": "par1"},
+	}}
+
+	groups, err := ParseTargetGroups("targets.json", []byte(json))
+	require.NoError(t, err)
+	assert.Equal(t, want, groups)
+
+	for _, name := range []string{"targets.yml", "targets.YAML"} {
+		groups, err = ParseTargetGroups(name, []byte(yml))
+		require.NoError(t, err, name)
+		assert.Equal(t, want, groups, name)
+	}
+
+	_, err = ParseTargetGroups("targets.json", []byte(`[{"targets": [], "lables": {}}]`))
+	assert.Error(t, err, "unknown fields must be rejected")
+
+	_, err = ParseTargetGroups("targets.yml", []byte("- targets: []\n  lables: {}\n"))
+	assert.Error(t, err, "unknown fields must be rejected")
+
+	_, err = ParseTargetGroups("targets.txt", []byte(json))
+	assert.ErrorContains(t, err, "unsupported target file extension")
+}
+
+func TestFileProvider_Discover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	p := NewFileProvider(path, time.Minute)
+	assert.Equal(t, "file:"+path, p.Name())
+
+	// A missing file has no targets
+	targets, err := p.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, targets)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"targets": ["time2.example.com"], "labels": {"site": "par1"}},
+		{"targets": ["time1.example.com"]}
+	]`), 0o600))
+
+	targets, err = p.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Target{
+		{Server: "time1.example.com"},
+		{Server: "time2.example.com", Labels: map[string]string{"site": "par1"}},
+	}, targets)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"targets": `), 0o600))
+	_, err = p.Discover(context.Background())
+	assert.ErrorContains(t, err, path)
+}
+
+func TestFileProvider_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "targets.yml")
+	p := NewFileProvider(path, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		p.Watch(ctx, func() { changes <- struct{}{} })
+		close(done)
+	}()
+
+	// Writes to other files of the directory are ignored; the target file is
+	// rewritten until the watcher, started asynchronously, reports it
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yml"), []byte("x"), 0o600))
+	require.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(path, []byte("- targets: [time.example.com]\n"), 0o600))
+		select {
+		case <-changes:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch did not return after cancellation")
+	}
+}
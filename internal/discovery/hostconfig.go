@@ -0,0 +1,375 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HostConfigSource is the source of the targets read from the host's time
+// daemon configuration
+const HostConfigSource = "host_config"
+
+// Time daemons whose configuration is read, in order of precedence
+const (
+	DaemonChrony    = "chrony"
+	DaemonNTPD      = "ntpd"
+	DaemonTimesyncd = "timesyncd"
+)
+
+// Host paths of the time daemon configurations
+var (
+	chronyConfigs = []string{"/etc/chrony.conf", "/etc/chrony/chrony.conf"}
+
+	// ntpd prefers the file written by the Debian dhclient hook when present
+	ntpdConfigs = []string{"/run/ntp.conf.dhcp", "/etc/ntpsec/ntp.conf", "/etc/ntp.conf"}
+
+	timesyncdConfig   = "/etc/systemd/timesyncd.conf"
+	timesyncdDropIns  = []string{"/etc/systemd/timesyncd.conf.d/*.conf", "/run/systemd/timesyncd.conf.d/*.conf"}
+	networkdLeaseGlob = "/run/systemd/netif/leases/*"
+)
+
+// errNoHostConfig is returned when no time daemon configuration is found
+var errNoHostConfig = errors.New("no chrony, ntpd or systemd-timesyncd configuration found")
+
+// HostConfigProvider discovers the time sources the host is configured with,
+// reading the chrony, ntpd or systemd-timesyncd configuration (the first one
+// found, in that order) under root, where the host filesystem is mounted.
+// DHCP-provided servers are included the way each daemon gets them: chrony
+// sourcedir files, the ntpd dhclient hook file and systemd-networkd leases.
+type HostConfigProvider struct {
+	root     string
+	interval time.Duration
+}
+
+// NewHostConfigProvider creates a provider reading the host configuration
+// under root ("/" when empty), read again every interval
+func NewHostConfigProvider(root string, interval time.Duration) *HostConfigProvider {
+	if root == "" {
+		root = "/"
+	}
+	return &HostConfigProvider{
+		root:     root,
+		interval: interval,
+	}
+}
+
+// Name returns HostConfigSource
+func (p *HostConfigProvider) Name() string {
+	return HostConfigSource
+}
+
+// Discover returns the servers of the host's time daemon, labelled with the
+// daemon they were read from
+func (p *HostConfigProvider) Discover(_ context.Context) ([]Target, error) {
+	readers := []struct {
+		daemon string
+		read   func() ([]string, bool, error)
+	}{
+		{DaemonChrony, p.chronyServers},
+		{DaemonNTPD, p.ntpdServers},
+		{DaemonTimesyncd, p.timesyncdServers},
+	}
+
+	for _, reader := range readers {
+		servers, found, err := reader.read()
+		if err != nil {
+			return nil, fmt.Errorf("%s configuration: %w", reader.daemon, err)
+		}
+		if !found {
+			continue
+		}
+
+		targets := make([]Target, 0, len(servers))
+		for _, server := range uniqueStrings(servers) {
+			targets = append(targets, Target{Server: server, Labels: map[string]string{"daemon": reader.daemon}})
+		}
+		return targets, nil
+	}
+
+	return nil, fmt.Errorf("%w under %s", errNoHostConfig, p.root)
+}
+
+// Watch calls changed every interval until ctx is done; leases and
+// configuration files change rarely enough that polling is sufficient
+func (p *HostConfigProvider) Watch(ctx context.Context, changed func()) {
+	watchInterval(ctx, p.interval, changed)
+}
+
+// hostPath maps a path of the host to the mounted filesystem
+func (p *HostConfigProvider) hostPath(path string) string {
+	return filepath.Join(p.root, path)
+}
+
+// firstExisting returns the first of the host paths that exists
+func (p *HostConfigProvider) firstExisting(paths []string) (string, bool) {
+	for _, path := range paths {
+		if _, err := os.Stat(p.hostPath(path)); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// hostGlob returns the host files matching a pattern, sorted
+func (p *HostConfigProvider) hostGlob(pattern string) []string {
+	matches, _ := filepath.Glob(p.hostPath(pattern))
+	sort.Strings(matches)
+	return matches
+}
+
+// chronyServers reads server, pool and peer directives from chrony.conf and
+// the files it includes through include, confdir and sourcedir
+func (p *HostConfigProvider) chronyServers() ([]string, bool, error) {
+	path, found := p.firstExisting(chronyConfigs)
+	if !found {
+		return nil, false, nil
+	}
+
+	parser := &chronyParser{provider: p, visited: make(map[string]bool)}
+	if err := parser.parse(p.hostPath(path), false); err != nil {
+		return nil, true, err
+	}
+	return parser.servers, true, nil
+}
+
+// chronyParser follows the files included by a chrony configuration
+type chronyParser struct {
+	provider *HostConfigProvider
+	servers  []string
+	visited  map[string]bool
+}
+
+// parse reads one chrony file; sources files only hold source directives
+func (c *chronyParser) parse(path string, sourcesOnly bool) error {
+	if c.visited[path] {
+		return nil
+	}
+	c.visited[path] = true
+
+	return readConfigLines(path, "!;#%", func(fields []string) {
+		directive := strings.ToLower(fields[0])
+		args := fields[1:]
+
+		switch directive {
+		case "server", "pool", "peer":
+			if len(args) > 0 {
+				c.servers = append(c.servers, chronyServer(args))
+			}
+		case "include":
+			if sourcesOnly || len(args) == 0 {
+				return
+			}
+			for _, file := range c.provider.hostGlob(args[0]) {
+				_ = c.parse(file, false)
+			}
+		case "confdir":
+			if !sourcesOnly {
+				c.parseDirs(args, "*.conf", false)
+			}
+		case "sourcedir":
+			if !sourcesOnly {
+				c.parseDirs(args, "*.sources", true)
+			}
+		}
+	})
+}
+
+// parseDirs reads the files matching pattern in dirs, in file name order;
+// as in chrony, a file name found in several directories is read once
+func (c *chronyParser) parseDirs(dirs []string, pattern string, sourcesOnly bool) {
+	files := make(map[string]string)
+	for _, dir := range dirs {
+		for _, file := range c.provider.hostGlob(filepath.Join(dir, pattern)) {
+			if _, exists := files[filepath.Base(file)]; !exists {
+				files[filepath.Base(file)] = file
+			}
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_ = c.parse(files[name], sourcesOnly)
+	}
+}
+
+// chronyServer returns the address of a chrony source, with its port option
+// when it is not the NTP port
+func chronyServer(args []string) string {
+	for i := 1; i+1 < len(args); i++ {
+		if strings.ToLower(args[i]) == "port" && args[i+1] != "123" {
+			return net.JoinHostPort(args[0], args[i+1])
+		}
+	}
+	return args[0]
+}
+
+// ntpdServers reads server, pool and peer directives from ntp.conf and the
+// files it includes, skipping reference clocks (127.127.t.u)
+func (p *HostConfigProvider) ntpdServers() ([]string, bool, error) {
+	path, found := p.firstExisting(ntpdConfigs)
+	if !found {
+		return nil, false, nil
+	}
+
+	var servers []string
+	visited := make(map[string]bool)
+
+	var parse func(path string) error
+	parse = func(path string) error {
+		if visited[path] {
+			return nil
+		}
+		visited[path] = true
+
+		return readConfigLines(path, "#", func(fields []string) {
+			switch strings.ToLower(fields[0]) {
+			case "server", "pool", "peer":
+				// The address may follow a -4 or -6 option
+				args := fields[1:]
+				if len(args) > 0 && (args[0] == "-4" || args[0] == "-6") {
+					args = args[1:]
+				}
+				if len(args) > 0 && !strings.HasPrefix(args[0], "127.127.") {
+					servers = append(servers, args[0])
+				}
+			case "includefile":
+				if len(fields) > 1 {
+					_ = parse(p.hostPath(fields[1]))
+				}
+			}
+		})
+	}
+
+	if err := parse(p.hostPath(path)); err != nil {
+		return nil, true, err
+	}
+	return servers, true, nil
+}
+
+// timesyncdServers reads NTP= and FallbackNTP= from timesyncd.conf and its
+// drop-ins, and the servers of systemd-networkd DHCP leases. As in
+// timesyncd, the fallback servers are only used when there is no other.
+func (p *HostConfigProvider) timesyncdServers() ([]string, bool, error) {
+	files := []string{}
+	if _, err := os.Stat(p.hostPath(timesyncdConfig)); err == nil {
+		files = append(files, p.hostPath(timesyncdConfig))
+	}
+
+	// Drop-ins apply in file name order whatever their directory
+	var dropIns []string
+	for _, pattern := range timesyncdDropIns {
+		dropIns = append(dropIns, p.hostGlob(pattern)...)
+	}
+	sort.SliceStable(dropIns, func(i, j int) bool { return filepath.Base(dropIns[i]) < filepath.Base(dropIns[j]) })
+	files = append(files, dropIns...)
+
+	if len(files) == 0 {
+		return nil, false, nil
+	}
+
+	var servers, fallback []string
+	for _, file := range files {
+		section := ""
+		err := readConfigLines(file, "#;", func(fields []string) {
+			line := strings.Join(fields, " ")
+			if strings.HasPrefix(line, "[") {
+				section = strings.Trim(line, "[]")
+				return
+			}
+			if section != "Time" {
+				return
+			}
+
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return
+			}
+			switch strings.TrimSpace(key) {
+			case "NTP":
+				servers = appendOrReset(servers, value)
+			case "FallbackNTP":
+				fallback = appendOrReset(fallback, value)
+			}
+		})
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	for _, lease := range p.hostGlob(networkdLeaseGlob) {
+		err := readConfigLines(lease, "#", func(fields []string) {
+			if value, ok := strings.CutPrefix(fields[0], "NTP="); ok {
+				servers = append(servers, strings.Fields(value+" "+strings.Join(fields[1:], " "))...)
+			}
+		})
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	if len(servers) == 0 {
+		servers = fallback
+	}
+	return servers, true, nil
+}
+
+// appendOrReset applies a systemd list assignment: an empty value resets the
+// list, otherwise its space-separated items are appended
+func appendOrReset(list []string, value string) []string {
+	items := strings.Fields(value)
+	if len(items) == 0 {
+		return nil
+	}
+	return append(list, items...)
+}
+
+// readConfigLines calls fn with the fields of each line of a file, without
+// blank lines and lines starting with one of the comment characters. A
+// missing file has no lines.
+func readConfigLines(path, comments string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsRune(comments, rune(line[0])) {
+			continue
+		}
+		fn(strings.Fields(line))
+	}
+	return scanner.Err()
+}
+
+// uniqueStrings removes duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeHostFile writes a file of the host filesystem mounted at root
+func writeHostFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+}
+
+// hostServers returns the servers discovered under root and checks their labels
+func hostServers(t *testing.T, root, daemon string) []string {
+	t.Helper()
+	targets, err := NewHostConfigProvider(root, time.Minute).Discover(context.Background())
+	require.NoError(t, err)
+
+	servers := make([]string, 0, len(targets))
+	for _, target := range targets {
+		assert.Equal(t, map[string]string{"daemon": daemon}, target.Labels)
+		servers = append(servers, target.Server)
+	}
+	return servers
+}
+
+func TestHostConfigProvider_Chrony(t *testing.T) {
+	root := t.TempDir()
+	writeHostFile(t, root, "/etc/chrony/chrony.conf", `
+# Debian layout
+pool 2.debian.pool.ntp.org iburst
+server time.example.com iburst port 10123
+! server commented.example.com
+include /etc/chrony/extra.conf
+confdir /etc/chrony/conf.d
+sourcedir /run/chrony-dhcp /etc/chrony/sources.d
+refclock PHC /dev/ptp0
+`)
+	writeHostFile(t, root, "/etc/chrony/extra.conf", "peer peer.example.com\n")
+	writeHostFile(t, root, "/etc/chrony/conf.d/10-site.conf", "server site.example.com port 123\n")
+	writeHostFile(t, root, "/etc/chrony/conf.d/ignored.txt", "server ignored.example.com\n")
+	writeHostFile(t, root, "/run/chrony-dhcp/eth0.sources", "server dhcp.example.com iburst\ninclude /etc/other.conf\n")
+	writeHostFile(t, root, "/etc/chrony/sources.d/eth0.sources", "server shadowed.example.com\n")
+
+	assert.Equal(t, []string{
+		"2.debian.pool.ntp.org",
+		"time.example.com:10123",
+		"peer.example.com",
+		"site.example.com",
+		"dhcp.example.com",
+	}, hostServers(t, root, DaemonChrony))
+}
+
+func TestHostConfigProvider_NTPD(t *testing.T) {
+	root := t.TempDir()
+	writeHostFile(t, root, "/etc/ntp.conf", `
+server 127.127.1.0
+pool 0.pool.ntp.org iburst
+server time.example.com
+server -4 ipv4.example.com iburst
+pool -6 ipv6.pool.example.com
+includefile /etc/ntp/servers.conf
+`)
+	writeHostFile(t, root, "/etc/ntp/servers.conf", "peer peer.example.com\nserver time.example.com\n")
+
+	assert.Equal(t, []string{"0.pool.ntp.org", "time.example.com", "ipv4.example.com", "ipv6.pool.example.com", "peer.example.com"},
+		hostServers(t, root, DaemonNTPD))
+
+	// The dhclient hook file replaces ntp.conf
+	writeHostFile(t, root, "/run/ntp.conf.dhcp", "server dhcp.example.com\n")
+	assert.Equal(t, []string{"dhcp.example.com"}, hostServers(t, root, DaemonNTPD))
+}
+
+func TestHostConfigProvider_Timesyncd(t *testing.T) {
+	root := t.TempDir()
+	writeHostFile(t, root, "/etc/systemd/timesyncd.conf", `
+[Time]
+#NTP=
+FallbackNTP=0.debian.pool.ntp.org 1.debian.pool.ntp.org
+`)
+
+	assert.Equal(t, []string{"0.debian.pool.ntp.org", "1.debian.pool.ntp.org"},
+		hostServers(t, root, DaemonTimesyncd), "fallback servers are used when there is no other")
+
+	writeHostFile(t, root, "/etc/systemd/timesyncd.conf.d/10-site.conf", "[Time]\nNTP=a.example.com b.example.com\n")
+	writeHostFile(t, root, "/run/systemd/timesyncd.conf.d/20-reset.conf", "[Time]\nNTP=\nNTP=c.example.com\n")
+	writeHostFile(t, root, "/run/systemd/netif/leases/2", "# This is private data.\nADDRESS=192.0.2.10\nNTP=dhcp.example.com\n")
+
+	assert.Equal(t, []string{"c.example.com", "dhcp.example.com"}, hostServers(t, root, DaemonTimesyncd))
+}
+
+func TestHostConfigProvider_Precedence(t *testing.T) {
+	root := t.TempDir()
+	writeHostFile(t, root, "/etc/systemd/timesyncd.conf", "[Time]\nNTP=timesyncd.example.com\n")
+	writeHostFile(t, root, "/etc/chrony.conf", "server chrony.example.com\n")
+
+	assert.Equal(t, []string{"chrony.example.com"}, hostServers(t, root, DaemonChrony))
+}
+
+func TestHostConfigProvider_NoConfig(t *testing.T) {
+	p := NewHostConfigProvider(t.TempDir(), time.Minute)
+	assert.Equal(t, HostConfigSource, p.Name())
+
+	_, err := p.Discover(context.Background())
+	assert.ErrorIs(t, err, errNoHostConfig)
+}
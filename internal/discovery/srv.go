@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNTPPort is left out of discovered server addresses
+const defaultNTPPort = 123
+
+// SRVResolver looks up SRV records; *net.Resolver implements it
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVProvider discovers the targets of a DNS SRV record such as
+// _ntp._udp.example.com, looked up again every interval
+type SRVProvider struct {
+	name     string
+	interval time.Duration
+	resolver SRVResolver
+}
+
+// NewSRVProvider creates a provider for the SRV record name. A nil resolver
+// uses the system resolver.
+func NewSRVProvider(name string, interval time.Duration, resolver SRVResolver) *SRVProvider {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &SRVProvider{
+		name:     name,
+		interval: interval,
+		resolver: resolver,
+	}
+}
+
+// Name returns "srv:" followed by the record name
+func (p *SRVProvider) Name() string {
+	return "srv:" + p.name
+}
+
+// Discover looks the SRV record up. Targets keep their port unless it is
+// the NTP port, and the "." target (service not available) is skipped.
+func (p *SRVProvider) Discover(ctx context.Context) ([]Target, error) {
+	_, records, err := p.resolver.LookupSRV(ctx, "", "", p.name)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		if host == "" {
+			continue
+		}
+
+		server := host
+		if srv.Port != 0 && srv.Port != defaultNTPPort {
+			server = net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))
+		}
+		targets = append(targets, Target{Server: server})
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Server < targets[j].Server })
+	return targets, nil
+}
+
+// Watch calls changed every interval until ctx is done
+func (p *SRVProvider) Watch(ctx context.Context, changed func()) {
+	watchInterval(ctx, p.interval, changed)
+}
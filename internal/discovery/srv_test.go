@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSRVResolver answers SRV lookups from a fixed table
+type fakeSRVResolver struct {
+	records map[string][]*net.SRV
+}
+
+func (r fakeSRVResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	records, ok := r.records[name]
+	if !ok || service != "" || proto != "" {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+func TestSRVProvider_Discover(t *testing.T) {
+	resolver := fakeSRVResolver{records: map[string][]*net.SRV{
+		"_ntp._udp.example.com": {
+			{Target: "time2.example.com.", Port: 123, Priority: 10},
+			{Target: "time1.example.com.", Port: 10123, Priority: 20},
+			{Target: ".", Port: 0},
+		},
+	}}
+
+	p := NewSRVProvider("_ntp._udp.example.com", time.Minute, resolver)
+	assert.Equal(t, "srv:_ntp._udp.example.com", p.Name())
+
+	targets, err := p.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Target{
+		{Server: "time1.example.com:10123"},
+		{Server: "time2.example.com"},
+	}, targets)
+}
+
+func TestSRVProvider_DiscoverError(t *testing.T) {
+	p := NewSRVProvider("_ntp._udp.missing.example", time.Minute, fakeSRVResolver{})
+
+	_, err := p.Discover(context.Background())
+
+	var dnsErr *net.DNSError
+	require.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+}
+
+func TestSRVProvider_Watch(t *testing.T) {
+	p := NewSRVProvider("_ntp._udp.example.com", 10*time.Millisecond, fakeSRVResolver{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	p.Watch(ctx, func() { calls++ })
+
+	assert.GreaterOrEqual(t, calls, 2, "changed should be called every interval")
+}
@@ -0,0 +1,70 @@
+package ntp
+
+import (
+	"net"
+)
+
+// Address family modes selecting which resolved addresses of a server are queried
+const (
+	AddressFamilyDefault = ""              // The NTP library resolves and picks one address
+	AddressFamilyIPv4    = "ipv4"          // First IPv4 address only
+	AddressFamilyIPv6    = "ipv6"          // First IPv6 address only
+	AddressFamilyBoth    = "both"          // First IPv4 and first IPv6 address
+	AddressFamilyAll     = "all_addresses" // Every resolved address
+)
+
+// ServerAddress is one resolved address of an NTP server
+type ServerAddress struct {
+	IP     string
+	Family string // AddressFamilyIPv4 or AddressFamilyIPv6
+}
+
+// AddressFamilyOf returns AddressFamilyIPv4 or AddressFamilyIPv6 for an IP
+// address, or an empty string when ip is not an IP address
+func AddressFamilyOf(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return AddressFamilyIPv4
+	default:
+		return AddressFamilyIPv6
+	}
+}
+
+// SelectAddresses returns the addresses to query among the resolved ips for
+// an address family mode, keeping the resolver order and dropping duplicates
+func SelectAddresses(ips []string, mode string) []ServerAddress {
+	seen := make(map[string]bool, len(ips))
+	selected := make([]ServerAddress, 0, len(ips))
+	families := make(map[string]bool, 2)
+
+	for _, ip := range ips {
+		family := AddressFamilyOf(ip)
+		if family == "" || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+
+		switch mode {
+		case AddressFamilyIPv4, AddressFamilyIPv6:
+			if family != mode || families[family] {
+				continue
+			}
+		case AddressFamilyBoth:
+			if families[family] {
+				continue
+			}
+		case AddressFamilyAll:
+		default:
+			if len(selected) > 0 {
+				continue
+			}
+		}
+
+		families[family] = true
+		selected = append(selected, ServerAddress{IP: ip, Family: family})
+	}
+	return selected
+}
@@ -0,0 +1,40 @@
+package ntp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressFamilyOf(t *testing.T) {
+	assert.Equal(t, AddressFamilyIPv4, AddressFamilyOf("192.0.2.1"))
+	assert.Equal(t, AddressFamilyIPv6, AddressFamilyOf("2001:db8::1"))
+	assert.Equal(t, AddressFamilyIPv4, AddressFamilyOf("::ffff:192.0.2.1"))
+	assert.Empty(t, AddressFamilyOf("time.example"))
+}
+
+func TestSelectAddresses(t *testing.T) {
+	ips := []string{"2001:db8::1", "192.0.2.1", "192.0.2.2", "2001:db8::2", "192.0.2.1", "not-an-ip"}
+
+	v4 := func(ip string) ServerAddress { return ServerAddress{IP: ip, Family: AddressFamilyIPv4} }
+	v6 := func(ip string) ServerAddress { return ServerAddress{IP: ip, Family: AddressFamilyIPv6} }
+
+	tests := []struct {
+		mode string
+		want []ServerAddress
+	}{
+		{AddressFamilyDefault, []ServerAddress{v6("2001:db8::1")}},
+		{AddressFamilyIPv4, []ServerAddress{v4("192.0.2.1")}},
+		{AddressFamilyIPv6, []ServerAddress{v6("2001:db8::1")}},
+		{AddressFamilyBoth, []ServerAddress{v6("2001:db8::1"), v4("192.0.2.1")}},
+		{AddressFamilyAll, []ServerAddress{v6("2001:db8::1"), v4("192.0.2.1"), v4("192.0.2.2"), v6("2001:db8::2")}},
+	}
+
+	for _, tt := range tests {
+		t.Run("mode_"+tt.mode, func(t *testing.T) {
+			assert.Equal(t, tt.want, SelectAddresses(ips, tt.mode))
+		})
+	}
+
+	assert.Empty(t, SelectAddresses([]string{"192.0.2.1"}, AddressFamilyIPv6))
+}
@@ -0,0 +1,146 @@
+package ntp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+)
+
+// Kiss-of-Death codes a client must act upon (RFC 5905 section 7.4)
+const (
+	KissCodeRate = "RATE" // Reduce the polling rate
+	KissCodeDeny = "DENY" // Access denied, stop querying
+	KissCodeRstr = "RSTR" // Access restricted, stop querying
+)
+
+// ErrBackoff is returned for queries to a server backing off after a Kiss-of-Death.
+var ErrBackoff = errors.New("backing off after Kiss-of-Death")
+
+// BackoffState is the Kiss-of-Death backoff of one server.
+type BackoffState struct {
+	Server string    `json:"server"`
+	Code   string    `json:"kod"`
+	Count  int       `json:"count"` // Consecutive RATE codes (doubles the backoff each time)
+	Until  time.Time `json:"until"`
+}
+
+// Remaining returns how long the server is still suspended at now.
+func (s BackoffState) Remaining(now time.Time) time.Duration {
+	if now.After(s.Until) {
+		return 0
+	}
+	return s.Until.Sub(now)
+}
+
+// KoDBackoff suspends servers that sent a Kiss-of-Death: RATE doubles the
+// backoff from base up to max, DENY and RSTR suspend the server for suspend.
+// Other kiss codes are informational and ignored.
+type KoDBackoff struct {
+	base    time.Duration
+	max     time.Duration
+	suspend time.Duration
+
+	mu     sync.Mutex
+	states map[string]BackoffState
+	now    func() time.Time
+}
+
+// NewKoDBackoff creates a Kiss-of-Death backoff tracker.
+func NewKoDBackoff(base, max, suspend time.Duration) *KoDBackoff {
+	if max < base {
+		max = base
+	}
+	return &KoDBackoff{
+		base:    base,
+		max:     max,
+		suspend: suspend,
+		states:  make(map[string]BackoffState),
+		now:     time.Now,
+	}
+}
+
+// Check returns an ErrBackoff error while the server is suspended.
+func (b *KoDBackoff) Check(server string) error {
+	b.mu.Lock()
+	state, exists := b.states[server]
+	b.mu.Unlock()
+
+	if !exists || state.Remaining(b.now()) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w %s from %s: suspended until %s", ErrBackoff, state.Code, server, state.Until.Format(time.RFC3339))
+}
+
+// Record handles the kiss code of a response. It reports whether the server
+// is now suspended.
+func (b *KoDBackoff) Record(server, code string) bool {
+	now := b.now()
+
+	b.mu.Lock()
+	state := b.states[server]
+	switch code {
+	case KissCodeRate:
+		count := 1
+		if state.Code == KissCodeRate {
+			count = state.Count + 1
+		}
+		state = BackoffState{Server: server, Code: code, Count: count, Until: now.Add(b.rateDelay(count))}
+	case KissCodeDeny, KissCodeRstr:
+		state = BackoffState{Server: server, Code: code, Count: 1, Until: now.Add(b.suspend)}
+	default:
+		b.mu.Unlock()
+		return false
+	}
+	b.states[server] = state
+	b.mu.Unlock()
+
+	logger.SafeWarn("ntp", "Kiss-of-Death received, backing off", map[string]interface{}{
+		"server":  server,
+		"kod":     code,
+		"count":   state.Count,
+		"backoff": state.Until.Sub(now).String(),
+	})
+	return true
+}
+
+// rateDelay returns base * 2^(count-1), capped at max
+func (b *KoDBackoff) rateDelay(count int) time.Duration {
+	delay := b.base
+	for i := 1; i < count && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	return delay
+}
+
+// RecordSuccess clears the backoff of a server that answered normally.
+func (b *KoDBackoff) RecordSuccess(server string) {
+	b.mu.Lock()
+	delete(b.states, server)
+	b.mu.Unlock()
+}
+
+// Snapshot returns the servers currently suspended, sorted by server.
+func (b *KoDBackoff) Snapshot() []BackoffState {
+	now := b.now()
+
+	b.mu.Lock()
+	states := make([]BackoffState, 0, len(b.states))
+	for _, state := range b.states {
+		if state.Remaining(now) > 0 {
+			states = append(states, state)
+		}
+	}
+	b.mu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Server < states[j].Server
+	})
+	return states
+}
@@ -0,0 +1,120 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBackoff returns a backoff tracker with a controllable clock
+func newTestBackoff(now *time.Time) *KoDBackoff {
+	b := NewKoDBackoff(time.Minute, 10*time.Minute, 24*time.Hour)
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestKoDBackoff_RateIsExponential(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBackoff(&now)
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		require.True(t, b.Record("a.example", KissCodeRate))
+		state := b.Snapshot()[0]
+		delays = append(delays, state.Remaining(now))
+		now = state.Until // expire before the next RATE
+	}
+
+	assert.Equal(t, []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute,
+	}, delays)
+}
+
+func TestKoDBackoff_Check(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBackoff(&now)
+
+	assert.NoError(t, b.Check("a.example"))
+
+	b.Record("a.example", KissCodeRate)
+	err := b.Check("a.example")
+	require.ErrorIs(t, err, ErrBackoff)
+	assert.Contains(t, err.Error(), "RATE")
+
+	now = now.Add(time.Minute + time.Second)
+	assert.NoError(t, b.Check("a.example"))
+	assert.Empty(t, b.Snapshot())
+}
+
+func TestKoDBackoff_DenyAndRstrSuspend(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBackoff(&now)
+
+	b.Record("deny.example", KissCodeDeny)
+	b.Record("rstr.example", KissCodeRstr)
+
+	states := b.Snapshot()
+	require.Len(t, states, 2)
+	for _, state := range states {
+		assert.Equal(t, 24*time.Hour, state.Remaining(now), state.Server)
+	}
+
+	now = now.Add(23 * time.Hour)
+	assert.ErrorIs(t, b.Check("deny.example"), ErrBackoff)
+}
+
+func TestKoDBackoff_SuccessResetsAndOtherCodesIgnored(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBackoff(&now)
+
+	b.Record("a.example", KissCodeRate)
+	now = now.Add(2 * time.Minute)
+	b.RecordSuccess("a.example")
+
+	// The next RATE starts over from the base backoff
+	b.Record("a.example", KissCodeRate)
+	assert.Equal(t, time.Minute, b.Snapshot()[0].Remaining(now))
+
+	assert.False(t, b.Record("b.example", "INIT"))
+	assert.NoError(t, b.Check("b.example"))
+}
+
+func TestClient_QuerySkipsServerInBackoff(t *testing.T) {
+	client := NewClient(time.Second, 4)
+	backoff := NewKoDBackoff(time.Hour, time.Hour, time.Hour)
+	client.SetKoDBackoff(backoff)
+	backoff.Record("192.0.2.1", KissCodeDeny)
+
+	_, err := client.Query(context.Background(), "192.0.2.1")
+	assert.ErrorIs(t, err, ErrBackoff)
+
+	_, err = client.QueryMultiple(context.Background(), "192.0.2.1", 3)
+	assert.ErrorIs(t, err, ErrBackoff)
+}
+
+func TestCircuitBreakerClient_BackoffIsNotAFailure(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetError("rate.example", fmt.Errorf("%w RATE from rate.example", ErrBackoff))
+	mockClient.SetError("down.example", errors.New("timeout"))
+
+	cb := NewCircuitBreakerClient(mockClient, CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Minute,
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 2 },
+	})
+
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Query(context.Background(), "rate.example")
+		_, _ = cb.Query(context.Background(), "down.example")
+	}
+
+	assert.Equal(t, gobreaker.StateClosed, cb.GetState("rate.example"))
+	assert.Equal(t, gobreaker.StateOpen, cb.GetState("down.example"))
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
 	"github.com/sony/gobreaker"
 )
 
@@ -16,6 +18,28 @@ type CircuitBreakerClient struct {
 	breakers map[string]*gobreaker.CircuitBreaker
 	mu       sync.RWMutex
 	config   CircuitBreakerConfig
+
+	// Last state change per server. Guarded by its own mutex because
+	// gobreaker reports transitions from inside State() calls made under mu.
+	transitions  map[string]time.Time
+	transitionMu sync.Mutex
+
+	overrides *OverrideStore
+}
+
+// BreakerSnapshot is a point-in-time view of one server's circuit breaker.
+// Counts cover the current closed-state interval (or half-open probe window).
+type BreakerSnapshot struct {
+	Server               string    `json:"server"`
+	State                string    `json:"state"`
+	Requests             uint32    `json:"requests"`
+	TotalSuccesses       uint32    `json:"total_successes"`
+	TotalFailures        uint32    `json:"total_failures"`
+	ConsecutiveSuccesses uint32    `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32    `json:"consecutive_failures"`
+	LastTransition       time.Time `json:"last_transition,omitzero"`
+	RetryAt              time.Time `json:"retry_at,omitzero"`  // When an open breaker lets a probe through
+	Override             *Override `json:"override,omitempty"` // Manual override taking precedence over State
 }
 
 // CircuitBreakerConfig holds configuration for circuit breakers.
@@ -36,6 +60,10 @@ type CircuitBreakerConfig struct {
 	// If ReadyToTrip returns true, the CircuitBreaker will be placed into the open state.
 	// If ReadyToTrip is nil, default behavior is: consecutiveFailures > 5
 	ReadyToTrip func(counts gobreaker.Counts) bool
+
+	// OnStateChange is called whenever a server's breaker changes state.
+	// It runs under the breaker's lock and must not call back into the client.
+	OnStateChange func(server string, from, to gobreaker.State)
 }
 
 // DefaultCircuitBreakerConfig returns sensible defaults for circuit breaker configuration.
@@ -67,16 +95,31 @@ func NewCircuitBreakerConfigWithThreshold(maxRequests uint32, interval, timeout
 // NewCircuitBreakerClient creates a new circuit breaker protected NTP client.
 func NewCircuitBreakerClient(querier NTPQuerier, config CircuitBreakerConfig) *CircuitBreakerClient {
 	if config.MaxRequests == 0 {
+		onStateChange := config.OnStateChange
 		config = DefaultCircuitBreakerConfig()
+		config.OnStateChange = onStateChange
 	}
 
 	return &CircuitBreakerClient{
-		querier:  querier,
-		breakers: make(map[string]*gobreaker.CircuitBreaker),
-		config:   config,
+		querier:     querier,
+		breakers:    make(map[string]*gobreaker.CircuitBreaker),
+		config:      config,
+		transitions: make(map[string]time.Time),
+		overrides:   NewOverrideStore(),
 	}
 }
 
+// SetOverrides replaces the override store, so that the overrides are held
+// by the caller rather than the client.
+func (cb *CircuitBreakerClient) SetOverrides(store *OverrideStore) {
+	cb.overrides = store
+}
+
+// Overrides returns the override store consulted before each query.
+func (cb *CircuitBreakerClient) Overrides() *OverrideStore {
+	return cb.overrides
+}
+
 // getBreakerForServer returns or creates a circuit breaker for the given server.
 func (cb *CircuitBreakerClient) getBreakerForServer(server string) *gobreaker.CircuitBreaker {
 	cb.mu.RLock()
@@ -95,27 +138,124 @@ func (cb *CircuitBreakerClient) getBreakerForServer(server string) *gobreaker.Ci
 		return breaker
 	}
 
-	// Create new circuit breaker for this server
-	breaker = gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:        server,
-		MaxRequests: cb.config.MaxRequests,
-		Interval:    cb.config.Interval,
-		Timeout:     cb.config.Timeout,
-		ReadyToTrip: cb.config.ReadyToTrip,
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			// Log state change (could be integrated with Prometheus metrics)
-			_ = name
-			_ = from
-			_ = to
-		},
-	})
-
+	breaker = cb.newBreaker(server)
 	cb.breakers[server] = breaker
 	return breaker
 }
 
+// newBreaker creates a closed circuit breaker for the given server.
+func (cb *CircuitBreakerClient) newBreaker(server string) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:          server,
+		MaxRequests:   cb.config.MaxRequests,
+		Interval:      cb.config.Interval,
+		Timeout:       cb.config.Timeout,
+		ReadyToTrip:   cb.config.ReadyToTrip,
+		OnStateChange: cb.onStateChange,
+		IsSuccessful:  isBreakerSuccess,
+	})
+}
+
+// isBreakerSuccess tells the breaker which errors are not server failures:
+// a Kiss-of-Death backoff means the server answered and asked us to wait
+func isBreakerSuccess(err error) bool {
+	return err == nil || errors.Is(err, ErrBackoff)
+}
+
+// Reset replaces a server's breaker with a closed one, clearing its counts,
+// and reports whether the server had a breaker.
+func (cb *CircuitBreakerClient) Reset(server string) bool {
+	cb.mu.Lock()
+	breaker, exists := cb.breakers[server]
+	var from gobreaker.State
+	if exists {
+		from = breaker.State()
+		cb.breakers[server] = cb.newBreaker(server)
+	}
+	cb.mu.Unlock()
+
+	if exists && from != gobreaker.StateClosed {
+		cb.onStateChange(server, from, gobreaker.StateClosed)
+	}
+
+	return exists
+}
+
+// Forget drops a server's breaker and last transition, for servers no longer
+// queried, and reports whether the server had a breaker. Manual overrides
+// are kept.
+func (cb *CircuitBreakerClient) Forget(server string) bool {
+	cb.mu.Lock()
+	_, exists := cb.breakers[server]
+	delete(cb.breakers, server)
+	cb.mu.Unlock()
+
+	cb.transitionMu.Lock()
+	delete(cb.transitions, server)
+	cb.transitionMu.Unlock()
+
+	return exists
+}
+
+// ResetAll resets every breaker and returns the affected servers, sorted.
+func (cb *CircuitBreakerClient) ResetAll() []string {
+	cb.mu.RLock()
+	servers := make([]string, 0, len(cb.breakers))
+	for server := range cb.breakers {
+		servers = append(servers, server)
+	}
+	cb.mu.RUnlock()
+
+	sort.Strings(servers)
+	for _, server := range servers {
+		cb.Reset(server)
+	}
+
+	return servers
+}
+
+// activeOverride returns the manual override for a server, if any.
+func (cb *CircuitBreakerClient) activeOverride(server string) (Override, bool) {
+	if cb.overrides == nil {
+		return Override{}, false
+	}
+	return cb.overrides.Get(server)
+}
+
+// forcedOpenError describes a query refused by an OverrideOpen override.
+func forcedOpenError(o Override) error {
+	if o.Reason == "" {
+		return fmt.Errorf("%w for %s", ErrForcedOpen, o.Server)
+	}
+	return fmt.Errorf("%w for %s: %s", ErrForcedOpen, o.Server, o.Reason)
+}
+
+// onStateChange records and reports a breaker transition
+func (cb *CircuitBreakerClient) onStateChange(server string, from, to gobreaker.State) {
+	cb.transitionMu.Lock()
+	cb.transitions[server] = time.Now()
+	cb.transitionMu.Unlock()
+
+	logger.SafeInfo("circuitbreaker", "Circuit breaker state changed", map[string]interface{}{
+		"server": server,
+		"from":   from.String(),
+		"to":     to.String(),
+	})
+
+	if cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(server, from, to)
+	}
+}
+
 // Query performs a single NTP query with circuit breaker protection.
 func (cb *CircuitBreakerClient) Query(ctx context.Context, server string) (*Response, error) {
+	if o, ok := cb.activeOverride(server); ok {
+		if o.Mode == OverrideOpen {
+			return nil, newQueryError(server, forcedOpenError(o))
+		}
+		return cb.querier.Query(ctx, server)
+	}
+
 	breaker := cb.getBreakerForServer(server)
 
 	result, err := breaker.Execute(func() (interface{}, error) {
@@ -125,7 +265,7 @@ func (cb *CircuitBreakerClient) Query(ctx context.Context, server string) (*Resp
 	if err != nil {
 		// Check if circuit breaker is open
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			return nil, fmt.Errorf("circuit breaker open for %s: %w", server, err)
+			return nil, newQueryError(server, fmt.Errorf("circuit breaker open for %s: %w", server, err))
 		}
 		return nil, err
 	}
@@ -135,6 +275,13 @@ func (cb *CircuitBreakerClient) Query(ctx context.Context, server string) (*Resp
 
 // QueryMultiple performs multiple NTP queries with circuit breaker protection.
 func (cb *CircuitBreakerClient) QueryMultiple(ctx context.Context, server string, samples int) ([]*Response, error) {
+	if o, ok := cb.activeOverride(server); ok {
+		if o.Mode == OverrideOpen {
+			return nil, newQueryError(server, forcedOpenError(o))
+		}
+		return cb.querier.QueryMultiple(ctx, server, samples)
+	}
+
 	breaker := cb.getBreakerForServer(server)
 
 	result, err := breaker.Execute(func() (interface{}, error) {
@@ -143,7 +290,7 @@ func (cb *CircuitBreakerClient) QueryMultiple(ctx context.Context, server string
 
 	if err != nil {
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			return nil, fmt.Errorf("circuit breaker open for %s: %w", server, err)
+			return nil, newQueryError(server, fmt.Errorf("circuit breaker open for %s: %w", server, err))
 		}
 		return nil, err
 	}
@@ -189,3 +336,59 @@ func (cb *CircuitBreakerClient) GetAllStates() map[string]gobreaker.State {
 
 	return states
 }
+
+// Snapshot returns the state and counts of every known breaker, sorted by server.
+func (cb *CircuitBreakerClient) Snapshot() []BreakerSnapshot {
+	cb.mu.RLock()
+	snapshots := make([]BreakerSnapshot, 0, len(cb.breakers))
+	for server, breaker := range cb.breakers {
+		// State() first: it may move an expired open breaker to half-open
+		state := breaker.State()
+		counts := breaker.Counts()
+		snapshots = append(snapshots, BreakerSnapshot{
+			Server:               server,
+			State:                state.String(),
+			Requests:             counts.Requests,
+			TotalSuccesses:       counts.TotalSuccesses,
+			TotalFailures:        counts.TotalFailures,
+			ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+			ConsecutiveFailures:  counts.ConsecutiveFailures,
+		})
+	}
+	cb.mu.RUnlock()
+
+	if cb.overrides != nil {
+		known := make(map[string]int, len(snapshots))
+		for i := range snapshots {
+			known[snapshots[i].Server] = i
+		}
+		for _, o := range cb.overrides.List() {
+			o := o
+			if i, ok := known[o.Server]; ok {
+				snapshots[i].Override = &o
+				continue
+			}
+			// Forced open before any query went through its breaker
+			snapshots = append(snapshots, BreakerSnapshot{
+				Server:   o.Server,
+				State:    gobreaker.StateClosed.String(),
+				Override: &o,
+			})
+		}
+	}
+
+	cb.transitionMu.Lock()
+	for i := range snapshots {
+		snapshots[i].LastTransition = cb.transitions[snapshots[i].Server]
+		if snapshots[i].State == gobreaker.StateOpen.String() && !snapshots[i].LastTransition.IsZero() {
+			snapshots[i].RetryAt = snapshots[i].LastTransition.Add(cb.config.Timeout)
+		}
+	}
+	cb.transitionMu.Unlock()
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Server < snapshots[j].Server
+	})
+
+	return snapshots
+}
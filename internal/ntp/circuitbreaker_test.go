@@ -327,3 +327,176 @@ func BenchmarkCircuitBreakerClient_QueryMultiple(b *testing.B) {
 		_, _ = cb.QueryMultiple(ctx, "bench-multi", 5)
 	}
 }
+
+func TestCircuitBreakerClient_OnStateChange(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetError("bad.ntp.org", errors.New("network error"))
+
+	type transition struct {
+		server   string
+		from, to gobreaker.State
+	}
+	var transitions []transition
+
+	config := CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    1 * time.Second,
+		Timeout:     50 * time.Millisecond,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 2
+		},
+		OnStateChange: func(server string, from, to gobreaker.State) {
+			transitions = append(transitions, transition{server, from, to})
+		},
+	}
+
+	cb := NewCircuitBreakerClient(mockClient, config)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, _ = cb.Query(ctx, "bad.ntp.org")
+	}
+	require.Len(t, transitions, 1)
+	assert.Equal(t, transition{"bad.ntp.org", gobreaker.StateClosed, gobreaker.StateOpen}, transitions[0])
+
+	time.Sleep(75 * time.Millisecond)
+	assert.Equal(t, gobreaker.StateHalfOpen, cb.GetState("bad.ntp.org"))
+	require.Len(t, transitions, 2)
+	assert.Equal(t, gobreaker.StateHalfOpen, transitions[1].to)
+}
+
+func TestCircuitBreakerClient_OnStateChangeKeptWithDefaults(t *testing.T) {
+	called := false
+	cb := NewCircuitBreakerClient(NewMockNTPClient(), CircuitBreakerConfig{
+		OnStateChange: func(string, gobreaker.State, gobreaker.State) { called = true },
+	})
+
+	require.NotNil(t, cb.config.OnStateChange)
+	cb.config.OnStateChange("a", gobreaker.StateClosed, gobreaker.StateOpen)
+	assert.True(t, called)
+}
+
+func TestCircuitBreakerClient_Snapshot(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetError("bad.ntp.org", errors.New("network error"))
+	mockClient.SetupSuccessfulServer("good.ntp.org", time.Millisecond, 2)
+
+	config := CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Minute,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 2
+		},
+	}
+
+	cb := NewCircuitBreakerClient(mockClient, config)
+	ctx := context.Background()
+
+	_, err := cb.Query(ctx, "good.ntp.org")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _ = cb.Query(ctx, "bad.ntp.org")
+	}
+
+	snapshots := cb.Snapshot()
+	require.Len(t, snapshots, 2)
+
+	bad := snapshots[0]
+	assert.Equal(t, "bad.ntp.org", bad.Server)
+	assert.Equal(t, "open", bad.State)
+	assert.False(t, bad.LastTransition.IsZero())
+	assert.Equal(t, bad.LastTransition.Add(time.Minute), bad.RetryAt)
+
+	good := snapshots[1]
+	assert.Equal(t, "good.ntp.org", good.Server)
+	assert.Equal(t, "closed", good.State)
+	assert.Equal(t, uint32(1), good.Requests)
+	assert.Equal(t, uint32(1), good.TotalSuccesses)
+	assert.True(t, good.RetryAt.IsZero())
+}
+
+func TestCircuitBreakerClient_Overrides(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetupSuccessfulServer("good.example", time.Millisecond, 1)
+	mockClient.SetupUnreachableServer("bad.example")
+
+	cb := NewCircuitBreakerClient(mockClient, CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Minute,
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+	ctx := context.Background()
+
+	_, err := cb.Overrides().Set("good.example", OverrideOpen, time.Hour, "maintenance")
+	require.NoError(t, err)
+
+	_, err = cb.Query(ctx, "good.example")
+	require.ErrorIs(t, err, ErrForcedOpen)
+	assert.Contains(t, err.Error(), "maintenance")
+	assert.Equal(t, 0, mockClient.GetCallCount("good.example"), "forced open server must not be queried")
+
+	// Trip bad.example, then pin it closed: queries go through regardless
+	_, _ = cb.Query(ctx, "bad.example")
+	require.Equal(t, gobreaker.StateOpen, cb.GetState("bad.example"))
+	_, err = cb.Overrides().Set("bad.example", OverrideClosed, 0, "")
+	require.NoError(t, err)
+
+	_, _ = cb.QueryMultiple(ctx, "bad.example", 1)
+	assert.Equal(t, 2, mockClient.GetCallCount("bad.example"))
+
+	snapshots := cb.Snapshot()
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "bad.example", snapshots[0].Server)
+	require.NotNil(t, snapshots[0].Override)
+	assert.Equal(t, OverrideClosed, snapshots[0].Override.Mode)
+	assert.Equal(t, "good.example", snapshots[1].Server, "forced open servers are listed even without a breaker")
+	require.NotNil(t, snapshots[1].Override)
+}
+
+func TestCircuitBreakerClient_Reset(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetupUnreachableServer("bad.example")
+
+	var transitions []string
+	cb := NewCircuitBreakerClient(mockClient, CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Hour,
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		OnStateChange: func(server string, from, to gobreaker.State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+
+	_, _ = cb.Query(context.Background(), "bad.example")
+	require.Equal(t, gobreaker.StateOpen, cb.GetState("bad.example"))
+
+	assert.True(t, cb.Reset("bad.example"))
+	assert.Equal(t, gobreaker.StateClosed, cb.GetState("bad.example"))
+	assert.Equal(t, gobreaker.Counts{}, cb.GetCounts("bad.example"))
+	assert.Equal(t, []string{"closed->open", "open->closed"}, transitions)
+
+	assert.False(t, cb.Reset("unknown.example"))
+	assert.Equal(t, []string{"bad.example"}, cb.ResetAll())
+}
+
+func TestCircuitBreakerClient_Forget(t *testing.T) {
+	mockClient := NewMockNTPClient()
+	mockClient.SetupUnreachableServer("bad.example")
+
+	cb := NewCircuitBreakerClient(mockClient, CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Hour,
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	_, _ = cb.Query(context.Background(), "bad.example")
+	require.Equal(t, gobreaker.StateOpen, cb.GetState("bad.example"))
+
+	assert.True(t, cb.Forget("bad.example"))
+	assert.Empty(t, cb.Snapshot())
+	assert.False(t, cb.Forget("bad.example"))
+}
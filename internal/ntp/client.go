@@ -2,7 +2,13 @@ package ntp
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/beevik/ntp"
@@ -15,6 +21,11 @@ type Client struct {
 	timeout     time.Duration
 	version     int
 	rateLimiter *RateLimiter
+	backoff     *KoDBackoff
+	dnsCache    *DNSCache
+
+	mu     sync.Mutex
+	sticky map[string]string // Address queried per hostname, until it cannot be dialled
 }
 
 // Response represents an NTP query response with additional metadata
@@ -60,12 +71,102 @@ func NewClientWithRateLimit(timeout time.Duration, version int, globalRate, perS
 	}
 }
 
+// RateLimiter returns the client's rate limiter, nil when rate limiting is disabled
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.rateLimiter
+}
+
+// SetKoDBackoff makes the client honor Kiss-of-Death codes: servers that
+// sent RATE, DENY or RSTR are not queried until their backoff expires
+func (c *Client) SetKoDBackoff(b *KoDBackoff) {
+	c.backoff = b
+}
+
+// KoDBackoff returns the Kiss-of-Death backoff tracker, nil when not set
+func (c *Client) KoDBackoff() *KoDBackoff {
+	return c.backoff
+}
+
+// SetDNSCache makes the client resolve server hostnames through a shared DNS cache
+func (c *Client) SetDNSCache(cache *DNSCache) {
+	c.dnsCache = cache
+}
+
+// dial opens the UDP connection to an NTP server, resolving its hostname
+// through the DNS cache. A hostname sticks to one of its addresses, the first
+// resolved, so that the samples and cycles of a server all measure the same
+// path; the next address is used only when that one cannot be dialled.
+func (c *Client) dial(ctx context.Context, localAddress, remoteAddress string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(remoteAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	ips, err := c.dnsCache.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	var d net.Dialer
+	if localAddress != "" {
+		d.LocalAddr = &net.UDPAddr{IP: net.ParseIP(localAddress)}
+	}
+
+	first := c.stickyIndex(host, ips)
+	for i := range ips {
+		ip := ips[(first+i)%len(ips)]
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, "udp", net.JoinHostPort(ip, port))
+		if err == nil {
+			c.stick(host, ip)
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// stickyIndex returns the index in ips of the address host sticks to, 0 when
+// it has none or no longer resolves to it
+func (c *Client) stickyIndex(host string, ips []string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i := slices.Index(ips, c.sticky[host]); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+// stick makes host stick to the address ip
+func (c *Client) stick(host, ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sticky == nil {
+		c.sticky = make(map[string]string)
+	}
+	c.sticky[host] = ip
+}
+
 // Query performs a single NTP query to the specified server
 func (c *Client) Query(ctx context.Context, server string) (*Response, error) {
+	// Do not send anything to a server backing off after a Kiss-of-Death
+	if c.backoff != nil {
+		if err := c.backoff.Check(server); err != nil {
+			return nil, newQueryError(server, err)
+		}
+	}
+
 	// Apply rate limiting if enabled
 	if c.rateLimiter != nil {
 		if err := c.rateLimiter.Wait(ctx, server); err != nil {
-			return nil, fmt.Errorf("rate limit exceeded: %w", err)
+			return nil, newQueryError(server, fmt.Errorf("%w: %w", ErrRateLimited, err))
 		}
 	}
 
@@ -74,6 +175,11 @@ func (c *Client) Query(ctx context.Context, server string) (*Response, error) {
 		Timeout: c.timeout,
 		Version: c.version,
 	}
+	if c.dnsCache != nil {
+		opts.Dialer = func(localAddress, remoteAddress string) (net.Conn, error) {
+			return c.dial(ctx, localAddress, remoteAddress)
+		}
+	}
 
 	// Structure to encapsulate the result
 	type queryResult struct {
@@ -94,14 +200,14 @@ func (c *Client) Query(ctx context.Context, server string) (*Response, error) {
 	case <-ctx.Done():
 		// The goroutine will finish and write to the buffered channel
 		// The garbage collector will clean everything up
-		return nil, fmt.Errorf("query context cancelled: %w", ctx.Err())
+		return nil, newQueryError(server, fmt.Errorf("query context cancelled: %w", ctx.Err()))
 	case result := <-resultChan:
 		if result.err != nil {
 			logger.SafeDebug("ntp", "NTP query failed", map[string]interface{}{
 				"server": server,
 				"error":  result.err.Error(),
 			})
-			return nil, fmt.Errorf("ntp query to %s failed: %w", server, result.err)
+			return nil, newQueryError(server, fmt.Errorf("ntp query to %s failed: %w", server, result.err))
 		}
 
 		// Validate response
@@ -131,6 +237,15 @@ func (c *Client) Query(ctx context.Context, server string) (*Response, error) {
 		resp.MinError = result.response.MinError
 		resp.KissCode = result.response.KissCode
 
+		// The KoD response is still returned so that it gets reported
+		if c.backoff != nil {
+			if resp.IsKissOfDeath() {
+				c.backoff.Record(server, resp.KissCode)
+			} else {
+				c.backoff.RecordSuccess(server)
+			}
+		}
+
 		logger.SafeDebug("ntp", "NTP query successful", map[string]interface{}{
 			"server":  server,
 			"offset":  resp.Offset.Seconds(),
@@ -149,6 +264,7 @@ func (c *Client) QueryMultiple(ctx context.Context, server string, count int) ([
 	responseSlicePtr := GetResponseSlice()
 	defer PutResponseSlice(responseSlicePtr)
 	responses := *responseSlicePtr
+	var lastErr error
 
 	for i := 0; i < count; i++ {
 		select {
@@ -161,7 +277,15 @@ func (c *Client) QueryMultiple(ctx context.Context, server string, count int) ([
 		}
 
 		resp, err := c.Query(ctx, server)
+		if errors.Is(err, ErrBackoff) {
+			// Suspended by a Kiss-of-Death, possibly from an earlier sample
+			if len(responses) == 0 {
+				return nil, err
+			}
+			break
+		}
 		if err != nil {
+			lastErr = err
 			logger.SafeDebug("ntp", "NTP query attempt failed", map[string]interface{}{
 				"server":  server,
 				"attempt": i + 1,
@@ -180,7 +304,12 @@ func (c *Client) QueryMultiple(ctx context.Context, server string, count int) ([
 
 	if len(responses) == 0 {
 		logger.Warnf("ntp", "All %d NTP queries failed for server %s", count, server)
-		return nil, fmt.Errorf("all %d NTP queries failed for server %s", count, server)
+		// The reason of the last failure stands for the whole query
+		return nil, &QueryError{
+			Server: server,
+			Reason: ErrorReason(lastErr),
+			Err:    fmt.Errorf("all %d NTP queries failed for server %s: %w", count, server, lastErr),
+		}
 	}
 
 	logger.SafeDebug("ntp", "Multiple NTP queries completed", map[string]interface{}{
@@ -200,6 +329,35 @@ func (r *Response) IsKissOfDeath() bool {
 	return r.KissCode != ""
 }
 
+// ReferenceString returns the reference ID formatted the way ntpq displays it:
+// the Kiss-of-Death code for stratum 0, the reference clock name for stratum 1
+// and a dotted quad for higher strata
+func (r *Response) ReferenceString() string {
+	if r.Stratum == 0 {
+		return r.KissCode
+	}
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], r.ReferenceID)
+
+	if r.Stratum == 1 {
+		name := make([]byte, 0, len(b))
+		for _, c := range b {
+			if c == 0 {
+				break
+			}
+			if c < 32 || c > 126 {
+				c = '.'
+			}
+			name = append(name, c)
+		}
+		return "." + string(name) + "."
+	}
+
+	return strconv.Itoa(int(b[0])) + "." + strconv.Itoa(int(b[1])) + "." +
+		strconv.Itoa(int(b[2])) + "." + strconv.Itoa(int(b[3]))
+}
+
 // IsValid checks if the response passed validation
 func (r *Response) IsValid() bool {
 	return r.ValidateError == nil
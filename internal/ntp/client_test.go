@@ -448,6 +448,25 @@ func TestResponse_IsSuspicious(t *testing.T) {
 	}
 }
 
+func TestResponse_ReferenceString(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *Response
+		want     string
+	}{
+		{name: "kiss_of_death", response: &Response{Stratum: 0, KissCode: "DENY"}, want: "DENY"},
+		{name: "reference_clock", response: &Response{Stratum: 1, ReferenceID: 0x47505300}, want: ".GPS."},
+		{name: "non_printable", response: &Response{Stratum: 1, ReferenceID: 0x41014200}, want: ".A.B."},
+		{name: "ipv4_upstream", response: &Response{Stratum: 2, ReferenceID: 0xC0000201}, want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.response.ReferenceString())
+		})
+	}
+}
+
 func TestMock_SetError(t *testing.T) {
 	mock := NewMockNTPClient()
 	testError := errors.New("mock error")
@@ -0,0 +1,185 @@
+package ntp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock discontinuity detection thresholds
+const (
+	// clockSampleInterval is how often the clocks are compared between
+	// clock set notifications
+	clockSampleInterval = time.Second
+
+	// clockNoise is the largest difference between two clock readings that
+	// is not a discontinuity; readings are retried when taken further apart
+	clockNoise = time.Millisecond
+
+	// slewWindow is the monotonic time over which the slew rate is measured
+	slewWindow = 10 * time.Second
+)
+
+// ClockSnapshot holds readings of the system clocks taken together, each
+// since its own epoch
+type ClockSnapshot struct {
+	Realtime     time.Duration // CLOCK_REALTIME: stepped and slewed
+	Monotonic    time.Duration // CLOCK_MONOTONIC: slewed, stops during suspend
+	MonotonicRaw time.Duration // CLOCK_MONOTONIC_RAW: neither stepped nor slewed
+	Boottime     time.Duration // CLOCK_BOOTTIME: CLOCK_MONOTONIC plus suspend time
+}
+
+// ClockStats are the clock discontinuities observed since the watcher started
+type ClockStats struct {
+	Steps        uint64        // Steps of the realtime clock
+	LastStep     time.Duration // Size of the last step, negative when set back
+	Suspends     uint64        // Suspend/resume cycles (or VM pauses)
+	SuspendTotal time.Duration // Time spent suspended
+	SlewPPM      float64       // Rate at which the clock is slewed, over the last window
+	SlewValid    bool          // Whether a slew window has completed
+}
+
+// ClockWatcher detects steps of the realtime clock and suspend/resume cycles
+// by comparing the REALTIME, MONOTONIC and BOOTTIME clocks: REALTIME minus
+// BOOTTIME only changes when the clock is set, BOOTTIME minus MONOTONIC only
+// grows while suspended, and MONOTONIC against MONOTONIC_RAW gives the rate
+// at which the time daemon slews the clock.
+type ClockWatcher struct {
+	read func() (ClockSnapshot, error)
+
+	mu      sync.Mutex
+	last    ClockSnapshot
+	started bool
+	stats   ClockStats
+	window  struct{ monotonic, raw time.Duration } // Current slew window
+
+	// A sample found a step before its clock set notification came in
+	stepPending bool
+}
+
+// NewClockWatcher creates a watcher of the system clocks; Run starts it
+func NewClockWatcher() *ClockWatcher {
+	return &ClockWatcher{read: readClocks}
+}
+
+// Stats returns the discontinuities observed so far
+func (w *ClockWatcher) Stats() ClockStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// sample reads the clocks and records the discontinuities since the last
+// sample; clockSet forces a step to be recorded, however small
+func (w *ClockWatcher) sample(clockSet bool) error {
+	now, err := w.read()
+	if err != nil {
+		return err
+	}
+	w.observe(now, clockSet)
+	return nil
+}
+
+// observe records the discontinuities between the last snapshot and now. A
+// clock set notification forces a step only when no sample has recorded one
+// since the previous notification: when a periodic sample runs between the
+// set and its notification, the step is already counted.
+func (w *ClockWatcher) observe(now ClockSnapshot, clockSet bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.started {
+		w.last = now
+		w.started = true
+		return
+	}
+	last := w.last
+	w.last = now
+
+	step := (now.Realtime - now.Boottime) - (last.Realtime - last.Boottime)
+	stepped := step.Abs() > clockNoise
+	if stepped || (clockSet && !w.stepPending) {
+		w.stats.Steps++
+		w.stats.LastStep = step
+	}
+	if clockSet {
+		w.stepPending = false
+	} else if stepped {
+		w.stepPending = true
+	}
+
+	if suspend := (now.Boottime - now.Monotonic) - (last.Boottime - last.Monotonic); suspend > clockNoise {
+		w.stats.Suspends++
+		w.stats.SuspendTotal += suspend
+	}
+
+	w.window.monotonic += now.Monotonic - last.Monotonic
+	w.window.raw += now.MonotonicRaw - last.MonotonicRaw
+	if w.window.raw >= slewWindow {
+		w.stats.SlewPPM = float64(w.window.monotonic-w.window.raw) / float64(w.window.raw) * 1e6
+		w.stats.SlewValid = true
+		w.window.monotonic, w.window.raw = 0, 0
+	}
+}
+
+// ReadTimeNamespaceOffsets parses a timens_offsets file (e.g.
+// /proc/self/timens_offsets): the offsets of the monotonic and boottime
+// clocks of a time namespace, zero outside of one
+func ReadTimeNamespaceOffsets(path string) (map[string]time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	offsets := make(map[string]time.Duration)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		secs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s offset %q: %w", fields[0], fields[1], err)
+		}
+		nanos, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s offset %q: %w", fields[0], fields[2], err)
+		}
+		offsets[fields[0]] = time.Duration(secs)*time.Second + time.Duration(nanos)
+	}
+	return offsets, scanner.Err()
+}
+
+// CountTimeNamespaces returns the number of time namespaces other than the
+// one of PID 1 that processes under procRoot run in, i.e. the time-namespaced
+// containers of the host. Processes whose namespace cannot be read are skipped.
+func CountTimeNamespaces(procRoot string) (int, error) {
+	host, err := os.Readlink(filepath.Join(procRoot, "1", "ns", "time"))
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0, err
+	}
+
+	namespaces := make(map[string]bool)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		ns, err := os.Readlink(filepath.Join(procRoot, entry.Name(), "ns", "time"))
+		if err == nil && ns != host {
+			namespaces[ns] = true
+		}
+	}
+	return len(namespaces), nil
+}
@@ -0,0 +1,142 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+	"golang.org/x/sys/unix"
+)
+
+// clockReadAttempts bounds the retries of readClocks when it is preempted
+const clockReadAttempts = 5
+
+// Run samples the clocks every second, and immediately when the realtime
+// clock is set, until ctx is done. Sets are caught with a timerfd armed with
+// TFD_TIMER_CANCEL_ON_SET; without one, steps are still found by sampling.
+func (w *ClockWatcher) Run(ctx context.Context) {
+	if err := w.sample(false); err != nil {
+		logger.Error("ntp", "Failed to read the system clocks", err)
+		return
+	}
+
+	sets := make(chan struct{}, 1)
+	if timer, err := newClockSetTimer(); err != nil {
+		logger.SafeWarn("ntp", "Clock set notifications unavailable, sampling only", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else {
+		defer timer.Close()
+		go timer.watch(sets)
+	}
+
+	ticker := time.NewTicker(clockSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = w.sample(false)
+		case <-sets:
+			_ = w.sample(true)
+			stats := w.Stats()
+			logger.SafeWarn("ntp", "System clock was set", map[string]interface{}{
+				"step_seconds": stats.LastStep.Seconds(),
+				"steps":        stats.Steps,
+			})
+		}
+	}
+}
+
+// readClocks reads the four clocks back to back, again when the reads were
+// spread over more than clockNoise (the goroutine was preempted)
+func readClocks() (ClockSnapshot, error) {
+	var snapshot ClockSnapshot
+	for attempt := 0; attempt < clockReadAttempts; attempt++ {
+		var ts [5]unix.Timespec
+		for i, clock := range []int32{unix.CLOCK_MONOTONIC, unix.CLOCK_REALTIME, unix.CLOCK_MONOTONIC_RAW, unix.CLOCK_BOOTTIME, unix.CLOCK_MONOTONIC} {
+			if err := unix.ClockGettime(clock, &ts[i]); err != nil {
+				return ClockSnapshot{}, fmt.Errorf("clock_gettime(%d): %w", clock, err)
+			}
+		}
+
+		snapshot = ClockSnapshot{
+			Monotonic:    time.Duration(ts[0].Nano()),
+			Realtime:     time.Duration(ts[1].Nano()),
+			MonotonicRaw: time.Duration(ts[2].Nano()),
+			Boottime:     time.Duration(ts[3].Nano()),
+		}
+		if time.Duration(ts[4].Nano())-snapshot.Monotonic <= clockNoise/10 {
+			break
+		}
+	}
+	return snapshot, nil
+}
+
+// clockSetTimer is a CLOCK_REALTIME timerfd whose reads fail with ECANCELED
+// when the clock is set (settimeofday, clock_settime, ADJ_SETOFFSET, leap
+// seconds)
+type clockSetTimer struct {
+	fd   int
+	file *os.File // Never call Fd(): it would make the descriptor blocking
+}
+
+// newClockSetTimer creates and arms the timerfd
+func newClockSetTimer() (*clockSetTimer, error) {
+	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, unix.TFD_NONBLOCK|unix.TFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("timerfd_create: %w", err)
+	}
+
+	// A non-blocking descriptor is read through the runtime poller, so
+	// that Close interrupts a pending read
+	t := &clockSetTimer{fd: fd, file: os.NewFile(uintptr(fd), "timerfd")}
+	if err := t.arm(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// arm sets the timer far in the future: only its cancellation matters
+func (t *clockSetTimer) arm() error {
+	deadline := unix.NsecToTimespec(time.Now().AddDate(10, 0, 0).UnixNano())
+	spec := unix.ItimerSpec{Value: deadline}
+	if err := unix.TimerfdSettime(t.fd, unix.TFD_TIMER_ABSTIME|unix.TFD_TIMER_CANCEL_ON_SET, &spec, nil); err != nil {
+		return fmt.Errorf("timerfd_settime: %w", err)
+	}
+	return nil
+}
+
+// watch signals sets until the timer is closed
+func (t *clockSetTimer) watch(sets chan<- struct{}) {
+	buf := make([]byte, 8)
+	for {
+		_, err := t.file.Read(buf)
+		switch {
+		case errors.Is(err, syscall.ECANCELED):
+			select {
+			case sets <- struct{}{}:
+			default: // A set is already pending
+			}
+		case err != nil:
+			return // Closed
+		}
+
+		// The timer is disarmed once cancelled or expired
+		if err := t.arm(); err != nil {
+			return
+		}
+	}
+}
+
+// Close releases the timerfd
+func (t *clockSetTimer) Close() error {
+	return t.file.Close()
+}
@@ -0,0 +1,60 @@
+package ntp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadClocksLinux(t *testing.T) {
+	snapshot, err := readClocks()
+	require.NoError(t, err)
+
+	assert.Positive(t, snapshot.Monotonic)
+	assert.Positive(t, snapshot.MonotonicRaw)
+	assert.GreaterOrEqual(t, snapshot.Boottime, snapshot.Monotonic)
+	assert.InDelta(t, float64(time.Now().UnixNano()), float64(snapshot.Realtime), float64(time.Second))
+}
+
+func TestClockSetTimerLinux(t *testing.T) {
+	timer, err := newClockSetTimer()
+	if err != nil {
+		t.Skipf("timerfd unavailable: %v", err)
+	}
+
+	sets := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		timer.watch(sets)
+		close(done)
+	}()
+
+	// Closing the timer interrupts the pending read
+	require.NoError(t, timer.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watch did not return after Close")
+	}
+}
+
+func TestClockWatcherRunLinux(t *testing.T) {
+	w := NewClockWatcher()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	assert.Zero(t, w.Stats().Steps)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package ntp
+
+import (
+	"context"
+	"errors"
+)
+
+// Run does nothing on non-Linux platforms
+func (w *ClockWatcher) Run(_ context.Context) {}
+
+// readClocks is not supported on non-Linux platforms
+func readClocks() (ClockSnapshot, error) {
+	return ClockSnapshot{}, errors.New("clock discontinuity detection is not supported on this platform (Linux only)")
+}
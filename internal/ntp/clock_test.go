@@ -0,0 +1,127 @@
+package ntp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// advance returns a snapshot taken elapsed later, with the realtime clock
+// stepped by step, suspended for suspend and slewed by slewPPM
+func advance(s ClockSnapshot, elapsed, step, suspend time.Duration, slewPPM float64) ClockSnapshot {
+	slewed := elapsed + time.Duration(float64(elapsed)*slewPPM/1e6)
+	return ClockSnapshot{
+		Realtime:     s.Realtime + slewed + step + suspend,
+		Monotonic:    s.Monotonic + slewed,
+		MonotonicRaw: s.MonotonicRaw + elapsed,
+		Boottime:     s.Boottime + slewed + suspend,
+	}
+}
+
+func TestClockWatcher_Observe(t *testing.T) {
+	w := NewClockWatcher()
+	now := ClockSnapshot{Realtime: 1700000000 * time.Second, Monotonic: time.Hour, MonotonicRaw: time.Hour, Boottime: time.Hour}
+	w.observe(now, false)
+	assert.Equal(t, ClockStats{}, w.Stats())
+
+	// Steady clock slewed by 50 ppm
+	for i := 0; i < 10; i++ {
+		now = advance(now, time.Second, 0, 0, 50)
+		w.observe(now, false)
+	}
+	stats := w.Stats()
+	assert.Zero(t, stats.Steps)
+	assert.Zero(t, stats.Suspends)
+	assert.True(t, stats.SlewValid)
+	assert.InDelta(t, 50, stats.SlewPPM, 0.01)
+
+	// The clock is set back by 2.5s
+	now = advance(now, 100*time.Millisecond, -2500*time.Millisecond, 0, 50)
+	w.observe(now, true)
+	stats = w.Stats()
+	assert.Equal(t, uint64(1), stats.Steps)
+	assert.InDelta(t, -2.5, stats.LastStep.Seconds(), 1e-6)
+
+	// A set to the same time still counts as a step
+	now = advance(now, time.Second, 0, 0, 0)
+	w.observe(now, true)
+	assert.Equal(t, uint64(2), w.Stats().Steps)
+
+	// A step found by sampling, without notification
+	now = advance(now, time.Second, 40*time.Millisecond, 0, 0)
+	w.observe(now, false)
+	stats = w.Stats()
+	assert.Equal(t, uint64(3), stats.Steps)
+	assert.InDelta(t, 0.04, stats.LastStep.Seconds(), 1e-6)
+
+	// Suspended for 10 minutes: not a step
+	now = advance(now, time.Second, 0, 10*time.Minute, 0)
+	w.observe(now, false)
+	stats = w.Stats()
+	assert.Equal(t, uint64(3), stats.Steps)
+	assert.Equal(t, uint64(1), stats.Suspends)
+	assert.Equal(t, 10*time.Minute, stats.SuspendTotal)
+}
+
+func TestReadTimeNamespaceOffsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timens_offsets")
+	require.NoError(t, os.WriteFile(path, []byte("monotonic     86400         0\nboottime   -3600 500000000\n"), 0o644))
+
+	offsets, err := ReadTimeNamespaceOffsets(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"monotonic": 24 * time.Hour,
+		"boottime":  -3600*time.Second + 500*time.Millisecond,
+	}, offsets)
+
+	_, err = ReadTimeNamespaceOffsets(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestCountTimeNamespaces(t *testing.T) {
+	proc := t.TempDir()
+	for pid, ns := range map[string]string{
+		"1":   "time:[4026531834]",
+		"200": "time:[4026531834]",
+		"300": "time:[4026532700]",
+		"301": "time:[4026532700]",
+		"400": "time:[4026532800]",
+	} {
+		dir := filepath.Join(proc, pid, "ns")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.Symlink(ns, filepath.Join(dir, "time")))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "500"), 0o755)) // Unreadable namespace
+
+	count, err := CountTimeNamespaces(proc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = CountTimeNamespaces(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestClockWatcher_ObserveTickBeforeNotification(t *testing.T) {
+	w := NewClockWatcher()
+	now := ClockSnapshot{Realtime: 1700000000 * time.Second, Monotonic: time.Hour, MonotonicRaw: time.Hour, Boottime: time.Hour}
+	w.observe(now, false)
+
+	// The periodic sample runs between the set and its notification
+	now = advance(now, 300*time.Millisecond, 2*time.Second, 0, 0)
+	w.observe(now, false)
+	now = advance(now, time.Millisecond, 0, 0, 0)
+	w.observe(now, true)
+
+	stats := w.Stats()
+	assert.Equal(t, uint64(1), stats.Steps, "the step is counted once")
+	assert.InDelta(t, 2, stats.LastStep.Seconds(), 1e-6)
+
+	// The next set to the same time is counted again
+	now = advance(now, time.Second, 0, 0, 0)
+	w.observe(now, true)
+	assert.Equal(t, uint64(2), w.Stats().Steps)
+}
@@ -0,0 +1,133 @@
+package ntp
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock filter parameters (RFC 5905)
+const (
+	// ClockFilterStages is the number of samples kept per server (NSTAGE)
+	ClockFilterStages = 8
+
+	// MaxDispersion is the dispersion of an empty or expired stage (MAXDISP)
+	MaxDispersion = 16 * time.Second
+)
+
+// filterStage is one sample of a clock filter register
+type filterStage struct {
+	offset time.Duration
+	delay  time.Duration
+	disp   time.Duration
+	valid  bool
+}
+
+// filterRegister is the shift register of the samples of one server
+type filterRegister struct {
+	stages [ClockFilterStages]filterStage
+	last   time.Time // When the register was last shifted
+}
+
+// FilterResult is the state of a server's clock filter after a sample
+type FilterResult struct {
+	Offset     time.Duration // Offset of the minimum-delay sample
+	Delay      time.Duration // Round-trip delay of that sample
+	Dispersion time.Duration // Peer dispersion: the stage dispersions weighted by delay rank
+	Jitter     time.Duration // Peer jitter: RMS offset difference to the minimum-delay sample
+	Samples    int           // Valid stages
+}
+
+// ClockFilter is the clock filter algorithm of RFC 5905 (ntpd's
+// clock_filter). Each server has an 8-stage shift register of samples kept
+// across collection cycles; the sample with the lowest round-trip delay is
+// selected, as it is the least affected by queueing. A stage's dispersion
+// grows by DispersionRate while it sits in the register.
+type ClockFilter struct {
+	mu        sync.Mutex
+	registers map[string]*filterRegister
+}
+
+// NewClockFilter creates a clock filter with empty registers
+func NewClockFilter() *ClockFilter {
+	return &ClockFilter{registers: make(map[string]*filterRegister)}
+}
+
+// Add shifts the sample of resp, received at the local time at, into the
+// register of server and returns the filter output
+func (f *ClockFilter) Add(server string, at time.Time, resp *Response) FilterResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reg, ok := f.registers[server]
+	if !ok {
+		reg = &filterRegister{last: at}
+		f.registers[server] = reg
+	}
+
+	// Age the samples already in the register
+	if elapsed := at.Sub(reg.last); elapsed > 0 {
+		growth := time.Duration(DispersionRate * float64(elapsed))
+		for i := range reg.stages {
+			if reg.stages[i].valid {
+				reg.stages[i].disp += growth
+			}
+		}
+	}
+	reg.last = at
+
+	copy(reg.stages[1:], reg.stages[:ClockFilterStages-1])
+	reg.stages[0] = filterStage{
+		offset: resp.Offset,
+		delay:  resp.RTT,
+		disp:   resp.Precision + time.Duration(DispersionRate*float64(resp.RTT)),
+		valid:  true,
+	}
+
+	return reg.result()
+}
+
+// Forget drops the register of a server no longer collected
+func (f *ClockFilter) Forget(server string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.registers, server)
+}
+
+// result sorts the stages by delay and computes the peer statistics
+func (r *filterRegister) result() FilterResult {
+	sorted := r.stages
+	for i := range sorted {
+		if sorted[i].disp >= MaxDispersion {
+			sorted[i].valid = false
+		}
+		if !sorted[i].valid {
+			sorted[i].delay, sorted[i].disp = MaxDispersion, MaxDispersion
+		}
+	}
+	sort.SliceStable(sorted[:], func(i, j int) bool { return sorted[i].delay < sorted[j].delay })
+
+	var res FilterResult
+	var dispersion, jitter float64
+	for i, stage := range sorted {
+		dispersion += stage.disp.Seconds() / math.Exp2(float64(i+1))
+		if !stage.valid {
+			continue
+		}
+		res.Samples++
+		diff := (stage.offset - sorted[0].offset).Seconds()
+		jitter += diff * diff
+	}
+
+	res.Dispersion = time.Duration(dispersion * float64(time.Second))
+	if res.Samples == 0 {
+		return res
+	}
+	res.Offset = sorted[0].offset
+	res.Delay = sorted[0].delay
+	if res.Samples > 1 {
+		res.Jitter = time.Duration(math.Sqrt(jitter/float64(res.Samples-1)) * float64(time.Second))
+	}
+	return res
+}
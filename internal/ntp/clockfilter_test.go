@@ -0,0 +1,64 @@
+package ntp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockFilter_MinimumDelay(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	f := NewClockFilter()
+
+	// The sample delayed by queueing has a skewed offset
+	samples := []struct{ offset, delay time.Duration }{
+		{2 * time.Millisecond, 20 * time.Millisecond},
+		{9 * time.Millisecond, 80 * time.Millisecond},
+		{1 * time.Millisecond, 10 * time.Millisecond},
+	}
+	var res FilterResult
+	for i, s := range samples {
+		res = f.Add("a", start.Add(time.Duration(i)*time.Minute), &Response{Offset: s.offset, RTT: s.delay})
+	}
+
+	assert.Equal(t, 3, res.Samples)
+	assert.Equal(t, time.Millisecond, res.Offset)
+	assert.Equal(t, 10*time.Millisecond, res.Delay)
+	// Offsets sorted by delay: 1ms, 2ms, 9ms
+	assert.InDelta(t, math.Sqrt((1e-6+64e-6)/2), res.Jitter.Seconds(), 1e-9)
+
+	// Another server has its own register
+	other := f.Add("b", start, &Response{Offset: 5 * time.Millisecond, RTT: 30 * time.Millisecond})
+	assert.Equal(t, 1, other.Samples)
+	assert.Equal(t, 5*time.Millisecond, other.Offset)
+	assert.Zero(t, other.Jitter)
+}
+
+func TestClockFilter_Dispersion(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	f := NewClockFilter()
+
+	// A single sample: the seven empty stages weigh MaxDispersion
+	res := f.Add("a", start, &Response{RTT: 10 * time.Millisecond, Precision: time.Microsecond})
+	sampleDisp := time.Microsecond + 150*time.Nanosecond
+	empty := 16.0 * (0.5 - 1.0/256)
+	assert.InDelta(t, sampleDisp.Seconds()/2+empty, res.Dispersion.Seconds(), 1e-9)
+
+	// The register fills and the dispersion converges
+	for i := 1; i < ClockFilterStages; i++ {
+		last := res.Dispersion
+		res = f.Add("a", start.Add(time.Duration(i)*time.Second), &Response{RTT: 10 * time.Millisecond, Precision: time.Microsecond})
+		assert.Less(t, res.Dispersion, last)
+	}
+	assert.Equal(t, ClockFilterStages, res.Samples)
+	assert.Less(t, res.Dispersion, 100*time.Microsecond)
+
+	// Samples age: after 13 days without update the stages expire
+	res = f.Add("a", start.Add(13*24*time.Hour), &Response{RTT: 10 * time.Millisecond})
+	assert.Equal(t, 1, res.Samples)
+
+	f.Forget("a")
+	assert.Empty(t, f.registers)
+}
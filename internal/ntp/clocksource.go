@@ -0,0 +1,52 @@
+package ntp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// clocksourceGlob matches the clocksource devices under a sysfs root
+const clocksourceGlob = "devices/system/clocksource/*"
+
+// Clocksource is the clocksource state of the kernel, e.g. "tsc" with
+// "tsc hpet acpi_pm" available. An unstable TSC is removed from the
+// available clocksources and replaced as the current one.
+type Clocksource struct {
+	Current   string
+	Available []string
+}
+
+// ReadClocksource reads the current and available clocksources under
+// sysRoot ("/sys" when empty)
+func ReadClocksource(sysRoot string) (*Clocksource, error) {
+	if sysRoot == "" {
+		sysRoot = "/sys"
+	}
+
+	devices, err := filepath.Glob(filepath.Join(sysRoot, clocksourceGlob))
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, errors.New("no clocksource device found under " + sysRoot)
+	}
+	sort.Strings(devices)
+	device := devices[0] // clocksource0, the only one on current kernels
+
+	current, err := os.ReadFile(filepath.Join(device, "current_clocksource"))
+	if err != nil {
+		return nil, err
+	}
+	available, err := os.ReadFile(filepath.Join(device, "available_clocksource"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clocksource{
+		Current:   strings.TrimSpace(string(current)),
+		Available: strings.Fields(string(available)),
+	}, nil
+}
@@ -0,0 +1,27 @@
+package ntp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadClocksource(t *testing.T) {
+	sys := t.TempDir()
+	dir := filepath.Join(sys, "devices", "system", "clocksource", "clocksource0")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "current_clocksource"), []byte("kvm-clock\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "available_clocksource"), []byte("kvm-clock tsc acpi_pm \n"), 0o644))
+
+	cs, err := ReadClocksource(sys)
+	require.NoError(t, err)
+	assert.Equal(t, &Clocksource{Current: "kvm-clock", Available: []string{"kvm-clock", "tsc", "acpi_pm"}}, cs)
+}
+
+func TestReadClocksource_Missing(t *testing.T) {
+	_, err := ReadClocksource(t.TempDir())
+	assert.Error(t, err)
+}
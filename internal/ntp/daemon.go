@@ -0,0 +1,276 @@
+package ntp
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Time daemons recognized on the host
+const (
+	DaemonChronyd   = "chronyd"
+	DaemonNTPD      = "ntpd"
+	DaemonTimesyncd = "systemd-timesyncd"
+	DaemonPTP4L     = "ptp4l"
+	DaemonPHC2SYS   = "phc2sys"
+	DaemonOpenNTPD  = "openntpd"
+)
+
+// UnknownVersion is reported when the daemon version cannot be read
+const UnknownVersion = "unknown"
+
+// daemonPackages lists the Debian packages a daemon may come from
+var daemonPackages = map[string][]string{
+	DaemonChronyd:   {"chrony"},
+	DaemonNTPD:      {"ntpsec", "ntp"},
+	DaemonTimesyncd: {"systemd-timesyncd", "systemd"},
+	DaemonPTP4L:     {"linuxptp"},
+	DaemonPHC2SYS:   {"linuxptp"},
+	DaemonOpenNTPD:  {"openntpd"},
+}
+
+// TimeDaemon is a time daemon running on the host
+type TimeDaemon struct {
+	Name         string
+	Version      string
+	PID          int
+	AdjustsClock bool // Whether it disciplines the system clock (CLOCK_REALTIME)
+}
+
+// DaemonDetector finds the time daemons running on the host by scanning a
+// proc filesystem, usually the host's /proc mounted in the container
+type DaemonDetector struct {
+	procRoot string
+
+	mu       sync.Mutex
+	versions map[int]string // Version of each daemon PID, read once
+}
+
+// NewDaemonDetector creates a detector scanning procRoot ("/proc" when empty)
+func NewDaemonDetector(procRoot string) *DaemonDetector {
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	return &DaemonDetector{
+		procRoot: procRoot,
+		versions: make(map[int]string),
+	}
+}
+
+// Detect returns the time daemons running on the host, one per daemon (the
+// process with the lowest PID, privilege-separated children being skipped),
+// sorted by name
+func (d *DaemonDetector) Detect() ([]TimeDaemon, error) {
+	entries, err := os.ReadDir(d.procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	type process struct {
+		pid  int
+		name string
+		args []string
+	}
+	var processes []process
+	openntpdParents := make(map[int]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		args := d.cmdline(pid)
+		if len(args) == 0 {
+			continue // Kernel thread or process gone
+		}
+		name := daemonName(args)
+		if name == "" {
+			continue
+		}
+		if name == DaemonOpenNTPD && strings.HasPrefix(args[0], "ntpd: ") {
+			openntpdParents[d.parent(pid)] = true
+		}
+		processes = append(processes, process{pid: pid, name: name, args: args})
+	}
+
+	found := make(map[string]TimeDaemon)
+	for _, proc := range processes {
+		// OpenNTPD's parent process may be called ntpd, as in OpenBSD
+		if proc.name == DaemonNTPD && openntpdParents[proc.pid] {
+			proc.name = DaemonOpenNTPD
+		}
+		if existing, ok := found[proc.name]; ok && existing.PID < proc.pid {
+			continue
+		}
+		found[proc.name] = TimeDaemon{
+			Name:         proc.name,
+			PID:          proc.pid,
+			AdjustsClock: adjustsClock(proc.name, proc.args[1:]),
+		}
+	}
+
+	daemons := make([]TimeDaemon, 0, len(found))
+	live := make(map[int]bool, len(found))
+	for _, daemon := range found {
+		daemon.Version = d.version(daemon)
+		live[daemon.PID] = true
+		daemons = append(daemons, daemon)
+	}
+	sort.Slice(daemons, func(i, j int) bool { return daemons[i].Name < daemons[j].Name })
+
+	// Forget the versions of daemons that are gone
+	d.mu.Lock()
+	for pid := range d.versions {
+		if !live[pid] {
+			delete(d.versions, pid)
+		}
+	}
+	d.mu.Unlock()
+
+	return daemons, nil
+}
+
+// cmdline returns the arguments of a process
+func (d *DaemonDetector) cmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join(d.procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return nil
+	}
+	return strings.FieldsFunc(string(data), func(r rune) bool { return r == 0 })
+}
+
+// parent returns the parent PID of a process, 0 when unknown
+func (d *DaemonDetector) parent(pid int) int {
+	data, err := os.ReadFile(filepath.Join(d.procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0
+	}
+	// The command name may contain spaces: fields start after its ")"
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	if len(fields) < 2 {
+		return 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return ppid
+}
+
+// daemonName returns the time daemon a command line belongs to, or "".
+// OpenNTPD is told apart from ntpd by its binary name or by the
+// "ntpd: ntp engine" titles of its processes.
+func daemonName(args []string) string {
+	title := args[0]
+	if strings.HasPrefix(title, "ntpd: ") {
+		return DaemonOpenNTPD
+	}
+	fields := strings.Fields(title)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := filepath.Base(fields[0])
+
+	switch name {
+	case DaemonChronyd, DaemonNTPD, DaemonTimesyncd, DaemonPTP4L, DaemonPHC2SYS, DaemonOpenNTPD:
+		return name
+	}
+	return ""
+}
+
+// adjustsClock reports whether a daemon started with args disciplines the
+// system clock. ptp4l only does with software timestamping (-S), otherwise
+// it disciplines the NIC's PTP hardware clock; phc2sys does unless -c names
+// another clock; chronyd does not with -x.
+func adjustsClock(name string, args []string) bool {
+	switch name {
+	case DaemonChronyd:
+		return !hasFlag(args, "-x")
+	case DaemonPTP4L:
+		return hasFlag(args, "-S")
+	case DaemonPHC2SYS:
+		for i, arg := range args {
+			if arg == "-c" && i+1 < len(args) && args[i+1] != "CLOCK_REALTIME" {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// hasFlag reports whether a short flag appears alone or grouped (-Sm)
+func hasFlag(args []string, flag string) bool {
+	letter := flag[1:]
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.Contains(arg[1:], letter) {
+			return true
+		}
+	}
+	return false
+}
+
+// version returns the version of a daemon's package, read once per PID from
+// the dpkg database of the daemon's root filesystem
+func (d *DaemonDetector) version(daemon TimeDaemon) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if version, ok := d.versions[daemon.PID]; ok {
+		return version
+	}
+
+	status := filepath.Join(d.procRoot, strconv.Itoa(daemon.PID), "root", "var", "lib", "dpkg", "status")
+	version := dpkgVersion(status, daemonPackages[daemon.Name])
+	d.versions[daemon.PID] = version
+	return version
+}
+
+// dpkgVersion returns the version of the first installed package of
+// packages in a dpkg status file, or UnknownVersion
+func dpkgVersion(path string, packages []string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return UnknownVersion
+	}
+	defer f.Close()
+
+	installed := make(map[string]string)
+	var pkg, version, state string
+	flush := func() {
+		if pkg != "" && version != "" && strings.HasSuffix(state, " installed") {
+			installed[pkg] = version
+		}
+		pkg, version, state = "", "", ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "Package: "); ok {
+			pkg = value
+		} else if value, ok := strings.CutPrefix(line, "Version: "); ok {
+			version = value
+		} else if value, ok := strings.CutPrefix(line, "Status: "); ok {
+			state = value
+		}
+	}
+	flush()
+
+	for _, name := range packages {
+		if version, ok := installed[name]; ok {
+			return version
+		}
+	}
+	return UnknownVersion
+}
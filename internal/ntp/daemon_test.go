@@ -0,0 +1,133 @@
+package ntp
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProc builds a proc tree in a temporary directory
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	return &fakeProc{t: t, root: t.TempDir()}
+}
+
+// process adds a process with its command line and parent
+func (p *fakeProc) process(pid, ppid int, args ...string) {
+	p.t.Helper()
+	dir := filepath.Join(p.root, strconv.Itoa(pid))
+	require.NoError(p.t, os.MkdirAll(dir, 0o755))
+	cmdline := strings.Join(args, "\x00")
+	if cmdline != "" {
+		cmdline += "\x00"
+	}
+	require.NoError(p.t, os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644))
+	stat := strconv.Itoa(pid) + " (some name) S " + strconv.Itoa(ppid) + " 1 1 0"
+	require.NoError(p.t, os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644))
+}
+
+// dpkg writes the dpkg status file seen from a process root
+func (p *fakeProc) dpkg(pid int, status string) {
+	p.t.Helper()
+	dir := filepath.Join(p.root, strconv.Itoa(pid), "root", "var", "lib", "dpkg")
+	require.NoError(p.t, os.MkdirAll(dir, 0o755))
+	require.NoError(p.t, os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644))
+}
+
+func TestDaemonDetector_Detect(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.process(1, 0, "/sbin/init")
+	proc.process(2, 0) // Kernel thread
+	proc.process(410, 1, "/usr/sbin/chronyd", "-F", "1")
+	proc.process(412, 410, "/usr/sbin/chronyd", "-F", "1")
+	proc.process(520, 1, "/lib/systemd/systemd-timesyncd")
+	proc.process(600, 1, "/usr/sbin/ptp4l", "-f", "/etc/ptp4l.conf", "-i", "eth0")
+	proc.process(601, 1, "/usr/sbin/phc2sys", "-s", "eth0", "-w")
+	require.NoError(t, os.WriteFile(filepath.Join(proc.root, "uptime"), []byte("1.0 1.0"), 0o644))
+
+	proc.dpkg(410, `Package: chrony
+Status: install ok installed
+Version: 4.3-2+deb12u1
+
+Package: linuxptp
+Status: deinstall ok config-files
+Version: 3.1.1-4
+`)
+
+	daemons, err := NewDaemonDetector(proc.root).Detect()
+	require.NoError(t, err)
+	assert.Equal(t, []TimeDaemon{
+		{Name: DaemonChronyd, Version: "4.3-2+deb12u1", PID: 410, AdjustsClock: true},
+		{Name: DaemonPHC2SYS, Version: UnknownVersion, PID: 601, AdjustsClock: true},
+		{Name: DaemonPTP4L, Version: UnknownVersion, PID: 600, AdjustsClock: false},
+		{Name: DaemonTimesyncd, Version: UnknownVersion, PID: 520, AdjustsClock: true},
+	}, daemons)
+}
+
+func TestDaemonDetector_OpenNTPD(t *testing.T) {
+	proc := newFakeProc(t)
+	proc.process(300, 1, "/usr/sbin/ntpd", "-d")
+	proc.process(301, 300, "ntpd: ntp engine")
+	proc.process(302, 301, "ntpd: dns engine")
+
+	daemons, err := NewDaemonDetector(proc.root).Detect()
+	require.NoError(t, err)
+	require.Len(t, daemons, 1)
+	assert.Equal(t, DaemonOpenNTPD, daemons[0].Name)
+	assert.Equal(t, 300, daemons[0].PID)
+}
+
+func TestDaemonDetector_MissingRoot(t *testing.T) {
+	_, err := NewDaemonDetector(filepath.Join(t.TempDir(), "proc")).Detect()
+	assert.Error(t, err)
+}
+
+func TestAdjustsClock(t *testing.T) {
+	tests := []struct {
+		name     string
+		daemon   string
+		args     []string
+		expected bool
+	}{
+		{"chronyd", DaemonChronyd, []string{"-F", "1"}, true},
+		{"chronyd_no_clock_control", DaemonChronyd, []string{"-x"}, false},
+		{"chronyd_grouped_flags", DaemonChronyd, []string{"-dx"}, false},
+		{"ptp4l_hardware", DaemonPTP4L, []string{"-i", "eth0", "-H"}, false},
+		{"ptp4l_software", DaemonPTP4L, []string{"-i", "eth0", "-S"}, true},
+		{"phc2sys_system_clock", DaemonPHC2SYS, []string{"-s", "eth0", "-c", "CLOCK_REALTIME"}, true},
+		{"phc2sys_other_clock", DaemonPHC2SYS, []string{"-s", "eth0", "-c", "eth1"}, false},
+		{"ntpd", DaemonNTPD, []string{"-g"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, adjustsClock(tt.daemon, tt.args))
+		})
+	}
+}
+
+func TestDpkgVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+	require.NoError(t, os.WriteFile(path, []byte(`Package: systemd
+Status: install ok installed
+Version: 252.22-1~deb12u1
+
+Package: ntpsec
+Status: install ok installed
+Version: 1.2.2+dfsg1-1+deb12u1
+`), 0o644))
+
+	assert.Equal(t, "1.2.2+dfsg1-1+deb12u1", dpkgVersion(path, daemonPackages[DaemonNTPD]))
+	assert.Equal(t, "252.22-1~deb12u1", dpkgVersion(path, daemonPackages[DaemonTimesyncd]))
+	assert.Equal(t, UnknownVersion, dpkgVersion(path, daemonPackages[DaemonChronyd]))
+	assert.Equal(t, UnknownVersion, dpkgVersion(filepath.Join(t.TempDir(), "missing"), []string{"chrony"}))
+}
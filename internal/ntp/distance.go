@@ -0,0 +1,25 @@
+package ntp
+
+import "time"
+
+// DispersionRate is the rate at which the dispersion of a server's clock
+// grows since its last update from its reference (PHI, RFC 5905)
+const DispersionRate = 15e-6
+
+// ErrorBound returns the largest error of the offset: half the round trip,
+// the server's own distance to its reference (half its root delay plus its
+// root dispersion), and the dispersion grown since the server last updated
+// its clock
+func (r *Response) ErrorBound() time.Duration {
+	bound := r.RTT/2 + r.RootDelay/2 + r.RootDispersion
+	if !r.ReferenceTime.IsZero() && r.Time.After(r.ReferenceTime) {
+		bound += time.Duration(DispersionRate * float64(r.Time.Sub(r.ReferenceTime)))
+	}
+	return bound
+}
+
+// OffsetBounds returns the interval the true offset lies in
+func (r *Response) OffsetBounds() (lower, upper time.Duration) {
+	bound := r.ErrorBound()
+	return r.Offset - bound, r.Offset + bound
+}
@@ -0,0 +1,30 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponse_OffsetBounds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	resp := &Response{
+		Offset:         2 * time.Millisecond,
+		RTT:            10 * time.Millisecond,
+		RootDelay:      4 * time.Millisecond,
+		RootDispersion: time.Millisecond,
+		ReferenceTime:  now.Add(-100 * time.Second),
+		Time:           now,
+	}
+
+	// 5ms + 2ms + 1ms + 15 ppm over 100s
+	assert.Equal(t, 9500*time.Microsecond, resp.ErrorBound())
+	lower, upper := resp.OffsetBounds()
+	assert.Equal(t, -7500*time.Microsecond, lower)
+	assert.Equal(t, 11500*time.Microsecond, upper)
+
+	// No dispersion growth without a reference time
+	resp.ReferenceTime = time.Time{}
+	assert.Equal(t, 8*time.Millisecond, resp.ErrorBound())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
@@ -17,19 +18,25 @@ type DNSCacheEntry struct {
 	ErrorCount int
 }
 
-// DNSCache provides intelligent DNS caching with adaptive TTL
+// DNSCache provides intelligent DNS caching: entries live for the record TTL
+// clamped to [minTTL, maxTTL], or an adaptive TTL when the resolver does not
+// report one
 type DNSCache struct {
-	mu      sync.RWMutex
-	cache   map[string]*DNSCacheEntry
-	minTTL  time.Duration
-	maxTTL  time.Duration
-	resolver *net.Resolver
+	mu       sync.RWMutex
+	cache    map[string]*DNSCacheEntry
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	resolver DNSResolver
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
 // DNSCacheConfig configures the DNS cache behavior
 type DNSCacheConfig struct {
-	MinTTL time.Duration // Minimum TTL (default: 5min)
-	MaxTTL time.Duration // Maximum TTL (default: 60min)
+	MinTTL   time.Duration // Minimum TTL (default: 5min)
+	MaxTTL   time.Duration // Maximum TTL (default: 60min)
+	Resolver DNSResolver   // Resolver used on cache misses (default: system resolver)
 }
 
 // NewDNSCache creates a new DNS cache with adaptive TTL
@@ -40,14 +47,15 @@ func NewDNSCache(config DNSCacheConfig) *DNSCache {
 	if config.MaxTTL == 0 {
 		config.MaxTTL = 60 * time.Minute
 	}
+	if config.Resolver == nil {
+		config.Resolver = NewSystemResolver()
+	}
 
 	return &DNSCache{
-		cache:  make(map[string]*DNSCacheEntry),
-		minTTL: config.MinTTL,
-		maxTTL: config.MaxTTL,
-		resolver: &net.Resolver{
-			PreferGo: true,
-		},
+		cache:    make(map[string]*DNSCacheEntry),
+		minTTL:   config.MinTTL,
+		maxTTL:   config.MaxTTL,
+		resolver: config.Resolver,
 	}
 }
 
@@ -64,6 +72,7 @@ func (c *DNSCache) Resolve(ctx context.Context, hostname string) ([]string, erro
 	c.mu.RUnlock()
 
 	if exists && time.Now().Before(entry.ExpiresAt) {
+		c.hits.Add(1)
 		logger.SafeDebug("dns", "DNS cache hit", map[string]interface{}{
 			"hostname": hostname,
 			"ips":      len(entry.IPs),
@@ -73,11 +82,12 @@ func (c *DNSCache) Resolve(ctx context.Context, hostname string) ([]string, erro
 	}
 
 	// Cache miss or expired - perform resolution
+	c.misses.Add(1)
 	logger.SafeDebug("dns", "DNS cache miss, resolving", map[string]interface{}{
 		"hostname": hostname,
 	})
 
-	ips, err := c.resolveWithTimeout(ctx, hostname)
+	answer, err := c.resolveWithTimeout(ctx, hostname)
 	if err != nil {
 		// On error, try to use stale cache if available
 		if exists {
@@ -95,8 +105,12 @@ func (c *DNSCache) Resolve(ctx context.Context, hostname string) ([]string, erro
 		return nil, err
 	}
 
-	// Calculate adaptive TTL based on success/failure history
-	ttl := c.calculateAdaptiveTTL(exists, entry)
+	// Honor the record TTL, or adapt to the success/failure history without one
+	ttl := c.clampTTL(answer.TTL)
+	if answer.TTL == 0 {
+		ttl = c.calculateAdaptiveTTL(exists, entry)
+	}
+	ips := answer.IPs
 
 	// Update cache
 	c.mu.Lock()
@@ -118,7 +132,7 @@ func (c *DNSCache) Resolve(ctx context.Context, hostname string) ([]string, erro
 }
 
 // resolveWithTimeout performs DNS resolution with timeout
-func (c *DNSCache) resolveWithTimeout(ctx context.Context, hostname string) ([]string, error) {
+func (c *DNSCache) resolveWithTimeout(ctx context.Context, hostname string) (DNSAnswer, error) {
 	// Create context with timeout if not already set
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
 		var cancel context.CancelFunc
@@ -126,12 +140,18 @@ func (c *DNSCache) resolveWithTimeout(ctx context.Context, hostname string) ([]s
 		defer cancel()
 	}
 
-	addrs, err := c.resolver.LookupHost(ctx, hostname)
-	if err != nil {
-		return nil, err
-	}
+	return c.resolver.Lookup(ctx, hostname)
+}
 
-	return addrs, nil
+// clampTTL bounds a record TTL to [minTTL, maxTTL]
+func (c *DNSCache) clampTTL(ttl time.Duration) time.Duration {
+	if ttl < c.minTTL {
+		return c.minTTL
+	}
+	if ttl > c.maxTTL {
+		return c.maxTTL
+	}
+	return ttl
 }
 
 // calculateAdaptiveTTL calculates TTL based on resolution history
@@ -200,6 +220,8 @@ func (c *DNSCache) Stats() map[string]interface{} {
 		"expired_entries": expiredEntries,
 		"min_ttl":         c.minTTL.String(),
 		"max_ttl":         c.maxTTL.String(),
+		"hits":            c.hits.Load(),
+		"misses":          c.misses.Load(),
 	}
 }
 
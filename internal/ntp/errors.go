@@ -0,0 +1,109 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/beevik/ntp"
+	"github.com/sony/gobreaker"
+)
+
+// Reasons a query fails, as returned by ErrorReason
+const (
+	ReasonTimeout     = "timeout"       // No answer within the timeout
+	ReasonDNS         = "dns"           // The server name did not resolve
+	ReasonUnreachable = "unreachable"   // ICMP unreachable or connection refused
+	ReasonKissOfDeath = "kiss_of_death" // Suspended after a Kiss-of-Death
+	ReasonRateLimited = "rate_limited"  // Refused by the local rate limiter
+	ReasonCircuitOpen = "circuit_open"  // Refused by an open circuit breaker
+	ReasonValidation  = "validation"    // The answer failed the protocol checks
+	ReasonCanceled    = "canceled"      // The collection was cancelled
+	ReasonOther       = "other"
+)
+
+// ErrRateLimited is returned when the rate limiter refuses a query
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// QueryError is a failed query to an NTP server, with the reason it failed
+type QueryError struct {
+	Server string
+	Reason string
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// newQueryError wraps err in a QueryError, unless it already is one
+func newQueryError(server string, err error) error {
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		return err
+	}
+	return &QueryError{Server: server, Reason: classifyError(err), Err: err}
+}
+
+// ErrorReason returns why a query failed: the reason of its QueryError, or
+// the one shown by the error chain for errors from other queriers
+func ErrorReason(err error) string {
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		return queryErr.Reason
+	}
+	return classifyError(err)
+}
+
+// validationErrors are the protocol checks of an answer that can fail a query
+var validationErrors = []error{
+	ntp.ErrAuthFailed,
+	ntp.ErrInvalidDispersion,
+	ntp.ErrInvalidLeapSecond,
+	ntp.ErrInvalidMode,
+	ntp.ErrInvalidStratum,
+	ntp.ErrInvalidTime,
+	ntp.ErrInvalidTransmitTime,
+	ntp.ErrServerClockFreshness,
+	ntp.ErrServerResponseMismatch,
+	ntp.ErrServerTickedBackwards,
+}
+
+// classifyError finds the reason of a query error in its chain. Refusals by
+// the exporter itself come first, then the causes from the outermost one: a
+// DNS failure may wrap a timeout.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, ErrBackoff), errors.Is(err, ntp.ErrKissOfDeath):
+		return ReasonKissOfDeath
+	case errors.Is(err, ErrForcedOpen), errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return ReasonCircuitOpen
+	case errors.Is(err, ErrRateLimited):
+		return ReasonRateLimited
+	case errors.As(err, &dnsErr):
+		return ReasonDNS
+	case errors.Is(err, context.Canceled):
+		return ReasonCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ReasonUnreachable
+	}
+
+	for _, target := range validationErrors {
+		if errors.Is(err, target) {
+			return ReasonValidation
+		}
+	}
+	return ReasonOther
+}
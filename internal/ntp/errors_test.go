@@ -0,0 +1,107 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"deadline", fmt.Errorf("query context cancelled: %w", context.DeadlineExceeded), ReasonTimeout},
+		{"read_timeout", &net.OpError{Op: "read", Net: "udp", Err: os.ErrDeadlineExceeded}, ReasonTimeout},
+		{"dns", &net.DNSError{Err: "no such host", Name: "time.invalid", IsNotFound: true}, ReasonDNS},
+		{"dns_timeout", &net.DNSError{Err: "i/o timeout", Name: "time.example", IsTimeout: true}, ReasonDNS},
+		{"refused", &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)}, ReasonUnreachable},
+		{"host_unreachable", syscall.EHOSTUNREACH, ReasonUnreachable},
+		{"kod", ntp.ErrKissOfDeath, ReasonKissOfDeath},
+		{"backoff", fmt.Errorf("%w: time.example suspended", ErrBackoff), ReasonKissOfDeath},
+		{"rate_limited", fmt.Errorf("%w: %w", ErrRateLimited, context.DeadlineExceeded), ReasonRateLimited},
+		{"circuit_open", fmt.Errorf("circuit breaker open for time.example: %w", gobreaker.ErrOpenState), ReasonCircuitOpen},
+		{"half_open", gobreaker.ErrTooManyRequests, ReasonCircuitOpen},
+		{"forced_open", fmt.Errorf("%w for time.example", ErrForcedOpen), ReasonCircuitOpen},
+		{"validation", ntp.ErrInvalidStratum, ReasonValidation},
+		{"canceled", fmt.Errorf("query context cancelled: %w", context.Canceled), ReasonCanceled},
+		{"other", errors.New("server not configured in mock"), ReasonOther},
+		{"query_error", &QueryError{Server: "time.example", Reason: ReasonDNS, Err: errors.New("lookup failed")}, ReasonDNS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrorReason(tt.err))
+		})
+	}
+}
+
+func TestNewQueryError(t *testing.T) {
+	err := newQueryError("time.example", fmt.Errorf("ntp query to time.example failed: %w", syscall.ECONNREFUSED))
+
+	var queryErr *QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.Equal(t, "time.example", queryErr.Server)
+	assert.Equal(t, ReasonUnreachable, queryErr.Reason)
+	assert.Equal(t, "ntp query to time.example failed: connection refused", err.Error())
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	// An error already classified keeps its reason when wrapped again
+	wrapped := fmt.Errorf("circuit breaker: %w", err)
+	assert.Same(t, wrapped, newQueryError("time.example", wrapped))
+	assert.Equal(t, ReasonUnreachable, ErrorReason(wrapped))
+}
+
+func TestClient_QueryErrors(t *testing.T) {
+	t.Run("kiss_of_death", func(t *testing.T) {
+		client := NewClient(time.Second, 4)
+		backoff := NewKoDBackoff(time.Minute, time.Hour, time.Hour)
+		backoff.Record("192.0.2.1", "RATE")
+		client.SetKoDBackoff(backoff)
+
+		_, err := client.Query(context.Background(), "192.0.2.1")
+		assert.Equal(t, ReasonKissOfDeath, ErrorReason(err))
+
+		_, err = client.QueryMultiple(context.Background(), "192.0.2.1", 2)
+		assert.Equal(t, ReasonKissOfDeath, ErrorReason(err))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		client := NewClient(5*time.Second, 4)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := client.Query(ctx, "192.0.2.1")
+		var queryErr *QueryError
+		require.ErrorAs(t, err, &queryErr)
+		assert.Equal(t, "192.0.2.1", queryErr.Server)
+		assert.Equal(t, ReasonCanceled, queryErr.Reason)
+	})
+
+	t.Run("circuit_open", func(t *testing.T) {
+		mock := NewMockNTPClient()
+		mock.SetupUnreachableServer("time.example")
+		cb := NewCircuitBreakerClient(mock, CircuitBreakerConfig{
+			MaxRequests: 1,
+			Timeout:     time.Minute,
+			ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		})
+
+		_, err := cb.Query(context.Background(), "time.example")
+		assert.Equal(t, ReasonTimeout, ErrorReason(err))
+
+		_, err = cb.Query(context.Background(), "time.example")
+		assert.Equal(t, ReasonCircuitOpen, ErrorReason(err))
+	})
+}
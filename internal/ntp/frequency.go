@@ -0,0 +1,145 @@
+package ntp
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Local frequency estimation bounds
+const (
+	// frequencyHistory bounds the offsets kept per server
+	frequencyHistory = 64
+
+	// frequencyWindow is how long an offset is kept; older ones no longer
+	// describe the current frequency of the local oscillator
+	frequencyWindow = time.Hour
+
+	// frequencyMinSlopes is the number of pairwise slopes needed for an estimate
+	frequencyMinSlopes = 3
+
+	// frequencyZ is the normal quantile of the confidence interval (95%)
+	frequencyZ = 1.96
+)
+
+// FrequencyEstimate is the frequency error of the local clock derived from
+// the trend of its offsets to the NTP servers
+type FrequencyEstimate struct {
+	PPM      float64 // Frequency error, positive when the local clock runs fast
+	LowerPPM float64 // Lower bound of the 95% confidence interval
+	UpperPPM float64 // Upper bound of the 95% confidence interval
+	Servers  int     // Servers with at least two offsets in the window
+	Slopes   int     // Pairwise slopes the estimate is the median of
+	Valid    bool    // Whether enough offsets were recorded
+}
+
+// FrequencyEstimator estimates the frequency error of the local clock from
+// the offsets measured to the NTP servers, without kernel access.
+//
+// It is a Theil–Sen estimator pooled across servers: the slope of every pair
+// of offsets to the same server, the median of them all. Pairing offsets of
+// the same server cancels the constant bias each path has (asymmetry,
+// server error), so only the trend the servers share, the drift of the local
+// clock, remains. The confidence interval is Sen's, from the variance of
+// Kendall's S summed over the servers.
+type FrequencyEstimator struct {
+	mu      sync.Mutex
+	history map[string][]offsetSample
+}
+
+// NewFrequencyEstimator creates an estimator with no history
+func NewFrequencyEstimator() *FrequencyEstimator {
+	return &FrequencyEstimator{history: make(map[string][]offsetSample)}
+}
+
+// Record adds the offset measured to server at the local time at, which
+// should carry a monotonic reading so that clock steps do not skew the trend
+func (f *FrequencyEstimator) Record(server string, at time.Time, offset time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	samples := append(f.history[server], offsetSample{at: at, offset: offset})
+	if len(samples) > frequencyHistory {
+		samples = samples[len(samples)-frequencyHistory:]
+	}
+	f.history[server] = samples
+}
+
+// Forget drops the history of a server no longer collected
+func (f *FrequencyEstimator) Forget(server string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.history, server)
+}
+
+// Estimate returns the frequency error over the offsets recorded in the
+// window before now; offsets outside of it are dropped
+func (f *FrequencyEstimator) Estimate(now time.Time) FrequencyEstimate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var est FrequencyEstimate
+	var slopes []float64
+	var varS float64
+
+	for server, samples := range f.history {
+		samples = pruneSamples(samples, now.Add(-frequencyWindow))
+		if len(samples) == 0 {
+			delete(f.history, server)
+			continue
+		}
+		f.history[server] = samples
+
+		before := len(slopes)
+		for i := 0; i < len(samples); i++ {
+			for j := i + 1; j < len(samples); j++ {
+				dt := samples[j].at.Sub(samples[i].at).Seconds()
+				if dt <= 0 {
+					continue
+				}
+				slopes = append(slopes, (samples[j].offset-samples[i].offset).Seconds()/dt)
+			}
+		}
+		if len(slopes) > before {
+			est.Servers++
+			n := float64(len(samples))
+			varS += n * (n - 1) * (2*n + 5) / 18
+		}
+	}
+
+	est.Slopes = len(slopes)
+	if est.Slopes < frequencyMinSlopes {
+		return est
+	}
+	sort.Float64s(slopes)
+
+	// The offset is server minus local time: it decreases when the local
+	// clock runs fast, hence the sign change
+	est.PPM = -sortedMedian(slopes) * 1e6
+
+	// Sen's interval: the slopes ranked (N−C)/2 and (N+C)/2 + 1 (1-based)
+	c := frequencyZ * math.Sqrt(varS)
+	n := float64(len(slopes))
+	lower := int(math.Max(0, math.Floor((n-c)/2)-1))
+	upper := int(math.Min(n-1, math.Ceil((n+c)/2)))
+	est.LowerPPM = -slopes[upper] * 1e6
+	est.UpperPPM = -slopes[lower] * 1e6
+	est.Valid = true
+	return est
+}
+
+// pruneSamples drops the samples taken before cutoff
+func pruneSamples(samples []offsetSample, cutoff time.Time) []offsetSample {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].at.Before(cutoff) })
+	return samples[i:]
+}
+
+// sortedMedian returns the median of sorted, non-empty values
+func sortedMedian(values []float64) float64 {
+	n := len(values)
+	if n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+	return values[n/2]
+}
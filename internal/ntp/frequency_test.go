@@ -0,0 +1,76 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrequencyEstimator_SharedTrend(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	f := NewFrequencyEstimator()
+
+	// The local clock runs 20 ppm fast; each server has its own bias and
+	// noise, and one answers with an outlier
+	biases := map[string]time.Duration{"a": 0, "b": 3 * time.Millisecond, "c": -2 * time.Millisecond}
+	noise := []time.Duration{40, -25, 10, -60, 35, 0, -15, 50, -30, 20}
+	for i := 0; i < len(noise); i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		drift := -time.Duration(float64(i) * 60 * 20e-6 * float64(time.Second))
+		for server, bias := range biases {
+			f.Record(server, at, bias+drift+noise[i]*time.Microsecond)
+		}
+	}
+	f.Record("c", start.Add(10*time.Minute), 100*time.Millisecond)
+
+	est := f.Estimate(start.Add(10 * time.Minute))
+	assert.True(t, est.Valid)
+	assert.Equal(t, 3, est.Servers)
+	assert.InDelta(t, 20, est.PPM, 1)
+	assert.Less(t, est.LowerPPM, est.PPM)
+	assert.Greater(t, est.UpperPPM, est.PPM)
+	assert.Less(t, est.LowerPPM, 20.0)
+	assert.Greater(t, est.UpperPPM, 20.0)
+}
+
+func TestFrequencyEstimator_NotEnoughSamples(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	f := NewFrequencyEstimator()
+
+	assert.False(t, f.Estimate(start).Valid)
+
+	// A single offset per server gives no slope
+	f.Record("a", start, time.Millisecond)
+	f.Record("b", start, 2*time.Millisecond)
+	est := f.Estimate(start)
+	assert.False(t, est.Valid)
+	assert.Zero(t, est.Servers)
+
+	f.Record("a", start.Add(time.Minute), time.Millisecond)
+	f.Record("a", start.Add(2*time.Minute), time.Millisecond)
+	est = f.Estimate(start.Add(2 * time.Minute))
+	assert.True(t, est.Valid)
+	assert.Equal(t, 1, est.Servers)
+	assert.Equal(t, 3, est.Slopes)
+	assert.Zero(t, est.PPM)
+}
+
+func TestFrequencyEstimator_Window(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	f := NewFrequencyEstimator()
+
+	for i := 0; i < 3; i++ {
+		f.Record("a", start.Add(time.Duration(i)*time.Minute), time.Duration(i)*time.Millisecond)
+	}
+	assert.True(t, f.Estimate(start.Add(2*time.Minute)).Valid)
+
+	// An hour later the offsets are out of the window and dropped
+	est := f.Estimate(start.Add(2 * time.Hour))
+	assert.False(t, est.Valid)
+	assert.Empty(t, f.history)
+
+	f.Record("b", start, 0)
+	f.Forget("b")
+	assert.Empty(t, f.history)
+}
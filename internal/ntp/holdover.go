@@ -0,0 +1,158 @@
+package ntp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/pkg/mathutil"
+)
+
+// holdoverHistory bounds the cycle offsets kept to estimate the drift
+const holdoverHistory = 64
+
+// Holdover drift estimation methods
+const (
+	HoldoverMethodKernel   = "kernel"    // Kernel frequency and maximum error (agent mode)
+	HoldoverMethodNTPDrift = "ntp_drift" // Trend of the NTP offsets (probe mode)
+	HoldoverMethodNone     = "none"      // Not enough history: the last offset only
+)
+
+// offsetSample is the offset measured by one collection cycle
+type offsetSample struct {
+	at     time.Time
+	offset time.Duration
+}
+
+// HoldoverEstimate is how far the clock may have drifted since the last
+// collection cycle in which a time source answered
+type HoldoverEstimate struct {
+	Active     bool          // No time source answered in the last cycle
+	Synced     bool          // A time source answered at least once
+	LastSync   time.Time     // Last cycle in which a time source answered
+	Duration   time.Duration // Time spent in holdover
+	DriftPPM   float64       // Absolute drift rate assumed
+	Method     string        // HoldoverMethodKernel, HoldoverMethodNTPDrift or HoldoverMethodNone
+	ErrorBound time.Duration // Estimated worst-case accumulated error
+}
+
+// HoldoverTracker records the last good synchronization and estimates the
+// error accumulated since, when every time source is lost.
+//
+// With kernel access the estimate is the kernel maximum error at the last
+// sync plus the kernel frequency applied over the holdover: the drift left if
+// the daemon stopped correcting the clock. Without it, the drift is the trend
+// of the offsets measured before the loss, added to the last offset.
+type HoldoverTracker struct {
+	mu sync.Mutex
+
+	started  time.Time
+	lastSync time.Time
+	offset   time.Duration // Offset of the last sync
+	maxError time.Duration // Kernel maximum error at the last sync, when known
+	kernel   bool
+	active   bool
+	history  []offsetSample
+}
+
+// NewHoldoverTracker creates a tracker; holdover before any sync counts from now
+func NewHoldoverTracker(now time.Time) *HoldoverTracker {
+	return &HoldoverTracker{started: now}
+}
+
+// RecordSync records a cycle in which time sources answered with offset
+// (their median). kernel is the kernel state at that time, nil without
+// kernel access.
+func (h *HoldoverTracker) RecordSync(now time.Time, offset time.Duration, kernel *KernelTimex) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastSync = now
+	h.offset = offset
+	h.active = false
+	h.kernel = kernel != nil
+	if kernel != nil {
+		h.maxError = kernel.MaxError
+	}
+
+	h.history = append(h.history, offsetSample{at: now, offset: offset})
+	if len(h.history) > holdoverHistory {
+		h.history = h.history[len(h.history)-holdoverHistory:]
+	}
+}
+
+// RecordLoss records a cycle in which no time source answered and reports
+// whether holdover starts with it
+func (h *HoldoverTracker) RecordLoss() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entered := !h.active
+	h.active = true
+	return entered
+}
+
+// Estimate returns the holdover state at now. kernel is the current kernel
+// state, nil without kernel access.
+func (h *HoldoverTracker) Estimate(now time.Time, kernel *KernelTimex) HoldoverEstimate {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	est := HoldoverEstimate{
+		Active:   h.active,
+		Synced:   !h.lastSync.IsZero(),
+		LastSync: h.lastSync,
+		Method:   HoldoverMethodNone,
+	}
+	if !est.Synced {
+		if est.Active {
+			est.Duration = now.Sub(h.started)
+		}
+		return est
+	}
+	if est.Active {
+		est.Duration = now.Sub(h.lastSync)
+	}
+
+	initial := h.offset.Abs()
+	switch {
+	case kernel != nil:
+		est.Method = HoldoverMethodKernel
+		est.DriftPPM = mathutil.AbsFloat64(kernel.GetFrequencyPPM())
+		if h.kernel {
+			initial = h.maxError
+		}
+	case len(h.history) >= 2:
+		if slope, ok := offsetSlope(h.history); ok {
+			est.Method = HoldoverMethodNTPDrift
+			est.DriftPPM = mathutil.AbsFloat64(slope) * 1e6
+		}
+	}
+
+	est.ErrorBound = initial + time.Duration(est.DriftPPM/1e6*float64(est.Duration))
+	return est
+}
+
+// offsetSlope returns the least-squares slope of the offsets against time
+// (seconds per second); false when the samples span no time
+func offsetSlope(samples []offsetSample) (float64, bool) {
+	origin := samples[0].at
+	n := float64(len(samples))
+
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.at.Sub(origin).Seconds()
+		sumY += s.offset.Seconds()
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for _, s := range samples {
+		dx := s.at.Sub(origin).Seconds() - meanX
+		sxx += dx * dx
+		sxy += dx * (s.offset.Seconds() - meanY)
+	}
+	if sxx == 0 {
+		return 0, false
+	}
+	return sxy / sxx, true
+}
@@ -0,0 +1,80 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoldoverTracker_NeverSynced(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	h := NewHoldoverTracker(start)
+
+	assert.Equal(t, HoldoverEstimate{Method: HoldoverMethodNone}, h.Estimate(start, nil))
+
+	assert.True(t, h.RecordLoss())
+	assert.False(t, h.RecordLoss(), "holdover is entered once")
+	est := h.Estimate(start.Add(time.Minute), nil)
+	assert.True(t, est.Active)
+	assert.False(t, est.Synced)
+	assert.Equal(t, time.Minute, est.Duration)
+	assert.Zero(t, est.ErrorBound)
+}
+
+func TestHoldoverTracker_NTPDrift(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	h := NewHoldoverTracker(start)
+
+	// The local clock gains 20 ppm: offsets to the servers decrease
+	now := start
+	for i := 0; i < 10; i++ {
+		h.RecordSync(now, time.Millisecond-time.Duration(i)*600*time.Microsecond, nil)
+		now = now.Add(30 * time.Second)
+	}
+	lastSync := now.Add(-30 * time.Second)
+
+	est := h.Estimate(lastSync, nil)
+	assert.False(t, est.Active)
+	assert.Equal(t, HoldoverMethodNTPDrift, est.Method)
+	assert.InDelta(t, 20, est.DriftPPM, 1e-6)
+	assert.Zero(t, est.Duration)
+
+	assert.True(t, h.RecordLoss())
+	est = h.Estimate(lastSync.Add(time.Hour), nil)
+	assert.True(t, est.Active)
+	assert.Equal(t, lastSync, est.LastSync)
+	assert.Equal(t, time.Hour, est.Duration)
+	// |last offset| (4.4ms) + 20 ppm over an hour (72ms)
+	assert.InDelta(t, 0.0764, est.ErrorBound.Seconds(), 1e-6)
+
+	// A source answers again
+	h.RecordSync(lastSync.Add(time.Hour), 0, nil)
+	assert.False(t, h.Estimate(lastSync.Add(time.Hour), nil).Active)
+}
+
+func TestHoldoverTracker_Kernel(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	h := NewHoldoverTracker(start)
+
+	h.RecordSync(start, 300*time.Microsecond, &KernelTimex{MaxError: 5 * time.Millisecond, Frequency: 65536 * 10})
+	h.RecordLoss()
+
+	// The kernel frequency of -15 ppm applies over the holdover
+	est := h.Estimate(start.Add(2*time.Hour), &KernelTimex{MaxError: 3 * time.Second, Frequency: -65536 * 15})
+	assert.Equal(t, HoldoverMethodKernel, est.Method)
+	assert.Equal(t, 15.0, est.DriftPPM)
+	assert.InDelta(t, 0.005+0.108, est.ErrorBound.Seconds(), 1e-6)
+}
+
+func TestHoldoverTracker_SingleSample(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	h := NewHoldoverTracker(start)
+
+	h.RecordSync(start, -2*time.Millisecond, nil)
+	h.RecordLoss()
+
+	est := h.Estimate(start.Add(time.Hour), nil)
+	assert.Equal(t, HoldoverMethodNone, est.Method)
+	assert.Equal(t, 2*time.Millisecond, est.ErrorBound)
+}
@@ -2,6 +2,13 @@ package ntp
 
 import "time"
 
+// Kernel clock discipline modes
+const (
+	DisciplineNone = "none"
+	DisciplinePLL  = "pll"
+	DisciplineFLL  = "fll"
+)
+
 // KernelTimex represents the kernel NTP state
 type KernelTimex struct {
 	Offset     time.Duration // Time offset in microseconds
@@ -125,6 +125,20 @@ func (k *KernelTimex) IsPPSActive() bool {
 	return (k.Status & STA_PPSSIGNAL) != 0
 }
 
+// DisciplineMode returns how the kernel disciplines the clock: "pll", "fll"
+// (frequency-lock, selected with STA_FLL on top of STA_PLL) or "none" when
+// the daemon adjusts the clock itself or nothing does
+func (k *KernelTimex) DisciplineMode() string {
+	switch {
+	case k.Status&STA_PLL == 0:
+		return DisciplineNone
+	case k.Status&STA_FLL != 0:
+		return DisciplineFLL
+	default:
+		return DisciplinePLL
+	}
+}
+
 // getStatusString converts numeric status to human-readable string
 func getStatusString(status int32) string {
 	if (status & STA_UNSYNC) != 0 {
@@ -108,6 +108,30 @@ func TestKernelTimexIsPPSActiveLinux(t *testing.T) {
 	}
 }
 
+func TestKernelTimexDisciplineModeLinux(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int32
+		expected string
+	}{
+		{"no_discipline", 0, DisciplineNone},
+		{"fll_without_pll", STA_FLL, DisciplineNone},
+		{"pll", STA_PLL, DisciplinePLL},
+		{"fll", STA_PLL | STA_FLL, DisciplineFLL},
+		{"pll_unsync", STA_PLL | STA_UNSYNC, DisciplinePLL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kt := &KernelTimex{Status: tt.status}
+			if result := kt.DisciplineMode(); result != tt.expected {
+				t.Errorf("DisciplineMode() = %q, want %q (status=0x%04x)",
+					result, tt.expected, tt.status)
+			}
+		})
+	}
+}
+
 func TestKernelReaderReadLinux(t *testing.T) {
 	kr := NewKernelReader(true)
 	state, err := kr.Read()
@@ -24,3 +24,8 @@ func (k *KernelTimex) HasLeapSecond() bool {
 func (k *KernelTimex) IsPPSActive() bool {
 	return false
 }
+
+// DisciplineMode returns "none" on non-Linux platforms
+func (k *KernelTimex) DisciplineMode() string {
+	return DisciplineNone
+}
@@ -3,7 +3,9 @@ package ntp
 import (
 	"context"
 	"errors"
+	"os"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -51,7 +53,7 @@ func (m *MockNTPClient) Query(ctx context.Context, server string) (*Response, er
 		m.flapCounter[server]++
 		if m.flapCounter[server]%2 == 0 {
 			m.mu.Unlock()
-			return nil, errors.New("connection refused")
+			return nil, syscall.ECONNREFUSED
 		}
 	}
 
@@ -128,7 +130,7 @@ func (m *MockNTPClient) SetupUnreachableServer(server string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.errors[server] = errors.New("i/o timeout")
+	m.errors[server] = os.ErrDeadlineExceeded
 }
 
 // SetupKoDServer configures a Kiss-of-Death server response
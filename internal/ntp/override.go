@@ -0,0 +1,133 @@
+package ntp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OverrideMode selects how a manual override treats a server's circuit breaker.
+type OverrideMode string
+
+const (
+	// OverrideOpen stops all queries to the server, as if its breaker were open.
+	OverrideOpen OverrideMode = "open"
+	// OverrideClosed queries the server regardless of failures, bypassing its breaker.
+	OverrideClosed OverrideMode = "closed"
+)
+
+// ErrForcedOpen is returned for queries to a server forced open by an override.
+var ErrForcedOpen = errors.New("circuit breaker forced open")
+
+// Override is a manual circuit breaker decision for one server.
+type Override struct {
+	Server    string       `json:"server"`
+	Mode      OverrideMode `json:"mode"`
+	Reason    string       `json:"reason,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Until     time.Time    `json:"until,omitzero"` // Zero means until cleared
+}
+
+// Active reports whether the override still applies at now.
+func (o Override) Active(now time.Time) bool {
+	return o.Until.IsZero() || now.Before(o.Until)
+}
+
+// OverrideStore holds manual overrides, independently of the breakers so a
+// reset breaker keeps its override. Overrides are held in memory only and are
+// lost on restart.
+type OverrideStore struct {
+	mu        sync.RWMutex
+	overrides map[string]Override
+	now       func() time.Time
+}
+
+// NewOverrideStore creates an empty override store.
+func NewOverrideStore() *OverrideStore {
+	return &OverrideStore{
+		overrides: make(map[string]Override),
+		now:       time.Now,
+	}
+}
+
+// Set installs an override for a server for the given duration (0 for no expiry),
+// replacing any existing one.
+func (s *OverrideStore) Set(server string, mode OverrideMode, duration time.Duration, reason string) (Override, error) {
+	if server == "" {
+		return Override{}, errors.New("server is required")
+	}
+	if mode != OverrideOpen && mode != OverrideClosed {
+		return Override{}, fmt.Errorf("invalid override mode %q (expected %q or %q)", mode, OverrideOpen, OverrideClosed)
+	}
+	if duration < 0 {
+		return Override{}, fmt.Errorf("duration must not be negative, got %v", duration)
+	}
+
+	now := s.now()
+	o := Override{Server: server, Mode: mode, Reason: reason, CreatedAt: now}
+	if duration > 0 {
+		o.Until = now.Add(duration)
+	}
+
+	s.mu.Lock()
+	s.overrides[server] = o
+	s.mu.Unlock()
+
+	return o, nil
+}
+
+// Clear removes the override for a server and reports whether one existed.
+func (s *OverrideStore) Clear(server string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.overrides[server]
+	delete(s.overrides, server)
+	return exists
+}
+
+// Get returns the active override for a server. Expired overrides are dropped.
+func (s *OverrideStore) Get(server string) (Override, bool) {
+	s.mu.RLock()
+	o, exists := s.overrides[server]
+	s.mu.RUnlock()
+
+	if !exists {
+		return Override{}, false
+	}
+	if !o.Active(s.now()) {
+		s.mu.Lock()
+		if current, ok := s.overrides[server]; ok && current == o {
+			delete(s.overrides, server)
+		}
+		s.mu.Unlock()
+		return Override{}, false
+	}
+
+	return o, true
+}
+
+// List returns the active overrides sorted by server. Expired overrides are dropped.
+func (s *OverrideStore) List() []Override {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Override, 0, len(s.overrides))
+	for server, o := range s.overrides {
+		if !o.Active(now) {
+			delete(s.overrides, server)
+			continue
+		}
+		list = append(list, o)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Server < list[j].Server
+	})
+
+	return list
+}
@@ -0,0 +1,63 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideStore_SetGetClear(t *testing.T) {
+	store := NewOverrideStore()
+
+	o, err := store.Set("a.example", OverrideOpen, time.Hour, "maintenance")
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", o.Reason)
+	assert.False(t, o.Until.IsZero())
+
+	got, ok := store.Get("a.example")
+	require.True(t, ok)
+	assert.Equal(t, o, got)
+
+	assert.True(t, store.Clear("a.example"))
+	assert.False(t, store.Clear("a.example"))
+	_, ok = store.Get("a.example")
+	assert.False(t, ok)
+}
+
+func TestOverrideStore_Expiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewOverrideStore()
+	store.now = func() time.Time { return now }
+
+	_, err := store.Set("short.example", OverrideOpen, time.Minute, "")
+	require.NoError(t, err)
+	_, err = store.Set("pinned.example", OverrideClosed, 0, "")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+
+	_, ok := store.Get("short.example")
+	assert.False(t, ok, "expired override should not apply")
+
+	list := store.List()
+	require.Len(t, list, 1)
+	assert.Equal(t, "pinned.example", list[0].Server)
+	assert.True(t, list[0].Until.IsZero())
+}
+
+func TestOverrideStore_SetInvalid(t *testing.T) {
+	store := NewOverrideStore()
+
+	_, err := store.Set("", OverrideOpen, 0, "")
+	assert.Error(t, err)
+
+	_, err = store.Set("a.example", "half-open", 0, "")
+	assert.Error(t, err)
+
+	_, err = store.Set("a.example", OverrideOpen, -time.Second, "")
+	assert.Error(t, err)
+
+	assert.Empty(t, store.List())
+}
@@ -0,0 +1,181 @@
+package ntp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/pkg/mathutil"
+)
+
+// Poll scheduling parameters
+const (
+	// DefaultMinPoll and DefaultMaxPoll are the poll exponent bounds of ntpd:
+	// 2^6 = 64s and 2^10 = 1024s
+	DefaultMinPoll = 6
+	DefaultMaxPoll = 10
+
+	// MinPollLimit and MaxPollLimit bound the configurable poll exponents
+	MinPollLimit = 3
+	MaxPollLimit = 17
+
+	// pollStableLimit is the number of consecutive stable polls before the
+	// poll interval is doubled
+	pollStableLimit = 4
+
+	// pollGate is how many times its jitter an offset may move between two
+	// polls before the server counts as drifting (PGATE in ntpd)
+	pollGate = 4
+
+	// pollJitterFloor keeps servers with a tiny jitter from counting every
+	// sub-millisecond move as drift
+	pollJitterFloor = time.Millisecond
+)
+
+// pollState is the schedule of one server
+type pollState struct {
+	exponent int
+	next     time.Time
+	stable   int // Consecutive stable polls
+
+	answered  bool          // Whether the server answered the last poll
+	offset    time.Duration // Offset of the last answer
+	jitter    time.Duration // Running average of the offset moves
+	stratum   uint8
+	leap      uint8
+	reference uint32
+}
+
+// PollScheduler gives each server its own poll interval, the way ntpd
+// adjusts its poll exponent: a server that keeps answering with a steady
+// offset is polled half as often after pollStableLimit polls, up to
+// 2^maxPoll seconds; a server that stops answering, whose offset moves
+// beyond pollGate times its jitter, or whose stratum, leap indicator or
+// reference changes is polled twice as often, down to 2^minPoll seconds.
+type PollScheduler struct {
+	mu      sync.Mutex
+	minPoll int
+	maxPoll int
+	servers map[string]*pollState
+}
+
+// NewPollScheduler creates a scheduler with the given poll exponent bounds
+func NewPollScheduler(minPoll, maxPoll int) *PollScheduler {
+	return &PollScheduler{
+		minPoll: minPoll,
+		maxPoll: maxPoll,
+		servers: make(map[string]*pollState),
+	}
+}
+
+// Record updates the schedule of server after a poll at now; resp is nil
+// when the server did not answer
+func (s *PollScheduler) Record(server string, now time.Time, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, seen := s.servers[server]
+	if !seen {
+		st = &pollState{exponent: s.minPoll}
+		s.servers[server] = st
+	}
+
+	stable := seen && resp != nil && st.answered
+	if resp != nil {
+		if stable {
+			move := (resp.Offset - st.offset).Abs()
+			if move > mathutil.MaxDuration(pollGate*st.jitter, pollJitterFloor) ||
+				resp.Stratum != st.stratum || resp.LeapIndicator != st.leap || resp.ReferenceID != st.reference {
+				stable = false
+			}
+			st.jitter += (move - st.jitter) / 4
+		}
+		st.offset = resp.Offset
+		st.stratum = resp.Stratum
+		st.leap = resp.LeapIndicator
+		st.reference = resp.ReferenceID
+	}
+	st.answered = resp != nil
+
+	switch {
+	case stable:
+		st.stable++
+		if st.stable >= pollStableLimit && st.exponent < s.maxPoll {
+			st.exponent++
+			st.stable = 0
+		}
+	case seen:
+		if st.exponent > s.minPoll {
+			st.exponent--
+		}
+		st.stable = 0
+	}
+	st.next = now.Add(pollInterval(st.exponent))
+}
+
+// Due returns the servers to poll at now, in order; servers never polled are due
+func (s *PollScheduler) Due(now time.Time, servers []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if st, ok := s.servers[server]; !ok || !st.next.After(now) {
+			due = append(due, server)
+		}
+	}
+	return due
+}
+
+// Next returns when the next of servers is due, now if one already is
+func (s *PollScheduler) Next(now time.Time, servers []string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := now.Add(pollInterval(s.maxPoll))
+	for _, server := range servers {
+		st, ok := s.servers[server]
+		if !ok || !st.next.After(now) {
+			return now
+		}
+		if st.next.Before(next) {
+			next = st.next
+		}
+	}
+	return next
+}
+
+// Answering reports whether a server answered its last poll
+func (s *PollScheduler) Answering() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.servers {
+		if st.answered {
+			return true
+		}
+	}
+	return false
+}
+
+// Interval returns the current poll interval of a server, 0 if never polled
+func (s *PollScheduler) Interval(server string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.servers[server]; ok {
+		return pollInterval(st.exponent)
+	}
+	return 0
+}
+
+// Forget drops the schedule of a server no longer collected
+func (s *PollScheduler) Forget(server string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.servers, server)
+}
+
+// pollInterval converts a poll exponent to an interval
+func pollInterval(exponent int) time.Duration {
+	return time.Duration(1<<exponent) * time.Second
+}
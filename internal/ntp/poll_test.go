@@ -0,0 +1,70 @@
+package ntp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollScheduler_BackOffAndTighten(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewPollScheduler(4, 6)
+	steady := &Response{Offset: time.Millisecond, Stratum: 2, ReferenceID: 1}
+
+	assert.Zero(t, s.Interval("a"))
+	assert.False(t, s.Answering())
+	s.Record("a", now, steady)
+	assert.Equal(t, 16*time.Second, s.Interval("a"))
+	assert.True(t, s.Answering())
+
+	// Stable answers double the interval every pollStableLimit polls, up to maxPoll
+	for i := 0; i < 3*pollStableLimit; i++ {
+		s.Record("a", now, steady)
+	}
+	assert.Equal(t, 64*time.Second, s.Interval("a"))
+
+	// A drifting offset tightens the interval
+	s.Record("a", now, &Response{Offset: 20 * time.Millisecond, Stratum: 2, ReferenceID: 1})
+	assert.Equal(t, 32*time.Second, s.Interval("a"))
+
+	// So do a stratum change and a lost answer, down to minPoll
+	s.Record("a", now, &Response{Offset: 20 * time.Millisecond, Stratum: 3, ReferenceID: 1})
+	assert.Equal(t, 16*time.Second, s.Interval("a"))
+	s.Record("a", now, nil)
+	assert.Equal(t, 16*time.Second, s.Interval("a"))
+	assert.False(t, s.Answering())
+
+	// Answering again is a state change as well, then stability resumes
+	s.Record("a", now, steady)
+	for i := 0; i < pollStableLimit; i++ {
+		s.Record("a", now, steady)
+	}
+	assert.Equal(t, 32*time.Second, s.Interval("a"))
+
+	s.Forget("a")
+	assert.Zero(t, s.Interval("a"))
+}
+
+func TestPollScheduler_Due(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewPollScheduler(4, 10)
+	servers := []string{"a", "b", "c"}
+
+	// Servers never polled are due
+	assert.Equal(t, servers, s.Due(now, servers))
+	assert.Equal(t, now, s.Next(now, servers))
+
+	for _, server := range servers {
+		s.Record(server, now, &Response{})
+	}
+	s.Record("b", now.Add(5*time.Second), nil)
+
+	assert.Empty(t, s.Due(now.Add(10*time.Second), servers))
+	assert.Equal(t, now.Add(16*time.Second), s.Next(now.Add(10*time.Second), servers))
+	assert.Equal(t, []string{"a", "c"}, s.Due(now.Add(16*time.Second), servers))
+	assert.Equal(t, servers, s.Due(now.Add(21*time.Second), servers))
+
+	// A server added later is due at once
+	assert.Equal(t, []string{"d"}, s.Due(now.Add(10*time.Second), append(servers, "d")))
+}
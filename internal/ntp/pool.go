@@ -62,6 +62,11 @@ func (p *Pool) EnableWorkerPool(size int) {
 	p.useWorkerPool = true
 }
 
+// SetDNSCache makes the pool resolve through a cache shared with other pools
+func (p *Pool) SetDNSCache(cache *DNSCache) {
+	p.dnsCache = cache
+}
+
 // Resolve resolves the pool DNS to get individual server IPs using cache
 func (p *Pool) Resolve(ctx context.Context) ([]string, time.Duration, error) {
 	start := time.Now()
@@ -4,10 +4,28 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// Rate limiter scopes reported to a RateLimitObserver
+const (
+	RateLimitScopeGlobal = "global"
+	RateLimitScopeServer = "server"
+)
+
+// RateLimitObserver is notified of rate limiting decisions
+type RateLimitObserver interface {
+	// ObserveWait reports the total time a query to server waited for tokens
+	ObserveWait(server string, wait time.Duration)
+	// ObserveThrottled reports a query that had to wait in the given scope
+	ObserveThrottled(scope, server string)
+	// ObserveRejected reports a query refused in the given scope, because it was
+	// cancelled or could not get a token before its deadline
+	ObserveRejected(scope, server string)
+}
+
 // RateLimiter manages rate limiting for NTP queries
 type RateLimiter struct {
 	global        *rate.Limiter
@@ -15,34 +33,121 @@ type RateLimiter struct {
 	mu            sync.RWMutex
 	perServerRate int
 	burstSize     int
+
+	// Effective per-server limit, initially perServerRate/burstSize
+	perServerLimit rate.Limit
+	perServerBurst int
+
+	observer RateLimitObserver
 }
 
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(globalRate, perServerRate, burstSize int) *RateLimiter {
 	return &RateLimiter{
-		global:        rate.NewLimiter(rate.Limit(globalRate), burstSize),
-		perServer:     make(map[string]*rate.Limiter),
-		perServerRate: perServerRate,
-		burstSize:     burstSize,
+		global:         rate.NewLimiter(rate.Limit(globalRate), burstSize),
+		perServer:      make(map[string]*rate.Limiter),
+		perServerRate:  perServerRate,
+		burstSize:      burstSize,
+		perServerLimit: rate.Limit(perServerRate),
+		perServerBurst: burstSize,
 	}
 }
 
+// SetObserver sets the observer notified of waits, throttling and rejections
+func (rl *RateLimiter) SetObserver(observer RateLimitObserver) {
+	rl.observer = observer
+}
+
+// SetPerServerLimit changes the per-server rate (queries per second, may be
+// fractional) and burst, including for servers already seen
+func (rl *RateLimiter) SetPerServerLimit(perSecond float64, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.perServerLimit = rate.Limit(perSecond)
+	rl.perServerBurst = burst
+	for _, limiter := range rl.perServer {
+		limiter.SetLimit(rl.perServerLimit)
+		limiter.SetBurst(burst)
+	}
+}
+
+// PerServerLimit returns the effective per-server rate (queries per second) and burst
+func (rl *RateLimiter) PerServerLimit() (float64, int) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return float64(rl.perServerLimit), rl.perServerBurst
+}
+
 // Wait waits for permission to make a query to the specified server
 func (rl *RateLimiter) Wait(ctx context.Context, server string) error {
+	start := time.Now()
+
 	// Global rate limit
-	if err := rl.global.Wait(ctx); err != nil {
+	if err := rl.wait(ctx, rl.global, RateLimitScopeGlobal, server); err != nil {
 		return fmt.Errorf("global rate limit: %w", err)
 	}
 
 	// Per-server rate limit
 	limiter := rl.getLimiterForServer(server)
-	if err := limiter.Wait(ctx); err != nil {
+	if err := rl.wait(ctx, limiter, RateLimitScopeServer, server); err != nil {
 		return fmt.Errorf("per-server rate limit for %s: %w", server, err)
 	}
 
+	if rl.observer != nil {
+		rl.observer.ObserveWait(server, time.Since(start))
+	}
 	return nil
 }
 
+// wait is rate.Limiter.Wait reporting throttling and rejections to the observer
+func (rl *RateLimiter) wait(ctx context.Context, limiter *rate.Limiter, scope, server string) error {
+	if err := ctx.Err(); err != nil {
+		rl.rejected(scope, server)
+		return err
+	}
+
+	r := limiter.Reserve()
+	if !r.OK() {
+		rl.rejected(scope, server)
+		return fmt.Errorf("burst of %d does not allow any query", limiter.Burst())
+	}
+
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	if rl.observer != nil {
+		rl.observer.ObserveThrottled(scope, server)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel()
+		rl.rejected(scope, server)
+		return fmt.Errorf("waiting %v would exceed context deadline", delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		rl.rejected(scope, server)
+		return ctx.Err()
+	}
+}
+
+// rejected notifies the observer of a rejection
+func (rl *RateLimiter) rejected(scope, server string) {
+	if rl.observer != nil {
+		rl.observer.ObserveRejected(scope, server)
+	}
+}
+
 // getLimiterForServer gets or creates a rate limiter for a server
 func (rl *RateLimiter) getLimiterForServer(server string) *rate.Limiter {
 	rl.mu.RLock()
@@ -61,16 +166,41 @@ func (rl *RateLimiter) getLimiterForServer(server string) *rate.Limiter {
 		return limiter
 	}
 
-	limiter = rate.NewLimiter(rate.Limit(rl.perServerRate), rl.burstSize)
+	limiter = rate.NewLimiter(rl.perServerLimit, rl.perServerBurst)
 	rl.perServer[server] = limiter
 	return limiter
 }
 
+// Forget drops the limiter of a server no longer queried
+func (rl *RateLimiter) Forget(server string) {
+	rl.mu.Lock()
+	delete(rl.perServer, server)
+	rl.mu.Unlock()
+}
+
 // Allow checks if a query is allowed without waiting
 func (rl *RateLimiter) Allow(server string) bool {
 	if !rl.global.Allow() {
+		rl.rejected(RateLimitScopeGlobal, server)
 		return false
 	}
 	limiter := rl.getLimiterForServer(server)
-	return limiter.Allow()
+	if !limiter.Allow() {
+		rl.rejected(RateLimitScopeServer, server)
+		return false
+	}
+	return true
+}
+
+// Tokens returns the tokens currently available in the global limiter and in
+// the limiter of each server seen so far
+func (rl *RateLimiter) Tokens() (float64, map[string]float64) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	perServer := make(map[string]float64, len(rl.perServer))
+	for server, limiter := range rl.perServer {
+		perServer[server] = limiter.Tokens()
+	}
+	return rl.global.Tokens(), perServer
 }
@@ -2,6 +2,7 @@ package ntp
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 )
@@ -189,6 +190,152 @@ func TestRateLimiterConcurrentAccess(t *testing.T) {
 	}
 }
 
+// recordingObserver records rate limiter notifications
+type recordingObserver struct {
+	mu        sync.Mutex
+	waits     map[string]int
+	throttled map[string]int // by scope
+	rejected  map[string]int // by scope
+}
+
+func newRecordingObserver() *recordingObserver {
+	return &recordingObserver{
+		waits:     make(map[string]int),
+		throttled: make(map[string]int),
+		rejected:  make(map[string]int),
+	}
+}
+
+func (o *recordingObserver) ObserveWait(server string, wait time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.waits[server]++
+}
+
+func (o *recordingObserver) ObserveThrottled(scope, server string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.throttled[scope]++
+}
+
+func (o *recordingObserver) ObserveRejected(scope, server string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rejected[scope]++
+}
+
+func TestRateLimiterObserver(t *testing.T) {
+	rl := NewRateLimiter(1000, 10, 1)
+	obs := newRecordingObserver()
+	rl.SetObserver(obs)
+
+	ctx := context.Background()
+	server := "test.server.com"
+
+	// First query uses the burst, second one waits for the per-server limiter
+	for i := 0; i < 2; i++ {
+		if err := rl.Wait(ctx, server); err != nil {
+			t.Fatalf("Wait %d failed: %v", i, err)
+		}
+	}
+	if obs.waits[server] != 2 {
+		t.Errorf("Expected 2 observed waits, got %d", obs.waits[server])
+	}
+	if obs.throttled[RateLimitScopeServer] != 1 {
+		t.Errorf("Expected 1 per-server throttle, got %d", obs.throttled[RateLimitScopeServer])
+	}
+
+	// A deadline shorter than the delay is rejected without waiting
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.Wait(deadlineCtx, server); err == nil {
+		t.Error("Expected rejection when the deadline is shorter than the delay")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Rejection should not wait, took %v", elapsed)
+	}
+	if obs.rejected[RateLimitScopeServer] != 1 {
+		t.Errorf("Expected 1 per-server rejection, got %d", obs.rejected[RateLimitScopeServer])
+	}
+	if obs.waits[server] != 2 {
+		t.Errorf("Rejected query should not be observed as a wait, got %d waits", obs.waits[server])
+	}
+
+	// Allow reports rejections too (once the global limiter has refilled)
+	time.Sleep(5 * time.Millisecond)
+	if rl.Allow(server) {
+		t.Error("Allow should be rejected while the per-server limiter is empty")
+	}
+	if obs.rejected[RateLimitScopeServer] != 2 {
+		t.Errorf("Expected 2 per-server rejections, got %d", obs.rejected[RateLimitScopeServer])
+	}
+}
+
+func TestRateLimiterTokens(t *testing.T) {
+	rl := NewRateLimiter(100, 10, 5)
+
+	global, perServer := rl.Tokens()
+	if global != 5 {
+		t.Errorf("Expected 5 global tokens, got %v", global)
+	}
+	if len(perServer) != 0 {
+		t.Errorf("Expected no per-server tokens, got %v", perServer)
+	}
+
+	rl.Allow("server1")
+	rl.Allow("server1")
+	global, perServer = rl.Tokens()
+	if global > 3.1 {
+		t.Errorf("Expected about 3 global tokens, got %v", global)
+	}
+	if tokens := perServer["server1"]; tokens > 3.1 {
+		t.Errorf("Expected about 3 tokens for server1, got %v", tokens)
+	}
+}
+
+func TestRateLimiterSetPerServerLimit(t *testing.T) {
+	rl := NewRateLimiter(1000, 10, 5)
+	rl.Allow("existing")
+
+	rl.SetPerServerLimit(0.5, 8)
+
+	perSecond, burst := rl.PerServerLimit()
+	if perSecond != 0.5 || burst != 8 {
+		t.Errorf("Expected 0.5/s burst 8, got %v/s burst %d", perSecond, burst)
+	}
+
+	// Existing and new limiters both use the new limit
+	for _, server := range []string{"existing", "new"} {
+		limiter := rl.getLimiterForServer(server)
+		if float64(limiter.Limit()) != 0.5 || limiter.Burst() != 8 {
+			t.Errorf("%s: expected 0.5/s burst 8, got %v/s burst %d", server, limiter.Limit(), limiter.Burst())
+		}
+	}
+
+	// The configured values are left untouched
+	if rl.perServerRate != 10 || rl.burstSize != 5 {
+		t.Errorf("Configured limits changed: %d/s burst %d", rl.perServerRate, rl.burstSize)
+	}
+}
+
+func TestRateLimiterForget(t *testing.T) {
+	rl := NewRateLimiter(100, 10, 5)
+	rl.Allow("gone")
+	rl.Allow("kept")
+
+	rl.Forget("gone")
+	rl.Forget("unknown")
+
+	_, perServer := rl.Tokens()
+	if _, exists := perServer["gone"]; exists {
+		t.Error("Expected the forgotten server to have no limiter")
+	}
+	if _, exists := perServer["kept"]; !exists {
+		t.Error("Expected other servers to keep their limiter")
+	}
+}
+
 func BenchmarkRateLimiterWait(b *testing.B) {
 	rl := NewRateLimiter(100000, 10000, 100)
 	ctx := context.Background()
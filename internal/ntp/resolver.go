@@ -0,0 +1,430 @@
+package ntp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS transports of an upstream server
+const (
+	DNSTransportUDP   = "udp"   // Plain DNS, retried over TCP when truncated
+	DNSTransportTCP   = "tcp"   // Plain DNS over TCP
+	DNSTransportTLS   = "tls"   // DNS-over-TLS (RFC 7858)
+	DNSTransportHTTPS = "https" // DNS-over-HTTPS (RFC 8484)
+)
+
+const (
+	defaultDNSTimeout  = 5 * time.Second
+	maxDNSMessageBytes = 65535
+	dnsMessageType     = "application/dns-message"
+)
+
+// DNSAnswer is the result of a hostname lookup
+type DNSAnswer struct {
+	IPs []string
+	TTL time.Duration // Smallest TTL of the address records, 0 when unknown
+}
+
+// DNSResolver resolves hostnames to IP addresses
+type DNSResolver interface {
+	Lookup(ctx context.Context, hostname string) (DNSAnswer, error)
+}
+
+// SystemResolver resolves with the system configuration. Record TTLs are not
+// available through it, so answers carry no TTL.
+type SystemResolver struct {
+	resolver *net.Resolver
+}
+
+// NewSystemResolver creates a resolver using the system configuration
+func NewSystemResolver() *SystemResolver {
+	return &SystemResolver{resolver: &net.Resolver{PreferGo: true}}
+}
+
+// Lookup implements DNSResolver
+func (r *SystemResolver) Lookup(ctx context.Context, hostname string) (DNSAnswer, error) {
+	addrs, err := r.resolver.LookupHost(ctx, hostname)
+	if err != nil {
+		return DNSAnswer{}, err
+	}
+	return DNSAnswer{IPs: addrs}, nil
+}
+
+// DNSUpstream is an upstream DNS server
+type DNSUpstream struct {
+	Transport string
+	Address   string // host:port, or the query URL for DNSTransportHTTPS
+}
+
+// String returns the upstream in the form accepted by ParseDNSUpstream
+func (u DNSUpstream) String() string {
+	if u.Transport == DNSTransportHTTPS {
+		return u.Address
+	}
+	return u.Transport + "://" + u.Address
+}
+
+// ParseDNSUpstream parses an upstream server: "host[:port]" or
+// "udp://host[:port]" for plain DNS, "tcp://host[:port]", "tls://host[:port]"
+// for DNS-over-TLS (port 853 by default) or an https:// DNS-over-HTTPS URL
+func ParseDNSUpstream(s string) (DNSUpstream, error) {
+	scheme, rest, found := strings.Cut(s, "://")
+	if !found {
+		scheme, rest = DNSTransportUDP, s
+	}
+
+	var port string
+	switch scheme {
+	case DNSTransportUDP, DNSTransportTCP:
+		port = "53"
+	case DNSTransportTLS:
+		port = "853"
+	case DNSTransportHTTPS:
+		u, err := url.Parse(s)
+		if err != nil {
+			return DNSUpstream{}, fmt.Errorf("invalid DNS-over-HTTPS URL %q: %w", s, err)
+		}
+		if u.Host == "" {
+			return DNSUpstream{}, fmt.Errorf("invalid DNS-over-HTTPS URL %q: missing host", s)
+		}
+		return DNSUpstream{Transport: DNSTransportHTTPS, Address: s}, nil
+	default:
+		return DNSUpstream{}, fmt.Errorf("unsupported DNS transport %q in %q (must be udp, tcp, tls or https)", scheme, s)
+	}
+
+	if rest == "" || strings.Contains(rest, "/") {
+		return DNSUpstream{}, fmt.Errorf("invalid DNS server %q", s)
+	}
+	if _, _, err := net.SplitHostPort(rest); err != nil {
+		// No port: bare host or IP, including unbracketed IPv6
+		rest = net.JoinHostPort(strings.Trim(rest, "[]"), port)
+	}
+	return DNSUpstream{Transport: scheme, Address: rest}, nil
+}
+
+// UpstreamResolverConfig configures an UpstreamResolver
+type UpstreamResolverConfig struct {
+	Servers       []DNSUpstream
+	Timeout       time.Duration // Per server (default: 5s)
+	TLSServerName string        // Certificate name for DNS-over-TLS (default: server host)
+	TLSConfig     *tls.Config   // Base TLS configuration for DNS-over-TLS and DNS-over-HTTPS
+}
+
+// UpstreamResolver looks up A and AAAA records directly from upstream
+// servers, tried in order, and reports the record TTLs
+type UpstreamResolver struct {
+	servers       []DNSUpstream
+	timeout       time.Duration
+	tlsServerName string
+	tlsConfig     *tls.Config
+	httpClient    *http.Client
+}
+
+// NewUpstreamResolver creates a resolver querying the given upstream servers
+func NewUpstreamResolver(config UpstreamResolverConfig) (*UpstreamResolver, error) {
+	if len(config.Servers) == 0 {
+		return nil, errors.New("at least one upstream DNS server is required")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultDNSTimeout
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSConfig != nil {
+		tlsConfig = config.TLSConfig.Clone()
+	}
+
+	return &UpstreamResolver{
+		servers:       config.Servers,
+		timeout:       config.Timeout,
+		tlsServerName: config.TLSServerName,
+		tlsConfig:     tlsConfig,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   tlsConfig,
+				ForceAttemptHTTP2: true,
+				Proxy:             http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+// Lookup implements DNSResolver, falling back to the next server on failure.
+// A name that does not exist is not retried elsewhere.
+func (r *UpstreamResolver) Lookup(ctx context.Context, hostname string) (DNSAnswer, error) {
+	name, err := dnsmessage.NewName(fqdn(hostname))
+	if err != nil {
+		return DNSAnswer{}, &net.DNSError{Err: err.Error(), Name: hostname}
+	}
+
+	var errs []error
+	for _, server := range r.servers {
+		answer, err := r.lookup(ctx, server, name)
+		if err == nil {
+			if len(answer.IPs) == 0 {
+				return DNSAnswer{}, &net.DNSError{Err: "no such host", Name: hostname, Server: server.String(), IsNotFound: true}
+			}
+			return answer, nil
+		}
+		if ctx.Err() != nil {
+			return DNSAnswer{}, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+	}
+	return DNSAnswer{}, &net.DNSError{Err: errors.Join(errs...).Error(), Name: hostname}
+}
+
+// lookup queries the A and AAAA records of name from one server
+func (r *UpstreamResolver) lookup(ctx context.Context, server DNSUpstream, name dnsmessage.Name) (DNSAnswer, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var answer DNSAnswer
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		ips, ttl, err := r.query(ctx, server, name, qtype)
+		if err != nil {
+			return DNSAnswer{}, err
+		}
+		answer.IPs = append(answer.IPs, ips...)
+		if len(ips) > 0 && (answer.TTL == 0 || ttl < answer.TTL) {
+			answer.TTL = ttl
+		}
+	}
+	return answer, nil
+}
+
+// query sends one question and returns the matching addresses and their smallest TTL
+func (r *UpstreamResolver) query(ctx context.Context, server DNSUpstream, name dnsmessage.Name, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	id, err := newDNSID(server)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	req, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := r.exchange(ctx, server, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseDNSResponse(resp, id, qtype)
+}
+
+// exchange sends a packed query with the server transport and returns the packed response
+func (r *UpstreamResolver) exchange(ctx context.Context, server DNSUpstream, req []byte) ([]byte, error) {
+	switch server.Transport {
+	case DNSTransportUDP:
+		resp, err := r.exchangeUDP(ctx, server.Address, req)
+		if err != nil {
+			return nil, err
+		}
+		var p dnsmessage.Parser
+		if h, err := p.Start(resp); err == nil && h.Truncated {
+			return r.exchangeStream(ctx, DNSTransportTCP, server.Address, req)
+		}
+		return resp, nil
+	case DNSTransportTCP, DNSTransportTLS:
+		return r.exchangeStream(ctx, server.Transport, server.Address, req)
+	case DNSTransportHTTPS:
+		return r.exchangeHTTPS(ctx, server.Address, req)
+	default:
+		return nil, fmt.Errorf("unsupported DNS transport %q", server.Transport)
+	}
+}
+
+// exchangeUDP sends a query in one datagram
+func (r *UpstreamResolver) exchangeUDP(ctx context.Context, address string, req []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setConnDeadline(ctx, conn)
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxDNSMessageBytes)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// exchangeStream sends a length-prefixed query over TCP or TLS (RFC 1035 section 4.2.2)
+func (r *UpstreamResolver) exchangeStream(ctx context.Context, transport, address string, req []byte) ([]byte, error) {
+	var conn net.Conn
+	var err error
+	if transport == DNSTransportTLS {
+		config := r.tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = r.tlsServerName
+		}
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		d := tls.Dialer{Config: config}
+		conn, err = d.DialContext(ctx, "tcp", address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setConnDeadline(ctx, conn)
+
+	framed := make([]byte, 2+len(req))
+	binary.BigEndian.PutUint16(framed, uint16(len(req)))
+	copy(framed[2:], req)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// exchangeHTTPS POSTs a query to a DNS-over-HTTPS endpoint
+func (r *UpstreamResolver) exchangeHTTPS(ctx context.Context, endpoint string, req []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", dnsMessageType)
+	httpReq.Header.Set("Accept", dnsMessageType)
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDNSMessageBytes))
+}
+
+// parseDNSResponse extracts the addresses of type qtype from a response to query id
+func parseDNSResponse(resp []byte, id uint16, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return nil, 0, errors.New("DNS response does not match the query")
+	}
+
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("DNS server returned %s", h.RCode)
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+	}
+
+	var ips []string
+	var ttl time.Duration
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+		}
+		if rh.Type != qtype || rh.Class != dnsmessage.ClassINET {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+			}
+			continue
+		}
+
+		var ip net.IP
+		switch qtype {
+		case dnsmessage.TypeA:
+			rr, err := p.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+			}
+			ip = net.IP(rr.A[:])
+		default:
+			rr, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+			}
+			ip = net.IP(rr.AAAA[:])
+		}
+
+		ips = append(ips, ip.String())
+		recordTTL := time.Duration(rh.TTL) * time.Second
+		if len(ips) == 1 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+	}
+	return ips, ttl, nil
+}
+
+// newDNSID returns a random query ID, 0 for DNS-over-HTTPS to keep responses
+// cacheable (RFC 8484 section 4.1)
+func newDNSID(server DNSUpstream) (uint16, error) {
+	if server.Transport == DNSTransportHTTPS {
+		return 0, nil
+	}
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
+
+// setConnDeadline applies the context deadline to conn
+func setConnDeadline(ctx context.Context, conn net.Conn) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+}
+
+// fqdn returns hostname with a trailing dot
+func fqdn(hostname string) string {
+	if strings.HasSuffix(hostname, ".") {
+		return hostname
+	}
+	return hostname + "."
+}
@@ -0,0 +1,424 @@
+package ntp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStandIn is a local DNS server answering A and AAAA questions from records
+type dnsStandIn struct {
+	records     map[string][]string // FQDN -> addresses
+	ttl         atomic.Uint32       // Set by tests while the server goroutines read it
+	truncateUDP bool
+	queries     atomic.Int32
+}
+
+// answer builds the packed response to a packed query; udp responses are
+// truncated when truncateUDP is set
+func (s *dnsStandIn) answer(req []byte, udp bool) []byte {
+	s.queries.Add(1)
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(req); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	q := msg.Questions[0]
+
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 msg.ID,
+			Response:           true,
+			RecursionDesired:   msg.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: msg.Questions,
+	}
+
+	ips, found := s.records[q.Name.String()]
+	switch {
+	case !found:
+		resp.RCode = dnsmessage.RCodeNameError
+	case udp && s.truncateUDP:
+		resp.Truncated = true
+	default:
+		for _, addr := range ips {
+			ip := net.ParseIP(addr)
+			header := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: s.ttl.Load()}
+			if ip4 := ip.To4(); ip4 != nil && q.Type == dnsmessage.TypeA {
+				header.Type = dnsmessage.TypeA
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: [4]byte(ip4)}})
+			} else if ip.To4() == nil && q.Type == dnsmessage.TypeAAAA {
+				header.Type = dnsmessage.TypeAAAA
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte(ip)}})
+			}
+		}
+	}
+
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
+
+// serveUDP answers datagrams on addr ("127.0.0.1:0" for any port) and returns its address
+func (s *dnsStandIn) serveUDP(t *testing.T, addr string) string {
+	conn, err := net.ListenPacket("udp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxDNSMessageBytes)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.answer(buf[:n], true); resp != nil {
+				_, _ = conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// serveStream answers length-prefixed queries on listener and returns its address
+func (s *dnsStandIn) serveStream(t *testing.T, listener net.Listener) string {
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				req := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				resp := s.answer(req, false)
+				binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+				_, _ = conn.Write(append(length[:], resp...))
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// ServeHTTP answers DNS-over-HTTPS POST requests
+func (s *dnsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageType {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", dnsMessageType)
+	_, _ = w.Write(s.answer(req, false))
+}
+
+func newDNSStandIn() *dnsStandIn {
+	s := &dnsStandIn{
+		records: map[string][]string{
+			"pool.example.": {"192.0.2.1", "192.0.2.2", "2001:db8::1"},
+		},
+	}
+	s.ttl.Store(120)
+	return s
+}
+
+func TestParseDNSUpstream(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    DNSUpstream
+		wantErr bool
+	}{
+		{in: "192.0.2.53", want: DNSUpstream{Transport: DNSTransportUDP, Address: "192.0.2.53:53"}},
+		{in: "192.0.2.53:5353", want: DNSUpstream{Transport: DNSTransportUDP, Address: "192.0.2.53:5353"}},
+		{in: "2001:db8::53", want: DNSUpstream{Transport: DNSTransportUDP, Address: "[2001:db8::53]:53"}},
+		{in: "tcp://ns.example", want: DNSUpstream{Transport: DNSTransportTCP, Address: "ns.example:53"}},
+		{in: "tls://1.1.1.1", want: DNSUpstream{Transport: DNSTransportTLS, Address: "1.1.1.1:853"}},
+		{in: "https://dns.example/dns-query", want: DNSUpstream{Transport: DNSTransportHTTPS, Address: "https://dns.example/dns-query"}},
+		{in: "quic://dns.example", wantErr: true},
+		{in: "https:///dns-query", wantErr: true},
+		{in: "udp://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDNSUpstream(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewUpstreamResolver_NoServers(t *testing.T) {
+	_, err := NewUpstreamResolver(UpstreamResolverConfig{})
+	assert.Error(t, err)
+}
+
+func TestUpstreamResolver_Transports(t *testing.T) {
+	standIn := newDNSStandIn()
+
+	// DNS-over-HTTPS server, whose certificate also serves DNS-over-TLS
+	doh := httptest.NewTLSServer(standIn)
+	defer doh.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(doh.Certificate())
+	tlsConfig := &tls.Config{RootCAs: roots}
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tlsListener, err := tls.Listen("tcp", "127.0.0.1:0", doh.TLS)
+	require.NoError(t, err)
+
+	udpAddr := standIn.serveUDP(t, "127.0.0.1:0")
+	tcpAddr := standIn.serveStream(t, tcpListener)
+	tlsAddr := standIn.serveStream(t, tlsListener)
+
+	servers := map[string]DNSUpstream{
+		"udp":   {Transport: DNSTransportUDP, Address: udpAddr},
+		"tcp":   {Transport: DNSTransportTCP, Address: tcpAddr},
+		"tls":   {Transport: DNSTransportTLS, Address: tlsAddr},
+		"https": {Transport: DNSTransportHTTPS, Address: doh.URL + "/dns-query"},
+	}
+
+	for name, server := range servers {
+		t.Run(name, func(t *testing.T) {
+			resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+				Servers:   []DNSUpstream{server},
+				Timeout:   2 * time.Second,
+				TLSConfig: tlsConfig,
+			})
+			require.NoError(t, err)
+
+			answer, err := resolver.Lookup(context.Background(), "pool.example")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}, answer.IPs)
+			assert.Equal(t, 120*time.Second, answer.TTL)
+		})
+	}
+}
+
+func TestUpstreamResolver_TruncatedRetriesOverTCP(t *testing.T) {
+	standIn := newDNSStandIn()
+	standIn.truncateUDP = true
+
+	// Serve UDP and TCP on the same port, as a real server does
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := standIn.serveStream(t, tcpListener)
+
+	standIn.serveUDP(t, addr)
+
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: addr}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	answer, err := resolver.Lookup(context.Background(), "pool.example")
+	require.NoError(t, err)
+	assert.Len(t, answer.IPs, 3)
+}
+
+func TestUpstreamResolver_NotFound(t *testing.T) {
+	standIn := newDNSStandIn()
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	_, err = resolver.Lookup(context.Background(), "missing.example")
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	assert.True(t, dnsErr.IsNotFound)
+}
+
+func TestUpstreamResolver_FallsBackToNextServer(t *testing.T) {
+	// Nothing answers on a closed port: the first server times out
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	standIn := newDNSStandIn()
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{
+			{Transport: DNSTransportTCP, Address: deadAddr},
+			{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")},
+		},
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	answer, err := resolver.Lookup(context.Background(), "pool.example")
+	require.NoError(t, err)
+	assert.Len(t, answer.IPs, 3)
+}
+
+func TestDNSCache_UsesRecordTTL(t *testing.T) {
+	standIn := newDNSStandIn()
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		ttl    uint32
+		min    time.Duration
+		max    time.Duration
+		expect time.Duration
+	}{
+		{name: "within bounds", ttl: 120, min: time.Minute, max: time.Hour, expect: 2 * time.Minute},
+		{name: "clamped to min", ttl: 5, min: time.Minute, max: time.Hour, expect: time.Minute},
+		{name: "clamped to max", ttl: 86400, min: time.Minute, max: time.Hour, expect: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn.ttl.Store(tt.ttl)
+			cache := NewDNSCache(DNSCacheConfig{MinTTL: tt.min, MaxTTL: tt.max, Resolver: resolver})
+
+			ips, err := cache.Resolve(context.Background(), "pool.example")
+			require.NoError(t, err)
+			assert.Len(t, ips, 3)
+
+			cache.mu.RLock()
+			entry := cache.cache["pool.example"]
+			cache.mu.RUnlock()
+			require.NotNil(t, entry)
+			assert.Equal(t, tt.expect, entry.TTL)
+		})
+	}
+}
+
+func TestDNSCache_HitsAndMisses(t *testing.T) {
+	standIn := newDNSStandIn()
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+	cache := NewDNSCache(DNSCacheConfig{Resolver: resolver})
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.Resolve(context.Background(), "pool.example")
+		require.NoError(t, err)
+	}
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats["hits"])
+	assert.Equal(t, uint64(1), stats["misses"])
+	assert.Equal(t, int32(2), standIn.queries.Load()) // One A and one AAAA question
+}
+
+func TestClient_ResolvesThroughDNSCache(t *testing.T) {
+	standIn := newDNSStandIn()
+	standIn.records["ntp.example."] = []string{"127.0.0.1"}
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	cache := NewDNSCache(DNSCacheConfig{Resolver: resolver})
+	client := NewClient(time.Second, 4)
+	client.SetDNSCache(cache)
+
+	conn, err := client.dial(context.Background(), "", "ntp.example:123")
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "127.0.0.1:123", conn.RemoteAddr().String())
+
+	_, err = client.dial(context.Background(), "", "missing.example:123")
+	assert.Error(t, err)
+}
+
+// blockingResolver answers nothing until its context is done
+type blockingResolver struct{}
+
+func (blockingResolver) Lookup(ctx context.Context, hostname string) (DNSAnswer, error) {
+	<-ctx.Done()
+	return DNSAnswer{}, ctx.Err()
+}
+
+func TestClient_SticksToOneAddress(t *testing.T) {
+	standIn := newDNSStandIn()
+	standIn.records["pool.example."] = []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+	resolver, err := NewUpstreamResolver(UpstreamResolverConfig{
+		Servers: []DNSUpstream{{Transport: DNSTransportUDP, Address: standIn.serveUDP(t, "127.0.0.1:0")}},
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	client := NewClient(time.Second, 4)
+	client.SetDNSCache(NewDNSCache(DNSCacheConfig{Resolver: resolver}))
+
+	// The samples of a burst all measure the first resolved address
+	for i := 0; i < 4; i++ {
+		conn, err := client.dial(context.Background(), "", "pool.example:123")
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1:123", conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
+
+// staticResolver answers the same addresses for every hostname
+type staticResolver []string
+
+func (r staticResolver) Lookup(ctx context.Context, hostname string) (DNSAnswer, error) {
+	return DNSAnswer{IPs: r}, nil
+}
+
+func TestClient_DialFallsBackToNextAddress(t *testing.T) {
+	client := NewClient(time.Second, 4)
+	client.SetDNSCache(NewDNSCache(DNSCacheConfig{Resolver: staticResolver{"::1", "127.0.0.2", "127.0.0.3"}}))
+
+	// An IPv6 address cannot be dialled from an IPv4 local address
+	for i := 0; i < 3; i++ {
+		conn, err := client.dial(context.Background(), "127.0.0.1", "pool.example:123")
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.2:123", conn.RemoteAddr().String(), "the next address sticks once dialled")
+		conn.Close()
+	}
+}
+
+func TestClient_DialFollowsQueryContext(t *testing.T) {
+	client := NewClient(time.Minute, 4)
+	client.SetDNSCache(NewDNSCache(DNSCacheConfig{Resolver: blockingResolver{}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.dial(ctx, "", "pool.example:123")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second, "the lookup ends with the query context")
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/maximewewer/ntp-exporter/pkg/logger"
+)
+
+// maxAdminBodyBytes bounds admin request bodies
+const maxAdminBodyBytes = 64 << 10
+
+// resetRequest is the body of POST /api/v1/admin/breakers/reset.
+// An empty server resets every breaker.
+type resetRequest struct {
+	Server string `json:"server"`
+}
+
+// overrideRequest is the body of POST /api/v1/admin/breakers/overrides
+type overrideRequest struct {
+	Server   string           `json:"server"`
+	Mode     ntp.OverrideMode `json:"mode"`
+	Duration string           `json:"duration"` // Go duration, empty for no expiry
+	Reason   string           `json:"reason"`
+}
+
+// registerAdmin registers the bearer-token protected admin endpoints
+func (h *Handlers) registerAdmin(mux *http.ServeMux) {
+	mux.Handle("POST /api/v1/admin/breakers/reset", h.requireAdmin(h.AdminResetHandler))
+	mux.Handle("GET /api/v1/admin/breakers/overrides", h.requireAdmin(h.AdminListOverridesHandler))
+	mux.Handle("POST /api/v1/admin/breakers/overrides", h.requireAdmin(h.AdminSetOverrideHandler))
+	mux.Handle("DELETE /api/v1/admin/breakers/overrides/{server}", h.requireAdmin(h.AdminClearOverrideHandler))
+}
+
+// requireAdmin rejects requests without the configured bearer token
+func (h *Handlers) requireAdmin(next http.HandlerFunc) http.Handler {
+	expected := []byte(h.config.Server.Admin.Token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || len(expected) == 0 || subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			logger.SafeWarn("security", "Unauthorized admin request", map[string]interface{}{
+				"path":   r.URL.Path,
+				"remote": r.RemoteAddr,
+			})
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ntp-exporter"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		if h.breakers == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "circuit breakers are not available")
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// AdminResetHandler resets one breaker, or all of them when no server is given
+func (h *Handlers) AdminResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req resetRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var reset []string
+	if req.Server == "" {
+		reset = h.breakers.ResetAll()
+	} else {
+		if !h.breakers.Reset(req.Server) {
+			writeJSONError(w, http.StatusNotFound, "no circuit breaker for "+req.Server)
+			return
+		}
+		reset = []string{req.Server}
+	}
+
+	logger.SafeWarn("admin", "Circuit breakers reset", map[string]interface{}{
+		"servers": reset,
+		"remote":  r.RemoteAddr,
+	})
+	writeJSON(w, http.StatusOK, map[string][]string{"reset": reset})
+}
+
+// AdminListOverridesHandler lists the active manual overrides
+func (h *Handlers) AdminListOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.breakers.Overrides())
+}
+
+// AdminSetOverrideHandler forces a server open or pins it closed
+func (h *Handlers) AdminSetOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid duration: "+err.Error())
+			return
+		}
+		duration = d
+	}
+
+	o, err := h.breakers.SetOverride(req.Server, req.Mode, duration, req.Reason)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// AdminClearOverrideHandler removes a server's manual override
+func (h *Handlers) AdminClearOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	server := r.PathValue("server")
+	if !h.breakers.ClearOverride(server) {
+		writeJSONError(w, http.StatusNotFound, "no override for "+server)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeJSONBody decodes a size-limited JSON body; an empty body leaves v unchanged
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return errors.New("invalid request body: " + err.Error())
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("server", "failed to encode response", err)
+	}
+}
+
+// writeJSONError writes {"error": msg}
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "0123456789abcdef"
+
+// fakeBreakers is a BreakerController backed by a real override store
+type fakeBreakers struct {
+	snapshots []ntp.BreakerSnapshot
+	known     map[string]bool
+	reset     []string
+	overrides *ntp.OverrideStore
+}
+
+func newFakeBreakers(servers ...string) *fakeBreakers {
+	f := &fakeBreakers{known: make(map[string]bool), overrides: ntp.NewOverrideStore()}
+	for _, s := range servers {
+		f.known[s] = true
+	}
+	return f
+}
+
+func (f *fakeBreakers) Snapshot() []ntp.BreakerSnapshot { return f.snapshots }
+
+func (f *fakeBreakers) Reset(server string) bool {
+	if !f.known[server] {
+		return false
+	}
+	f.reset = append(f.reset, server)
+	return true
+}
+
+func (f *fakeBreakers) ResetAll() []string {
+	for server := range f.known {
+		f.reset = append(f.reset, server)
+	}
+	return f.reset
+}
+
+func (f *fakeBreakers) SetOverride(server string, mode ntp.OverrideMode, d time.Duration, reason string) (ntp.Override, error) {
+	return f.overrides.Set(server, mode, d, reason)
+}
+
+func (f *fakeBreakers) ClearOverride(server string) bool { return f.overrides.Clear(server) }
+
+func (f *fakeBreakers) Overrides() []ntp.Override { return f.overrides.List() }
+
+// newAdminMux builds a mux with the admin endpoints enabled
+func newAdminMux(breakers BreakerController) *http.ServeMux {
+	cfg := &config.Config{}
+	cfg.Server.Admin = config.AdminConfig{Enabled: true, Token: testAdminToken}
+
+	handlers := NewHandlers(cfg, prometheus.NewRegistry())
+	handlers.breakers = breakers
+
+	mux := http.NewServeMux()
+	handlers.registerAdmin(mux)
+	return mux
+}
+
+func adminRequest(method, path, body, token string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	mux := newAdminMux(newFakeBreakers())
+
+	for _, token := range []string{"", "wrong-token-0000", testAdminToken + "x"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodGet, "/api/v1/admin/breakers/overrides", "", token))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "token %q", token)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodGet, "/api/v1/admin/breakers/overrides", "", testAdminToken))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdmin_Reset(t *testing.T) {
+	breakers := newFakeBreakers("a.example", "b.example")
+	mux := newAdminMux(breakers)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/breakers/reset", `{"server":"a.example"}`, testAdminToken))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"reset":["a.example"]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/breakers/reset", `{"server":"unknown.example"}`, testAdminToken))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	breakers.reset = nil
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/breakers/reset", "", testAdminToken))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []string{"a.example", "b.example"}, breakers.reset)
+}
+
+func TestAdmin_Overrides(t *testing.T) {
+	breakers := newFakeBreakers()
+	mux := newAdminMux(breakers)
+
+	w := httptest.NewRecorder()
+	body := `{"server":"stratum1.internal","mode":"open","duration":"2h","reason":"GPS antenna maintenance"}`
+	mux.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/breakers/overrides", body, testAdminToken))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"reason":"GPS antenna maintenance"`)
+
+	o, ok := breakers.overrides.Get("stratum1.internal")
+	require.True(t, ok)
+	assert.Equal(t, ntp.OverrideOpen, o.Mode)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), o.Until, time.Minute)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodGet, "/api/v1/admin/breakers/overrides", "", testAdminToken))
+	assert.Contains(t, w.Body.String(), `"server":"stratum1.internal"`)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/breakers/overrides/stratum1.internal", "", testAdminToken))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/breakers/overrides/stratum1.internal", "", testAdminToken))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdmin_SetOverrideInvalid(t *testing.T) {
+	mux := newAdminMux(newFakeBreakers())
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"bad_json", `{"server":`},
+		{"unknown_field", `{"server":"a","mode":"open","until":"tomorrow"}`},
+		{"bad_duration", `{"server":"a","mode":"open","duration":"soon"}`},
+		{"bad_mode", `{"server":"a","mode":"half-open"}`},
+		{"missing_server", `{"mode":"open"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/breakers/overrides", tt.body, testAdminToken))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"error"`)
+		})
+	}
+}
@@ -1,21 +1,48 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/maximewewer/ntp-exporter/pkg/logger"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// BreakerController exposes the circuit breakers to the API
+type BreakerController interface {
+	Snapshot() []ntp.BreakerSnapshot
+	Reset(server string) bool
+	ResetAll() []string
+	SetOverride(server string, mode ntp.OverrideMode, duration time.Duration, reason string) (ntp.Override, error)
+	ClearOverride(server string) bool
+	Overrides() []ntp.Override
+}
+
+// Collection runs the collectors on demand (collection_mode: on_scrape)
+type Collection interface {
+	Collect(ctx context.Context) error
+}
+
 // Handlers contains HTTP request handlers
 type Handlers struct {
-	config   *config.Config
-	registry *prometheus.Registry
+	config     *config.Config
+	registry   *prometheus.Registry
+	breakers   BreakerController
+	collection Collection
 }
 
+// scrapeTimeoutHeader carries the scrape timeout of Prometheus, in seconds
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeTimeoutOffset is kept from the scrape timeout to encode and send the
+// metrics before Prometheus gives up
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
 // NewHandlers creates a new handlers instance
 func NewHandlers(cfg *config.Config, registry *prometheus.Registry) *Handlers {
 	return &Handlers{
@@ -24,8 +51,13 @@ func NewHandlers(cfg *config.Config, registry *prometheus.Registry) *Handlers {
 	}
 }
 
-// MetricsHandler serves Prometheus metrics
+// MetricsHandler serves Prometheus metrics, collected first when
+// collection_mode is on_scrape
 func (h *Handlers) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.collection != nil {
+		h.collect(r)
+	}
+
 	handler := promhttp.HandlerFor(h.registry, promhttp.HandlerOpts{
 		ErrorLog:      &loggerAdapter{},
 		ErrorHandling: promhttp.ContinueOnError,
@@ -34,6 +66,41 @@ func (h *Handlers) MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// collect runs an on-scrape collection within the scrape timeout. The
+// metrics are served whatever the outcome: failed collectors show in
+// collector_up, and a collection cut short leaves the previous values.
+func (h *Handlers) collect(r *http.Request) {
+	ctx := r.Context()
+	if timeout := h.scrapeTimeout(r); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := h.collection.Collect(ctx); err != nil {
+		logger.SafeWarn("server", "On-scrape collection failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// scrapeTimeout returns how long a scrape may collect: the scrape timeout
+// sent by Prometheus, bounded by the server write timeout, less
+// scrapeTimeoutOffset; 0 for no limit
+func (h *Handlers) scrapeTimeout(r *http.Request) time.Duration {
+	timeout := h.config.Server.WriteTimeout
+	if seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64); err == nil && seconds > 0 {
+		scrape := time.Duration(seconds * float64(time.Second))
+		if timeout <= 0 || scrape < timeout {
+			timeout = scrape
+		}
+	}
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	return timeout
+}
+
 // HealthHandler returns health status
 func (h *Handlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -43,6 +110,23 @@ func (h *Handlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(response))
 }
 
+// BreakersHandler returns the circuit breaker state of every server as JSON.
+// The list is empty when circuit breakers are disabled.
+func (h *Handlers) BreakersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshots := []ntp.BreakerSnapshot{}
+	if h.breakers != nil {
+		snapshots = append(snapshots, h.breakers.Snapshot()...)
+	}
+
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
 // IndexHandler serves the index page
 func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -75,6 +159,7 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
         <ul>
             <li><a href="/metrics">/metrics</a> - Prometheus metrics</li>
             <li><a href="/health">/health</a> - Health check</li>
+            <li><a href="/api/v1/breakers">/api/v1/breakers</a> - Circuit breaker state</li>
         </ul>
         <h2>Configuration:</h2>
         <ul>
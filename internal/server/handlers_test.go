@@ -1,12 +1,14 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/maximewewer/ntp-exporter/internal/config"
+	"github.com/maximewewer/ntp-exporter/internal/ntp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
@@ -317,3 +319,86 @@ func BenchmarkHandlers_IndexHandler(b *testing.B) {
 		handlers.IndexHandler(w, req)
 	}
 }
+
+func TestHandlers_BreakersHandler(t *testing.T) {
+	retry := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		method   string
+		breakers BreakerController
+		wantCode int
+		wantBody string
+	}{
+		{name: "disabled", method: http.MethodGet, wantCode: http.StatusOK, wantBody: "[]\n"},
+		{
+			name:   "open_breaker",
+			method: http.MethodGet,
+			breakers: &fakeBreakers{snapshots: []ntp.BreakerSnapshot{{
+				Server:              "bad.example",
+				State:               "open",
+				ConsecutiveFailures: 5,
+				LastTransition:      retry.Add(-30 * time.Second),
+				RetryAt:             retry,
+			}}},
+			wantCode: http.StatusOK,
+			wantBody: `"server":"bad.example","state":"open"`,
+		},
+		{name: "method_not_allowed", method: http.MethodPost, wantCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := NewHandlers(&config.Config{}, prometheus.NewRegistry())
+			handlers.breakers = tt.breakers
+
+			req := httptest.NewRequest(tt.method, "/api/v1/breakers", nil)
+			w := httptest.NewRecorder()
+			handlers.BreakersHandler(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// fakeCollection records the deadline of the on-scrape collections
+type fakeCollection struct {
+	runs     int
+	deadline time.Duration
+}
+
+func (c *fakeCollection) Collect(ctx context.Context) error {
+	c.runs++
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadline = time.Until(deadline)
+	}
+	return nil
+}
+
+func TestHandlers_MetricsHandler_OnScrape(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{WriteTimeout: 10 * time.Second}}
+	collection := &fakeCollection{}
+	handlers := NewHandlers(cfg, prometheus.NewRegistry())
+	handlers.collection = collection
+
+	// The Prometheus scrape timeout bounds the collection, less the offset
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(scrapeTimeoutHeader, "4")
+	w := httptest.NewRecorder()
+	handlers.MetricsHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, collection.runs)
+	assert.InDelta(t, (4*time.Second - scrapeTimeoutOffset).Seconds(), collection.deadline.Seconds(), 0.1)
+
+	// Without the header, or above it, the write timeout applies
+	for _, header := range []string{"", "60", "invalid"} {
+		req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set(scrapeTimeoutHeader, header)
+		handlers.MetricsHandler(httptest.NewRecorder(), req)
+		assert.InDelta(t, (10*time.Second - scrapeTimeoutOffset).Seconds(), collection.deadline.Seconds(), 0.1, header)
+	}
+}
@@ -17,10 +17,12 @@ import (
 
 // Server represents the HTTP server
 type Server struct {
-	config   *config.Config
-	registry *prometheus.Registry
-	metrics  *metrics.NTPMetrics
-	server   *http.Server
+	config     *config.Config
+	registry   *prometheus.Registry
+	metrics    *metrics.NTPMetrics
+	breakers   BreakerController
+	collection Collection
+	server     *http.Server
 }
 
 // New creates a new HTTP server
@@ -32,6 +34,16 @@ func New(cfg *config.Config, registry *prometheus.Registry, m *metrics.NTPMetric
 	}
 }
 
+// SetBreakers sets the breakers served by /api/v1/breakers and controlled by the admin API
+func (s *Server) SetBreakers(breakers BreakerController) {
+	s.breakers = breakers
+}
+
+// SetCollection makes /metrics run the collection before serving the metrics
+func (s *Server) SetCollection(collection Collection) {
+	s.collection = collection
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(ctx context.Context) error {
 	// Create router
@@ -39,9 +51,15 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Register handlers
 	handlers := NewHandlers(s.config, s.registry)
+	handlers.breakers = s.breakers
+	handlers.collection = s.collection
 
 	mux.HandleFunc("/metrics", handlers.MetricsHandler)
 	mux.HandleFunc("/health", handlers.HealthHandler)
+	mux.HandleFunc("/api/v1/breakers", handlers.BreakersHandler)
+	if s.config.Server.Admin.Enabled {
+		handlers.registerAdmin(mux)
+	}
 	mux.HandleFunc("/", handlers.IndexHandler)
 
 	// Apply middleware
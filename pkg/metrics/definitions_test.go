@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -221,3 +222,19 @@ func BenchmarkMetricDefinitions_Gather(b *testing.B) {
 		}
 	}
 }
+
+func TestNTPMetrics_DeleteServer(t *testing.T) {
+	m := NewNTPMetrics()
+
+	m.OffsetSeconds.WithLabelValues("old.example.com", "2", "4").Set(0.01)
+	m.RTTSeconds.WithLabelValues("old.example.com").Set(0.02)
+	m.AddressUp.WithLabelValues("old.example.com", "192.0.2.1", "ipv4").Set(1)
+	m.RTTSeconds.WithLabelValues("kept.example.com").Set(0.03)
+	m.PoolServersTotal.WithLabelValues("old.example.com").Set(4)
+
+	assert.Equal(t, 3, m.DeleteServer("old.example.com"))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.OffsetSeconds))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.RTTSeconds), "other servers are kept")
+	assert.Equal(t, 0, testutil.CollectAndCount(m.AddressUp))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.PoolServersTotal), "only series with a server label are deleted")
+}
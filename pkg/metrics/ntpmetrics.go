@@ -18,6 +18,29 @@ type NTPMetrics struct {
 	RootDistance        *prometheus.GaugeVec
 	Precision           *prometheus.GaugeVec
 	LeapIndicator       *prometheus.GaugeVec
+	OffsetLowerBound    *prometheus.GaugeVec
+	OffsetUpperBound    *prometheus.GaugeVec
+	MinErrorSeconds     *prometheus.GaugeVec
+	PollInterval        *prometheus.GaugeVec
+
+	// Poll Scheduling Metrics (poll_scheduling enabled)
+	ScheduledPollSeconds *prometheus.GaugeVec
+
+	// Query Error Metrics
+	QueryErrorsTotal     *prometheus.CounterVec
+	ConsecutiveFailures  *prometheus.GaugeVec
+	LastSuccessTimestamp *prometheus.GaugeVec
+
+	// Clock Filter Metrics (sample_filter: clock_filter)
+	FilteredOffsetSeconds *prometheus.GaugeVec
+	PeerDispersionSeconds *prometheus.GaugeVec
+	PeerJitterSeconds     *prometheus.GaugeVec
+
+	// Per-Address Metrics (address_family set)
+	AddressUp            *prometheus.GaugeVec
+	AddressOffsetSeconds *prometheus.GaugeVec
+	AddressRTTSeconds    *prometheus.GaugeVec
+	AddressStratum       *prometheus.GaugeVec
 
 	// Quality Metrics
 	JitterSeconds    *prometheus.GaugeVec
@@ -29,6 +52,7 @@ type NTPMetrics struct {
 	// Security Metrics
 	ServerSuspiciousTotal   *prometheus.CounterVec
 	KissOfDeathTotal        *prometheus.CounterVec
+	ServerBackoffSeconds    *prometheus.GaugeVec // Remaining Kiss-of-Death backoff
 	MalformedResponsesTotal *prometheus.CounterVec
 	ServerTrustScore        *prometheus.GaugeVec
 
@@ -38,6 +62,30 @@ type NTPMetrics struct {
 	PoolDNSResolutionSeconds *prometheus.GaugeVec
 	PoolBestOffsetSeconds    *prometheus.GaugeVec
 
+	// Circuit Breaker Metrics
+	CircuitBreakerState               *prometheus.GaugeVec // 0=closed, 1=half-open, 2=open
+	CircuitBreakerTransitionsTotal    *prometheus.CounterVec
+	CircuitBreakerRequests            *prometheus.GaugeVec
+	CircuitBreakerSuccesses           *prometheus.GaugeVec
+	CircuitBreakerFailures            *prometheus.GaugeVec
+	CircuitBreakerConsecutiveFailures *prometheus.GaugeVec
+	CircuitBreakerOverride            *prometheus.GaugeVec // 1 while a manual override is active
+
+	// Rate Limit Metrics
+	RateLimitWaitSeconds    *prometheus.HistogramVec
+	RateLimitTokens         *prometheus.GaugeVec // scope=global|server
+	RateLimitThrottledTotal *prometheus.CounterVec
+	RateLimitRejectedTotal  *prometheus.CounterVec
+	RateLimitPerServerRate  prometheus.Gauge // Effective per-server rate, queries per second
+
+	// DNS Cache Metrics
+	DNSCacheHitsTotal   prometheus.Counter
+	DNSCacheMissesTotal prometheus.Counter
+	DNSCacheEntries     *prometheus.GaugeVec // state=valid|expired
+
+	// Discovery Metrics
+	TargetInfo *TargetInfo // Unchecked collector, registered apart from getAllMetrics
+
 	// Exporter Operational Metrics
 	ExporterBuildInfo             *prometheus.GaugeVec
 	ExporterScrapeDuration        prometheus.Histogram
@@ -48,8 +96,14 @@ type NTPMetrics struct {
 	ExporterGoroutinesCount       prometheus.Gauge
 
 	// Performance Metrics
-	QueryDurationSeconds     *prometheus.HistogramVec
-	CollectorDurationSeconds *prometheus.HistogramVec
+	QueryDurationSeconds      *prometheus.HistogramVec
+	CollectorDurationSeconds  *prometheus.HistogramVec
+	CollectorLastRunTimestamp *prometheus.GaugeVec
+	CollectorOverrunsTotal    *prometheus.CounterVec
+	CollectorUp               *prometheus.GaugeVec
+	CollectorErrorsTotal      *prometheus.CounterVec
+	CollectorLastSuccess      *prometheus.GaugeVec
+	CollectionTimestamp       prometheus.Gauge
 
 	// Advanced Memory and GC Metrics
 	GCDurationSeconds    prometheus.Summary
@@ -66,6 +120,42 @@ type NTPMetrics struct {
 	KernelPrecisionSeconds *prometheus.GaugeVec
 	KernelSyncStatus       *prometheus.GaugeVec
 	KernelStatusCode       *prometheus.GaugeVec
+	KernelDisciplineMode   *prometheus.GaugeVec
+
+	// Time daemons running on the host (Agent mode)
+	TimeDaemonInfo       *prometheus.GaugeVec
+	TimeDaemonConflict   *prometheus.GaugeVec
+	TimeDaemonClockState *prometheus.GaugeVec
+
+	// Clock discontinuities (Agent mode, Linux only)
+	ClockStepsTotal                 *prometheus.CounterVec
+	ClockLastStepSeconds            *prometheus.GaugeVec
+	ClockSuspendsTotal              *prometheus.CounterVec
+	ClockSuspendSecondsTotal        *prometheus.CounterVec
+	ClockSlewPPM                    *prometheus.GaugeVec
+	ClockTimeNamespaceOffsetSeconds *prometheus.GaugeVec
+	ClockTimeNamespaces             *prometheus.GaugeVec
+
+	// Clocksource (Agent mode, Linux only)
+	ClocksourceInfo            *prometheus.GaugeVec
+	ClocksourceAvailable       *prometheus.GaugeVec
+	ClocksourceChangesTotal    *prometheus.CounterVec
+	ClocksourceFrequencyPPM    *prometheus.GaugeVec
+	ClocksourceMaxErrorSeconds *prometheus.GaugeVec
+
+	// Holdover when every time source is lost
+	HoldoverActive                prometheus.Gauge
+	HoldoverSeconds               prometheus.Gauge
+	HoldoverEstimatedErrorSeconds prometheus.Gauge
+	HoldoverDriftPPM              prometheus.Gauge
+	HoldoverErrorExceeded         prometheus.Gauge
+	LastSyncTimestamp             prometheus.Gauge
+
+	// Local clock frequency estimated from the NTP offsets
+	LocalFrequencyPPM      prometheus.Gauge
+	LocalFrequencyLowerPPM prometheus.Gauge
+	LocalFrequencyUpperPPM prometheus.Gauge
+	LocalFrequencyServers  prometheus.Gauge
 
 	// Hybrid Mode Metrics - Correlation between NTP and Kernel
 	NTPKernelDivergence *prometheus.GaugeVec
@@ -175,6 +265,149 @@ func NewNTPMetricsWithConfig(namespace, subsystem string) *NTPMetrics {
 			},
 			[]string{"server"},
 		),
+		OffsetLowerBound: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "offset_lower_bound_seconds",
+				Help:      "Offset minus its error bound (RTT/2 + root delay/2 + root dispersion + dispersion growth) in seconds",
+			},
+			[]string{"server"},
+		),
+		OffsetUpperBound: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "offset_upper_bound_seconds",
+				Help:      "Offset plus its error bound (RTT/2 + root delay/2 + root dispersion + dispersion growth) in seconds",
+			},
+			[]string{"server"},
+		),
+		MinErrorSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "min_error_seconds",
+				Help:      "Lower bound on the clock error from the response timestamps in seconds",
+			},
+			[]string{"server"},
+		),
+		PollInterval: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "poll_interval_seconds",
+				Help:      "Poll interval advertised by the NTP server in seconds",
+			},
+			[]string{"server"},
+		),
+
+		// Poll Scheduling Metrics
+		ScheduledPollSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "scheduled_poll_interval_seconds",
+				Help:      "Current interval at which the exporter polls the server or pool in seconds",
+			},
+			[]string{"server"},
+		),
+
+		// Query Error Metrics
+		QueryErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "query_errors_total",
+				Help:      "Total number of failed base collector queries to the NTP server by reason",
+			},
+			[]string{"server", "reason"},
+		),
+		ConsecutiveFailures: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "consecutive_failures",
+				Help:      "Number of failed base collector queries to the NTP server since it last answered",
+			},
+			[]string{"server"},
+		),
+		LastSuccessTimestamp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "last_success_timestamp_seconds",
+				Help:      "Unix timestamp of the last base collector query the NTP server answered",
+			},
+			[]string{"server"},
+		),
+
+		// Clock Filter Metrics
+		FilteredOffsetSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "filtered_offset_seconds",
+				Help:      "Offset of the minimum-delay sample of the RFC 5905 clock filter in seconds",
+			},
+			[]string{"server"},
+		),
+		PeerDispersionSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "peer_dispersion_seconds",
+				Help:      "Peer dispersion of the RFC 5905 clock filter in seconds",
+			},
+			[]string{"server"},
+		),
+		PeerJitterSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "peer_jitter_seconds",
+				Help:      "Peer jitter of the RFC 5905 clock filter (RMS offset difference to the selected sample) in seconds",
+			},
+			[]string{"server"},
+		),
+
+		// Per-Address Metrics
+		AddressUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "address_up",
+				Help:      "Whether the resolved address of the server answered (1=answered, 0=failed)",
+			},
+			[]string{"server", "address", "family"},
+		),
+		AddressOffsetSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "address_offset_seconds",
+				Help:      "Clock offset measured against one resolved address of the server",
+			},
+			[]string{"server", "address", "family"},
+		),
+		AddressRTTSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "address_rtt_seconds",
+				Help:      "Round-trip time to one resolved address of the server",
+			},
+			[]string{"server", "address", "family"},
+		),
+		AddressStratum: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "address_stratum",
+				Help:      "Stratum reported by one resolved address of the server",
+			},
+			[]string{"server", "address", "family"},
+		),
 
 		// Quality Metrics
 		JitterSeconds: prometheus.NewGaugeVec(
@@ -242,6 +475,15 @@ func NewNTPMetricsWithConfig(namespace, subsystem string) *NTPMetrics {
 			},
 			[]string{"server", "code"},
 		),
+		ServerBackoffSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "server_backoff_seconds",
+				Help:      "Remaining time the server is not queried after a Kiss-of-Death (RATE, DENY, RSTR)",
+			},
+			[]string{"server", "kod"},
+		),
 		MalformedResponsesTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -299,6 +541,149 @@ func NewNTPMetricsWithConfig(namespace, subsystem string) *NTPMetrics {
 			[]string{"pool"},
 		),
 
+		// Circuit Breaker Metrics
+		CircuitBreakerState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "state",
+				Help:      "Circuit breaker state per server (0=closed, 1=half-open, 2=open)",
+			},
+			[]string{"server"},
+		),
+		CircuitBreakerTransitionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "transitions_total",
+				Help:      "Total number of circuit breaker state transitions",
+			},
+			[]string{"server", "from", "to"},
+		),
+		CircuitBreakerRequests: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "requests",
+				Help:      "Requests counted by the circuit breaker in its current interval",
+			},
+			[]string{"server"},
+		),
+		CircuitBreakerSuccesses: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "successes",
+				Help:      "Successful requests counted by the circuit breaker in its current interval",
+			},
+			[]string{"server"},
+		),
+		CircuitBreakerFailures: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "failures",
+				Help:      "Failed requests counted by the circuit breaker in its current interval",
+			},
+			[]string{"server"},
+		),
+		CircuitBreakerConsecutiveFailures: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "consecutive_failures",
+				Help:      "Current number of consecutive failed requests seen by the circuit breaker",
+			},
+			[]string{"server"},
+		),
+
+		CircuitBreakerOverride: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "circuit_breaker",
+				Name:      "override",
+				Help:      "Manual circuit breaker override in effect (1=active), by mode (open, closed)",
+			},
+			[]string{"server", "mode"},
+		),
+
+		// Rate Limit Metrics
+		RateLimitWaitSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "rate_limit",
+				Name:      "wait_seconds",
+				Help:      "Time NTP queries waited for the global and per-server rate limiters",
+				Buckets:   []float64{0, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"server"},
+		),
+		RateLimitTokens: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "rate_limit",
+				Name:      "tokens",
+				Help:      "Tokens currently available in the rate limiter (scope=global with an empty server, or scope=server)",
+			},
+			[]string{"scope", "server"},
+		),
+		RateLimitThrottledTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "rate_limit",
+				Name:      "throttled_total",
+				Help:      "NTP queries delayed by the rate limiter, by limiter scope",
+			},
+			[]string{"scope", "server"},
+		),
+		RateLimitRejectedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "rate_limit",
+				Name:      "rejected_total",
+				Help:      "NTP queries refused by the rate limiter (cancelled or no token before the deadline), by limiter scope",
+			},
+			[]string{"scope", "server"},
+		),
+		RateLimitPerServerRate: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "rate_limit",
+				Name:      "per_server_rate",
+				Help:      "Effective per-server rate limit in queries per second",
+			},
+		),
+
+		// DNS Cache Metrics
+		DNSCacheHitsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "dns_cache",
+				Name:      "hits_total",
+				Help:      "Hostname resolutions answered from the DNS cache",
+			},
+		),
+		DNSCacheMissesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "dns_cache",
+				Name:      "misses_total",
+				Help:      "Hostname resolutions sent to the DNS resolver (not cached or expired)",
+			},
+		),
+		DNSCacheEntries: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "dns_cache",
+				Name:      "entries",
+				Help:      "DNS cache entries by state (valid, expired)",
+			},
+			[]string{"state"},
+		),
+
+		// Discovery Metrics
+		TargetInfo: NewTargetInfo(namespace, subsystem),
+
 		// Exporter Operational Metrics
 		ExporterBuildInfo: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -383,6 +768,59 @@ func NewNTPMetricsWithConfig(namespace, subsystem string) *NTPMetrics {
 			},
 			[]string{"collector"},
 		),
+		CollectorLastRunTimestamp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_last_run_timestamp_seconds",
+				Help:      "Unix time at which the collector last finished a run",
+			},
+			[]string{"collector"},
+		),
+		CollectorOverrunsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_overruns_total",
+				Help:      "Runs of the collector that lasted longer than its interval, delaying the next one",
+			},
+			[]string{"collector"},
+		),
+		CollectorUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_up",
+				Help:      "Whether the last run of the collector succeeded (1) or failed (0)",
+			},
+			[]string{"collector"},
+		),
+		CollectorErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_errors_total",
+				Help:      "Failed runs of the collector by reason (timeout, canceled, error)",
+			},
+			[]string{"collector", "reason"},
+		),
+		CollectorLastSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_last_success_timestamp_seconds",
+				Help:      "Unix time at which the collector last finished a run without error",
+			},
+			[]string{"collector"},
+		),
+		CollectionTimestamp: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collection_timestamp_seconds",
+				Help:      "Unix time at which the metrics served were collected (collection_mode on_scrape)",
+			},
+		),
 
 		// Advanced Memory and GC Metrics
 		GCDurationSeconds: prometheus.NewSummary(
@@ -491,6 +929,240 @@ func NewNTPMetricsWithConfig(namespace, subsystem string) *NTPMetrics {
 			},
 			[]string{"node"},
 		),
+		KernelDisciplineMode: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "kernel_discipline_mode",
+				Help:      "Kernel clock discipline from the STA_PLL and STA_FLL bits (1 for the current mode: pll, fll or none)",
+			},
+			[]string{"node", "mode"},
+		),
+
+		// Time daemons running on the host (Agent mode)
+		TimeDaemonInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "time_daemon_info",
+				Help:      "Time daemon running on the host (always 1)",
+			},
+			[]string{"node", "daemon", "version"},
+		),
+		TimeDaemonConflict: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "time_daemon_conflict",
+				Help:      "Whether more than one running daemon adjusts the system clock (1=conflict, 0=ok)",
+			},
+			[]string{"node"},
+		),
+		TimeDaemonClockState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "time_daemon_clock_state",
+				Help:      "Clock state from the running daemons and the kernel status (1 for the current state: synchronized, unsynchronized, conflict, free_running or orphaned)",
+			},
+			[]string{"node", "state"},
+		),
+
+		// Clock discontinuities (Agent mode, Linux only)
+		ClockStepsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_steps_total",
+				Help:      "Total number of times the system clock was set (stepped)",
+			},
+			[]string{"node"},
+		),
+		ClockLastStepSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_last_step_seconds",
+				Help:      "Size of the last system clock step in seconds (negative when set back)",
+			},
+			[]string{"node"},
+		),
+		ClockSuspendsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_suspends_total",
+				Help:      "Total number of suspend/resume cycles or VM pauses",
+			},
+			[]string{"node"},
+		),
+		ClockSuspendSecondsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_suspend_seconds_total",
+				Help:      "Total time spent suspended in seconds",
+			},
+			[]string{"node"},
+		),
+		ClockSlewPPM: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_slew_ppm",
+				Help:      "Rate at which the system clock is slewed against the raw hardware clock in PPM",
+			},
+			[]string{"node"},
+		),
+		ClockTimeNamespaceOffsetSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_time_namespace_offset_seconds",
+				Help:      "Offset of the exporter's time namespace per clock in seconds (0 outside of a time namespace)",
+			},
+			[]string{"node", "clock"},
+		),
+		ClockTimeNamespaces: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clock_time_namespaces",
+				Help:      "Number of time namespaces other than the host's, i.e. time-namespaced containers",
+			},
+			[]string{"node"},
+		),
+
+		// Clocksource (Agent mode, Linux only)
+		ClocksourceInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clocksource_info",
+				Help:      "Current kernel clocksource (always 1)",
+			},
+			[]string{"node", "clocksource"},
+		),
+		ClocksourceAvailable: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clocksource_available",
+				Help:      "Clocksource available to the kernel (always 1); an unstable TSC is removed",
+			},
+			[]string{"node", "clocksource"},
+		),
+		ClocksourceChangesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clocksource_changes_total",
+				Help:      "Total number of current clocksource changes seen between collections",
+			},
+			[]string{"node"},
+		),
+		ClocksourceFrequencyPPM: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clocksource_frequency_ppm",
+				Help:      "Kernel frequency adjustment in PPM, labelled with the current clocksource",
+			},
+			[]string{"node", "clocksource"},
+		),
+		ClocksourceMaxErrorSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "clocksource_max_error_seconds",
+				Help:      "Kernel maximum time error in seconds, labelled with the current clocksource",
+			},
+			[]string{"node", "clocksource"},
+		),
+
+		// Holdover when every time source is lost
+		HoldoverActive: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "holdover_active",
+				Help:      "Whether no time source answered in the last collection (1=holdover, 0=synchronized)",
+			},
+		),
+		HoldoverSeconds: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "holdover_seconds",
+				Help:      "Time since the last collection in which a time source answered, 0 when synchronized",
+			},
+		),
+		HoldoverEstimatedErrorSeconds: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "holdover_estimated_error_seconds",
+				Help:      "Estimated worst-case clock error accumulated since the last sync in seconds",
+			},
+		),
+		HoldoverDriftPPM: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "holdover_drift_ppm",
+				Help:      "Absolute drift rate assumed by the holdover error estimate in PPM",
+			},
+		),
+		HoldoverErrorExceeded: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "holdover_error_exceeded",
+				Help:      "Whether the estimated holdover error exceeds max_clock_offset (1=exceeded, 0=ok)",
+			},
+		),
+		LastSyncTimestamp: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "last_sync_timestamp_seconds",
+				Help:      "Unix timestamp of the last collection in which a time source answered",
+			},
+		),
+
+		// Local clock frequency estimated from the NTP offsets
+		LocalFrequencyPPM: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "local_frequency_ppm",
+				Help:      "Frequency error of the local clock estimated from the trend of the NTP offsets in PPM, positive when it runs fast",
+			},
+		),
+		LocalFrequencyLowerPPM: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "local_frequency_lower_ppm",
+				Help:      "Lower bound of the 95% confidence interval of the local frequency error in PPM",
+			},
+		),
+		LocalFrequencyUpperPPM: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "local_frequency_upper_ppm",
+				Help:      "Upper bound of the 95% confidence interval of the local frequency error in PPM",
+			},
+		),
+		LocalFrequencyServers: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "local_frequency_servers",
+				Help:      "Number of servers the local frequency error is estimated from, 0 when there is no estimate",
+			},
+		),
 
 		// Hybrid Mode Metrics - Correlation between NTP and Kernel
 		NTPKernelDivergence: prometheus.NewGaugeVec(
@@ -534,6 +1206,27 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 		m.RootDistance,
 		m.Precision,
 		m.LeapIndicator,
+		m.OffsetLowerBound,
+		m.OffsetUpperBound,
+		m.MinErrorSeconds,
+		m.PollInterval,
+		m.ScheduledPollSeconds,
+
+		// Query error metrics
+		m.QueryErrorsTotal,
+		m.ConsecutiveFailures,
+		m.LastSuccessTimestamp,
+
+		// Clock filter metrics
+		m.FilteredOffsetSeconds,
+		m.PeerDispersionSeconds,
+		m.PeerJitterSeconds,
+
+		// Per-address metrics
+		m.AddressUp,
+		m.AddressOffsetSeconds,
+		m.AddressRTTSeconds,
+		m.AddressStratum,
 
 		// Quality metrics
 		m.JitterSeconds,
@@ -545,6 +1238,7 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 		// Security metrics
 		m.ServerSuspiciousTotal,
 		m.KissOfDeathTotal,
+		m.ServerBackoffSeconds,
 		m.MalformedResponsesTotal,
 		m.ServerTrustScore,
 
@@ -554,6 +1248,27 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 		m.PoolDNSResolutionSeconds,
 		m.PoolBestOffsetSeconds,
 
+		// Circuit breaker metrics
+		m.CircuitBreakerState,
+		m.CircuitBreakerTransitionsTotal,
+		m.CircuitBreakerRequests,
+		m.CircuitBreakerSuccesses,
+		m.CircuitBreakerFailures,
+		m.CircuitBreakerConsecutiveFailures,
+		m.CircuitBreakerOverride,
+
+		// Rate limit metrics
+		m.RateLimitWaitSeconds,
+		m.RateLimitTokens,
+		m.RateLimitThrottledTotal,
+		m.RateLimitRejectedTotal,
+		m.RateLimitPerServerRate,
+
+		// DNS cache metrics
+		m.DNSCacheHitsTotal,
+		m.DNSCacheMissesTotal,
+		m.DNSCacheEntries,
+
 		// Exporter operational metrics
 		m.ExporterBuildInfo,
 		m.ExporterScrapeDuration,
@@ -564,6 +1279,12 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 		m.ExporterGoroutinesCount,
 		m.QueryDurationSeconds,
 		m.CollectorDurationSeconds,
+		m.CollectorLastRunTimestamp,
+		m.CollectorOverrunsTotal,
+		m.CollectorUp,
+		m.CollectorErrorsTotal,
+		m.CollectorLastSuccess,
+		m.CollectionTimestamp,
 		m.GCDurationSeconds,
 		m.MemoryAllocatedBytes,
 		m.MemoryHeapBytes,
@@ -578,6 +1299,42 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 		m.KernelPrecisionSeconds,
 		m.KernelSyncStatus,
 		m.KernelStatusCode,
+		m.KernelDisciplineMode,
+
+		// Time daemon metrics
+		m.TimeDaemonInfo,
+		m.TimeDaemonConflict,
+		m.TimeDaemonClockState,
+
+		// Clock discontinuity metrics
+		m.ClockStepsTotal,
+		m.ClockLastStepSeconds,
+		m.ClockSuspendsTotal,
+		m.ClockSuspendSecondsTotal,
+		m.ClockSlewPPM,
+		m.ClockTimeNamespaceOffsetSeconds,
+		m.ClockTimeNamespaces,
+
+		// Clocksource metrics
+		m.ClocksourceInfo,
+		m.ClocksourceAvailable,
+		m.ClocksourceChangesTotal,
+		m.ClocksourceFrequencyPPM,
+		m.ClocksourceMaxErrorSeconds,
+
+		// Holdover metrics
+		m.HoldoverActive,
+		m.HoldoverSeconds,
+		m.HoldoverEstimatedErrorSeconds,
+		m.HoldoverDriftPPM,
+		m.HoldoverErrorExceeded,
+		m.LastSyncTimestamp,
+
+		// Local frequency metrics
+		m.LocalFrequencyPPM,
+		m.LocalFrequencyLowerPPM,
+		m.LocalFrequencyUpperPPM,
+		m.LocalFrequencyServers,
 
 		// Hybrid metrics
 		m.NTPKernelDivergence,
@@ -585,6 +1342,19 @@ func (m *NTPMetrics) getAllMetrics() []prometheus.Collector {
 	}
 }
 
+// DeleteServer deletes every series labelled with the given server and
+// returns how many were deleted
+func (m *NTPMetrics) DeleteServer(server string) int {
+	labels := prometheus.Labels{"server": server}
+	deleted := 0
+	for _, metric := range m.getAllMetrics() {
+		if vec, ok := metric.(interface{ DeletePartialMatch(prometheus.Labels) int }); ok {
+			deleted += vec.DeletePartialMatch(labels)
+		}
+	}
+	return deleted
+}
+
 // Describe implements prometheus.Collector interface
 func (m *NTPMetrics) Describe(ch chan<- *prometheus.Desc) {
 	for _, metric := range m.getAllMetrics() {
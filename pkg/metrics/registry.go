@@ -7,8 +7,9 @@ import (
 
 // Registry manages Prometheus metric registration
 type Registry struct {
-	registry   *prometheus.Registry
-	ntpMetrics *NTPMetrics
+	registry    *prometheus.Registry
+	ntpMetrics  *NTPMetrics
+	constLabels prometheus.Labels
 }
 
 // NewRegistry creates a new metrics registry with NTP metrics
@@ -25,16 +26,35 @@ func NewRegistryWithConfig(namespace, subsystem string) *Registry {
 	}
 }
 
+// NewRegistryWithLabels creates a new metrics registry whose series all carry
+// the given constant labels, Go runtime and process metrics included
+func NewRegistryWithLabels(namespace, subsystem string, labels map[string]string) *Registry {
+	r := NewRegistryWithConfig(namespace, subsystem)
+	if len(labels) > 0 {
+		r.constLabels = make(prometheus.Labels, len(labels))
+		for name, value := range labels {
+			r.constLabels[name] = value
+		}
+	}
+	return r
+}
+
 // Register registers all NTP exporter metrics
 func (r *Registry) Register() error {
+	// Constant labels are added to every collector registered through this wrapper
+	registerer := prometheus.WrapRegistererWith(r.constLabels, r.registry)
+
 	// Register the NTP metrics collector
-	if err := r.registry.Register(r.ntpMetrics); err != nil {
+	if err := registerer.Register(r.ntpMetrics); err != nil {
+		return err
+	}
+	if err := registerer.Register(r.ntpMetrics.TargetInfo); err != nil {
 		return err
 	}
 
 	// Register Go runtime metrics
-	r.registry.MustRegister(collectors.NewGoCollector())
-	r.registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	registerer.MustRegister(collectors.NewGoCollector())
+	registerer.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
 	return nil
 }
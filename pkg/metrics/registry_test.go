@@ -379,3 +379,71 @@ func TestNewNTPMetrics_BackwardCompatibility(t *testing.T) {
 	assert.True(t, metricNames["ntp_exporter_build_info"], "Expected default metric ntp_exporter_build_info")
 	assert.True(t, metricNames["ntp_pool_servers_active"], "Expected default metric ntp_pool_servers_active")
 }
+
+func TestRegistryWithLabels_AppliedToAllSeries(t *testing.T) {
+	reg := NewRegistryWithLabels("ntp", "", map[string]string{"site": "par1", "probe": "edge-01"})
+	require.NoError(t, reg.Register())
+
+	m := reg.GetMetrics()
+	m.OffsetSeconds.WithLabelValues("test.ntp.org", "2", "4").Set(0.001)
+	m.ExporterBuildInfo.WithLabelValues("1.0.0", "test", "go1.21").Set(1)
+
+	metricFamilies, err := reg.GetRegistry().Gather()
+	require.NoError(t, err)
+
+	seen := make(map[string]bool)
+	for _, mf := range metricFamilies {
+		seen[mf.GetName()] = true
+		for _, metric := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			assert.Equal(t, "par1", labels["site"], "missing site label on %s", mf.GetName())
+			assert.Equal(t, "edge-01", labels["probe"], "missing probe label on %s", mf.GetName())
+		}
+	}
+
+	assert.True(t, seen["ntp_offset_seconds"])
+	assert.True(t, seen["ntp_exporter_build_info"])
+	assert.True(t, seen["go_goroutines"], "Go runtime metrics should be labelled too")
+}
+
+func TestRegistryWithLabels_ConflictingLabel(t *testing.T) {
+	reg := NewRegistryWithLabels("ntp", "", map[string]string{"server": "x"})
+
+	assert.Error(t, reg.Register(), "a constant label clashing with a variable label must be rejected")
+}
+
+func TestRegistry_TargetInfo(t *testing.T) {
+	reg := NewRegistry()
+	require.NoError(t, reg.Register())
+
+	reg.GetMetrics().TargetInfo.Set([]TargetLabels{
+		{Server: "time1.example.com", Source: "file:/etc/targets.json", Labels: map[string]string{"site": "par1"}},
+		{Server: "time2.example.com", Source: "srv:_ntp._udp.example.com"},
+	})
+
+	metricFamilies, err := reg.GetRegistry().Gather()
+	require.NoError(t, err, "targets with different label names must gather")
+
+	var series []map[string]string
+	for _, mf := range metricFamilies {
+		if mf.GetName() != "ntp_target_info" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			assert.Equal(t, 1.0, metric.GetGauge().GetValue())
+			labels := make(map[string]string)
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+
+	assert.ElementsMatch(t, []map[string]string{
+		{"server": "time1.example.com", "source": "file:/etc/targets.json", "site": "par1"},
+		{"server": "time2.example.com", "source": "srv:_ntp._udp.example.com"},
+	}, series)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TargetLabels describes one discovered target for the target_info metric
+type TargetLabels struct {
+	Server string
+	Source string
+	Labels map[string]string
+}
+
+// TargetInfo exports one target_info series (value 1) per discovered target,
+// labelled with its server, discovery source and the target's own labels.
+// Label names vary between targets, so it is an unchecked collector: its
+// Describe sends nothing and it is registered apart from NTPMetrics.
+type TargetInfo struct {
+	fqName string
+
+	mu      sync.RWMutex
+	targets []TargetLabels
+}
+
+// NewTargetInfo creates the target_info collector
+func NewTargetInfo(namespace, subsystem string) *TargetInfo {
+	return &TargetInfo{
+		fqName: prometheus.BuildFQName(namespace, subsystem, "target_info"),
+	}
+}
+
+// Set replaces the exported targets
+func (t *TargetInfo) Set(targets []TargetLabels) {
+	t.mu.Lock()
+	t.targets = append([]TargetLabels(nil), targets...)
+	t.mu.Unlock()
+}
+
+// Describe implements prometheus.Collector; nothing is sent, as for any
+// unchecked collector
+func (t *TargetInfo) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (t *TargetInfo) Collect(ch chan<- prometheus.Metric) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, target := range t.targets {
+		names := make([]string, 0, len(target.Labels))
+		for name := range target.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		labelNames := append([]string{"server", "source"}, names...)
+		values := []string{target.Server, target.Source}
+		for _, name := range names {
+			values = append(values, target.Labels[name])
+		}
+
+		desc := prometheus.NewDesc(t.fqName, "Discovered NTP target with the labels of its discovery source (always 1)", labelNames, nil)
+		metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- metric
+	}
+}